@@ -55,6 +55,12 @@ type ChartSpec struct {
 	// ChartTgz containts the complete chart
 	ChartTgz []byte `json:"chart_tgz,omitempty"`
 	// +optional
+	// ChartProvenance containts the provenance file of ChartTgz created by kdo package --sign-key
+	ChartProvenance []byte `json:"chart_provenance,omitempty"`
+	// +optional
+	// ChartSignature containts the signature of ChartProvenance
+	ChartSignature []byte `json:"chart_signature,omitempty"`
+	// +optional
 	// ChartURL containts the URL for the chart. If empty the ChartTgz field is used.
 	ChartURL string `json:"chart_url,omitempty"`
 	// +optional
@@ -40,6 +40,16 @@ func (in *ChartSpec) DeepCopyInto(out *ChartSpec) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.ChartProvenance != nil {
+		in, out := &in.ChartProvenance, &out.ChartProvenance
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChartSignature != nil {
+		in, out := &in.ChartSignature, &out.ChartSignature
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartSpec.
@@ -12,6 +12,7 @@ import (
 
 var applyChartArgs = kdo.ChartOptions{}
 var applyK8sArgs = k8s.Configs{}
+var applySkipValidation bool
 
 var newK8s = func(configs ...k8s.Config) (k8s.K8s, error) {
 	return k8s.NewK8s(configs...)
@@ -29,7 +30,7 @@ var applyCmd = &cobra.Command{
 		if err != nil {
 			exit(err)
 		}
-		exit(apply(args[0], k8s, applyChartArgs.Merge()))
+		exit(apply(args[0], k8s, applyChartArgs.Merge(), kdo.WithSkipValidation(applySkipValidation)))
 	},
 }
 
@@ -50,4 +51,5 @@ func init() {
 	applyChartArgs.AddFlags(applyCmd.Flags())
 	applyK8sArgs.AddFlags(applyCmd.Flags())
 	rootOsbConfig.AddFlags(applyCmd.Flags())
+	applyCmd.Flags().BoolVar(&applySkipValidation, "skip-validation", false, "Skip the validation of the rendered objects before they are applied")
 }
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var bundleCreateOutput string
+var bundleImportDir string
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "transfer charts into environments without network access",
+	Long:  ``,
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create [chart]",
+	Short: "write a chart and all charts it loads into a bundle",
+	Long:  `Discovers all charts loaded by the chart using chart, helm_chart, depends_on and catalogs and writes them together with their urls into a bundle.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(bundleCreate(args[0], bundleCreateOutput))
+	},
+}
+
+var bundleImportCmd = &cobra.Command{
+	Use:   "import [bundle]",
+	Short: "store the charts of a bundle in the cache",
+	Long:  `Stores the charts of a bundle in the cache, so they can be loaded using their original urls with --offline. A packaged root chart is written into --dir.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(bundleImport(args[0], bundleImportDir))
+	},
+}
+
+func bundleCreate(url string, output string) error {
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	bundle, err := kdo.CreateBundle(thread, url, file, repoConfigs()...)
+	if err != nil {
+		return err
+	}
+	for _, u := range bundle.URLs {
+		fmt.Printf("bundled %s\n", u)
+	}
+	fmt.Printf("chart %s written to %s\n", bundle.Chart, output)
+	return nil
+}
+
+func bundleImport(filename string, dir string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	bundle, err := kdo.ImportBundle(file, dir, repoConfigs()...)
+	if err != nil {
+		return err
+	}
+	for _, u := range bundle.URLs {
+		fmt.Printf("imported %s\n", u)
+	}
+	fmt.Printf("chart %s\n", bundle.Chart)
+	return nil
+}
+
+func init() {
+	bundleCreateCmd.Flags().StringVarP(&bundleCreateOutput, "output", "o", "bundle.tgz", "file name of the bundle")
+	bundleImportCmd.Flags().StringVar(&bundleImportDir, "dir", ".", "directory of the packaged root chart")
+	bundleCmd.AddCommand(bundleCreateCmd, bundleImportCmd)
+}
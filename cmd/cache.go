@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var cachePruneOlderThan time.Duration
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "manage the cache of downloaded charts",
+	Long:  ``,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the cached charts, indexes and repositories",
+	Long:  ``,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(cacheList(os.Stdout))
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "remove cache entries, which haven't been used for a while",
+	Long:  `Removes all cache entries, which haven't been fetched within --older-than (default: the ttl of the cache), and incomplete entries.`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(cachePrune(os.Stdout, cachePruneOlderThan))
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "remove all cache entries",
+	Long:  ``,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(cacheClear())
+	},
+}
+
+var cacheWarmCmd = &cobra.Command{
+	Use:   "warm [chart]...",
+	Short: "load charts and their resolved dependencies into the cache",
+	Long:  `Loads the charts and resolves their dependencies, so they can be used with --offline afterwards.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(cacheWarm(os.Stdout, args))
+	},
+}
+
+func cacheList(writer io.Writer) error {
+	cache, err := kdo.NewCache(repoConfigs()...)
+	if err != nil {
+		return err
+	}
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.Dir == "" {
+			name = "<incomplete>"
+		}
+		fetched := "-"
+		if !entry.Fetched.IsZero() {
+			fetched = entry.Fetched.Format(time.RFC3339)
+		}
+		if _, err := fmt.Fprintf(writer, "%-25s %10d %s\n", fetched, entry.Size, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cachePrune(writer io.Writer, olderThan time.Duration) error {
+	cache, err := kdo.NewCache(repoConfigs()...)
+	if err != nil {
+		return err
+	}
+	if olderThan == 0 {
+		olderThan = cache.TTL()
+	}
+	if olderThan == 0 {
+		return fmt.Errorf("No cache ttl configured. Use --older-than")
+	}
+	pruned, err := cache.Prune(olderThan)
+	if err != nil {
+		return err
+	}
+	for _, entry := range pruned {
+		if _, err := fmt.Fprintf(writer, "removed %s\n", entry.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cacheClear() error {
+	cache, err := kdo.NewCache(repoConfigs()...)
+	if err != nil {
+		return err
+	}
+	return cache.Clear()
+}
+
+func cacheWarm(writer io.Writer, urls []string) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	for _, url := range urls {
+		c, err := repo.Get(thread, url)
+		if err != nil {
+			return err
+		}
+		lock, err := repo.Resolve(thread, c, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(writer, "cached %s %s\n", url, c.GetVersion()); err != nil {
+			return err
+		}
+		for _, dep := range lock.Dependencies {
+			if _, err := fmt.Fprintf(writer, "cached %s %s\n", dep.URL, dep.Version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	cachePruneCmd.Flags().DurationVar(&cachePruneOlderThan, "older-than", 0, "remove entries, which haven't been fetched within this duration")
+	cacheCmd.AddCommand(cacheListCmd, cachePruneCmd, cacheClearCmd, cacheWarmCmd)
+}
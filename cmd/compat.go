@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var compatCmd = &cobra.Command{
+	Use:   "compat [old chart] [new chart]",
+	Short: "check if the properties of two kdo chart versions are compatible",
+	Long:  `Lists all property changes between two versions of a chart. Fails, if there are breaking changes without major version bump.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(compat(args[0], args[1]))
+	},
+}
+
+func compat(oldURL string, newURL string) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	old, err := repo.Get(thread, oldURL, kdo.WithPlaceholderArgs(true))
+	if err != nil {
+		return err
+	}
+	new, err := repo.Get(thread, newURL, kdo.WithPlaceholderArgs(true))
+	if err != nil {
+		return err
+	}
+	report := kdo.CompareCharts(old, new)
+	if err := report.Write(os.Stdout); err != nil {
+		return err
+	}
+	return report.Check()
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"strings"
+
 	kdov1a2 "github.com/sap/kubernetes-deployment-orchestrator/api/v1alpha2"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
 
 	"github.com/pkg/errors"
 	"github.com/sap/kubernetes-deployment-orchestrator/controllers"
@@ -15,6 +18,9 @@ import (
 )
 
 var controllerK8sArgs = k8s.Configs{}
+var controllerPolicyRules []string
+var controllerPolicyFiles []string
+var controllerSkipValidation bool
 
 var controllerCmd = &cobra.Command{
 	Use:   "controller",
@@ -45,7 +51,11 @@ func controller(stopCh <-chan struct{}) error {
 	if err != nil {
 		return errors.Wrap(err, "unable to add kdo scheme")
 	}
-	repo, err := repo()
+	secrets, err := k8s.NewK8s(controllerK8sArgs.Merge())
+	if err != nil {
+		return err
+	}
+	repo, err := kdo.NewRepo(append(append(repoConfigs(), kdo.WithCredentialSecrets(secrets)), controllerPolicies()...)...)
 	if err != nil {
 		return err
 	}
@@ -61,6 +71,7 @@ func controller(stopCh <-chan struct{}) error {
 		},
 		Load:     rootExecuteOptions.load,
 		Recorder: mgr.GetEventRecorderFor("kdochart-controller"),
+		Options:  []kdo.ChartOption{kdo.WithSkipValidation(controllerSkipValidation)},
 	}
 	err = reconciler.SetupWithManager(mgr, options)
 	if err != nil {
@@ -81,7 +92,27 @@ func controller(stopCh <-chan struct{}) error {
 	return nil
 }
 
+// controllerPolicies returns the policies given as command line options. Rules are given as name or name=action.
+func controllerPolicies() []kdo.RepoConfig {
+	configs := make([]kdo.RepoConfig, 0)
+	for _, rule := range controllerPolicyRules {
+		parts := strings.SplitN(rule, "=", 2)
+		action := ""
+		if len(parts) == 2 {
+			action = parts[1]
+		}
+		configs = append(configs, kdo.WithPolicyRule(parts[0], action))
+	}
+	for _, file := range controllerPolicyFiles {
+		configs = append(configs, kdo.WithPolicyFile(file))
+	}
+	return configs
+}
+
 func init() {
 	controllerCmd.Flags().IntVar(&options.MaxConcurrentReconciles, "concurrent-reconciles", options.MaxConcurrentReconciles, "Number of concurrent reconciles")
 	controllerK8sArgs.AddFlags(controllerCmd.Flags())
+	controllerCmd.Flags().StringArrayVar(&controllerPolicyRules, "policy-rule", nil, "Built-in policy rule checked before charts are applied (name or name=warn)")
+	controllerCmd.Flags().StringArrayVar(&controllerPolicyFiles, "policy-file", nil, "Starlark policy checked before charts are applied")
+	controllerCmd.Flags().BoolVar(&controllerSkipValidation, "skip-validation", false, "Skip the validation of the rendered objects before charts are applied")
 }
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var depsLockOutput string
+var depsTreeOptions = &kdo.RepoListOptions{}
+var depsTreeK8sArgs = &k8s.Configs{}
+
+var depsCmd = &cobra.Command{
+	Use:   "deps",
+	Short: "manage the dependencies of a kdo chart",
+	Long:  ``,
+}
+
+var depsLockCmd = &cobra.Command{
+	Use:   "lock [chart]",
+	Short: "resolve the dependencies of a kdo chart and write " + kdo.LockFile,
+	Long:  `Selects the highest versions matching the constraints of all dependencies. The resolved urls and digests are pinned in ` + kdo.LockFile + `, which is used by kdo apply afterwards.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(depsLock(args[0], depsLockOutput))
+	},
+}
+
+var depsTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "show the installed kdo charts and their dependencies",
+	Long:  ``,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		k8s, err := newK8s(depsTreeK8sArgs.Merge())
+		if err != nil {
+			exit(err)
+		}
+		exit(depsTree(k8s, depsTreeOptions))
+	},
+}
+
+func depsTree(k k8s.K8s, listOptions *kdo.RepoListOptions) error {
+	charts, err := kdo.ListInstalled(k, listOptions)
+	if err != nil {
+		return err
+	}
+	return kdo.WriteDependencyTree(os.Stdout, charts)
+}
+
+func depsLock(url string, output string) error {
+	if output == "" {
+		info, err := os.Stat(url)
+		if err != nil || !info.IsDir() {
+			return fmt.Errorf("%s is not a local chart directory, use --output", url)
+		}
+		output = path.Join(url, kdo.LockFile)
+	}
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	c, err := repo.Get(thread, url)
+	if err != nil {
+		return err
+	}
+	// resolve again instead of using the versions pinned by an existing lock file
+	lockFile := path.Join(url, kdo.LockFile)
+	previous, err := ioutil.ReadFile(lockFile)
+	if err == nil {
+		if err := os.Remove(lockFile); err != nil {
+			return err
+		}
+	}
+	lock, err := repo.Resolve(thread, c, nil)
+	if err != nil {
+		if previous != nil {
+			ioutil.WriteFile(lockFile, previous, 0644)
+		}
+		return err
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return lock.Write(out)
+}
+
+func init() {
+	depsLockCmd.Flags().StringVarP(&depsLockOutput, "output", "o", "", "Output file (default: "+kdo.LockFile+" in the chart directory)")
+	depsTreeOptions.AddFlags(depsTreeCmd.Flags())
+	depsTreeK8sArgs.AddFlags(depsTreeCmd.Flags())
+	depsCmd.AddCommand(depsLockCmd)
+	depsCmd.AddCommand(depsTreeCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var docsFormat string
+
+var docsCmd = &cobra.Command{
+	Use:   "docs [chart]",
+	Short: "print a reference of the properties and dependencies of a kdo chart",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(docs(args[0], docsFormat))
+	},
+}
+
+func docs(url string, format string) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	c, err := repo.Get(thread, url, kdo.WithPlaceholderArgs(true))
+	if err != nil {
+		return err
+	}
+	return c.Docs(os.Stdout, format)
+}
+
+func init() {
+	docsCmd.Flags().StringVar(&docsFormat, "format", "markdown", "Output format (markdown or html)")
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var gcDryRun bool
+var gcK8sArgs = &k8s.Configs{}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "delete dependencies, which aren't used anymore",
+	Long:  `Removes references to charts, which don't exist anymore, and deletes all dependencies without references in dependency order.`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		k8s, err := newK8s(gcK8sArgs.Merge())
+		if err != nil {
+			exit(err)
+		}
+		exit(gc(k8s, gcDryRun))
+	},
+}
+
+func gc(k k8s.K8s, dryRun bool) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	plan, err := kdo.PlanGarbageCollection(thread, repo, k)
+	if err != nil {
+		return err
+	}
+	if err := plan.Write(os.Stdout); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	return plan.Run(thread, repo, k)
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Only print what would be done")
+	gcK8sArgs.AddFlags(gcCmd.Flags())
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var graphFormat string
+var graphKubeConfigs []string
+var graphK8sArgs = &k8s.Configs{}
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "show the dependency graph of all installed kdo charts",
+	Long:  `Lists the installed kdo charts of all namespaces together with their dependencies. Orphaned dependencies, declared dependencies without reference and references to charts, which don't exist, are flagged. Pass --kubeconfig multiple times to combine several clusters.`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(graph(graphFormat, graphKubeConfigs))
+	},
+}
+
+func graph(format string, kubeConfigs []string) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	g := kdo.NewGraph()
+	if len(kubeConfigs) == 0 {
+		k, err := newK8s(graphK8sArgs.Merge())
+		if err != nil {
+			return err
+		}
+		if err := g.AddCluster(thread, repo, k, ""); err != nil {
+			return err
+		}
+	}
+	for _, kubeConfig := range kubeConfigs {
+		k, err := newK8s(graphK8sArgs.Merge(), k8s.WithKubeConfig(kubeConfig))
+		if err != nil {
+			return err
+		}
+		cluster := filepath.Base(kubeConfig)
+		if err := g.AddCluster(thread, repo, k, cluster); err != nil {
+			return err
+		}
+	}
+	return g.Write(os.Stdout, format)
+}
+
+func init() {
+	graphCmd.Flags().StringVar(&graphFormat, "format", "dot", "Output format (dot, mermaid or json)")
+	graphCmd.Flags().StringArrayVar(&graphKubeConfigs, "kubeconfig", nil, "kube config of a cluster, can be passed multiple times")
+	graphK8sArgs.AddFlags(graphCmd.Flags())
+}
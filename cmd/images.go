@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/k14s/starlark-go/starlark"
+
+	"github.com/spf13/cobra"
+)
+
+var imagesChartArgs = kdo.ChartOptions{}
+var imagesK8sArgs = k8s.Configs{}
+
+var imagesCmd = &cobra.Command{
+	Use:   "images [chart]",
+	Short: "list the images used by a kdo chart",
+	Long:  `Lists all images of the workloads rendered by the chart. If an image is relocated by the image mappings of the kdo configuration, the relocated image is printed next to it.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		k8s, err := newK8s(imagesK8sArgs.Merge())
+		if err != nil {
+			exit(err)
+		}
+		exit(images(args[0], k8s))
+	},
+}
+
+func images(url string, k k8s.K8s) error {
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	c, err := repo.Get(thread, url, imagesChartArgs.Merge())
+	if err != nil {
+		return err
+	}
+	images, err := kdo.Images(thread, c, k)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if image.Relocated != image.Name {
+			fmt.Printf("%s %s\n", image.Name, image.Relocated)
+		} else {
+			fmt.Println(image.Name)
+		}
+	}
+	return nil
+}
+
+func init() {
+	imagesChartArgs.AddFlags(imagesCmd.Flags())
+	imagesK8sArgs.AddFlags(imagesCmd.Flags())
+}
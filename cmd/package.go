@@ -1,15 +1,18 @@
 package cmd
 
 import (
-	"os"
+	"bytes"
+	"io/ioutil"
 	"path"
 
 	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
 
 	"github.com/spf13/cobra"
 )
 
 var helmFormat bool
+var signKey string
 
 var packageCmd = &cobra.Command{
 	Use:   "package [chart]",
@@ -32,14 +35,32 @@ func pkg(url string) error {
 	if err != nil {
 		return err
 	}
-	out, err := os.Create(path.Base(c.GetName()) + "-" + c.GetVersion().String() + ".tgz")
+	filename := path.Base(c.GetName()) + "-" + c.GetVersion().String() + ".tgz"
+	buf := &bytes.Buffer{}
+	if err := c.Package(buf, helmFormat); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if signKey == "" {
+		return nil
+	}
+	key, err := kdo.ReadSigningKey(signKey)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-	return c.Package(out, helmFormat)
+	provenance, signature, err := kdo.SignPackage(c, buf.Bytes(), key)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename+kdo.ProvenanceSuffix, provenance, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename+kdo.SignatureSuffix, signature, 0644)
 }
 
 func init() {
 	packageCmd.Flags().BoolVar(&helmFormat, "helm", false, "package kdo chart as helm chart")
+	packageCmd.Flags().StringVar(&signKey, "sign-key", "", "sign the package with the given ed25519 private key (PEM) and write a provenance file and a signature")
 }
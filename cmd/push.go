@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/k14s/starlark-go/starlark"
+
+	"github.com/spf13/cobra"
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push [chart] [url]",
+	Short: "push kdo chart to an oci registry or a http endpoint",
+	Long: `Packages the chart and uploads it to an oci registry, e.g. oci://registry/repository[:tag]. The tag defaults to the version of the chart. Prints the reference of the chart pinned to the digest of the manifest.
+
+Charts can also be uploaded to http endpoints accepting PUT (e.g. WebDAV). Urls ending with a slash are completed with <name>-<version>.tgz. Use kdo repo index to generate the index of the uploaded charts.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(push(args[0], args[1]))
+	},
+}
+
+func push(url string, target string) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	c, err := repo.Get(thread, url)
+	if err != nil {
+		return err
+	}
+	pinned, err := repo.Push(c, target)
+	if err != nil {
+		return err
+	}
+	fmt.Println(pinned)
+	return nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var rekeyOptions = &kdo.RepoListOptions{}
+var rekeyK8sArgs = &k8s.Configs{}
+var rekeyRotate bool
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "encrypt persisted chart properties with the current encryption key",
+	Long:  ``,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		k8s, err := newK8s(rekeyK8sArgs.Merge())
+		if err != nil {
+			exit(err)
+		}
+		exit(rekey(k8s, rekeyOptions, rekeyRotate))
+	},
+}
+
+func rekey(k k8s.K8s, listOptions *kdo.RepoListOptions, rotate bool) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	return repo.Rekey(k, listOptions, rotate)
+}
+
+func init() {
+	rekeyOptions.AddFlags(rekeyCmd.Flags())
+	rekeyK8sArgs.AddFlags(rekeyCmd.Flags())
+	rekeyCmd.Flags().BoolVar(&rekeyRotate, "rotate", false, "Create a new encryption key before encrypting")
+}
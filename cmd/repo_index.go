@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
+
+	"github.com/spf13/cobra"
+)
+
+var repoIndexURL string
+
+var repoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "manage kdo chart repositories",
+	Long:  ``,
+}
+
+var repoIndexCmd = &cobra.Command{
+	Use:   "index [dir]",
+	Short: "generate the index of the packaged kdo charts in a directory",
+	Long:  `Writes ` + kdo.IndexFile + ` containing genus, version, digest, urls, deprecation and description of all packaged charts in the directory. Catalogs containing an index select the versions of charts from it.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(repoIndex(args[0], repoIndexURL))
+	},
+}
+
+func repoIndex(dir string, url string) error {
+	repo, err := repo()
+	if err != nil {
+		return err
+	}
+	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
+	index, err := kdo.IndexDir(thread, repo, dir, url)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(index.Entries))
+	for name := range index.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, entry := range index.Entries[name] {
+			fmt.Printf("%s %s\n", name, entry.Version)
+		}
+	}
+	return nil
+}
+
+func init() {
+	repoIndexCmd.Flags().StringVar(&repoIndexURL, "url", "", "base url of the packaged charts (default: relative to the index)")
+	repoCmd.AddCommand(repoIndexCmd)
+}
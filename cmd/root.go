@@ -18,6 +18,7 @@ import (
 )
 
 var repoConfigFile string
+var offline bool
 var repoConfigFileDefault string
 var rootOsbConfig = extensions.OsbConfig{}
 
@@ -35,16 +36,38 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(testCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(rekeyCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(docsCmd)
+	rootCmd.AddCommand(compatCmd)
+	rootCmd.AddCommand(depsCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(repoCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(imagesCmd)
 	rootCmd.PersistentFlags().StringVar(&repoConfigFile, "config", repoConfigFileDefault, "kdo configuration file (e.g. credentials)")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "load remote charts only from the cache")
 }
 
-func repo() (kdo.Repo, error) {
-	if repoConfigFile == repoConfigFileDefault {
-		if _, err := os.Stat(repoConfigFile); err != nil {
-			return kdo.NewRepo()
-		}
+func repoConfigs() []kdo.RepoConfig {
+	configs := make([]kdo.RepoConfig, 0)
+	if repoConfigFile != repoConfigFileDefault {
+		configs = append(configs, kdo.WithConfigFile(repoConfigFile))
+	} else if _, err := os.Stat(repoConfigFile); err == nil {
+		configs = append(configs, kdo.WithConfigFile(repoConfigFile))
+	}
+	configs = append(configs, rootExecuteOptions.repoConfigs...)
+	if offline {
+		configs = append(configs, kdo.WithOffline())
 	}
-	return kdo.NewRepo(kdo.WithConfigFile(repoConfigFile))
+	return configs
+}
+
+func repo() (kdo.Repo, error) {
+	return kdo.NewRepo(repoConfigs()...)
 }
 
 var rootCmd = &cobra.Command{
@@ -61,7 +84,8 @@ var rootExecuteOptions = ExecuteOptions{load: defaultLoad}
 
 // ExecuteOptions -
 type ExecuteOptions struct {
-	load func(thread *starlark.Thread, module string) (dict starlark.StringDict, err error)
+	load        func(thread *starlark.Thread, module string) (dict starlark.StringDict, err error)
+	repoConfigs []kdo.RepoConfig
 }
 
 func defaultLoad(thread *starlark.Thread, module string) (starlark.StringDict, error) {
@@ -133,6 +157,13 @@ func WithTestFlags(flags func(flagsSet *pflag.FlagSet)) ExecuteOption {
 	}
 }
 
+// WithRepoConfigs adds configurations to the chart repository (e.g. a kms key provider)
+func WithRepoConfigs(configs ...kdo.RepoConfig) ExecuteOption {
+	return func(e *ExecuteOptions) {
+		e.repoConfigs = append(e.repoConfigs, configs...)
+	}
+}
+
 // WithK8s overrides constructor for k8s
 func WithK8s(k func(configs ...k8s.Config) (k8s.K8s, error)) ExecuteOption {
 	return func(e *ExecuteOptions) {
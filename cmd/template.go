@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path"
+
+	"github.com/Masterminds/semver/v3"
 
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
@@ -14,6 +19,9 @@ import (
 
 var templateChartArgs = kdo.ChartOptions{}
 var templateK8sArgs = k8s.Configs{}
+var templateValidate bool
+var templateKubeVersion string
+var templateSchemaDir string
 
 var templateCmd = &cobra.Command{
 	Use:   "template [chart]",
@@ -27,10 +35,48 @@ var templateCmd = &cobra.Command{
 		if err != nil {
 			exit(err)
 		}
-		exit(template(args[0], k8s)(os.Stdout))
+		stream := template(args[0], k8s)
+		if templateValidate {
+			version, err := semver.NewVersion(templateKubeVersion)
+			if err != nil {
+				exit(fmt.Errorf("Invalid kubernetes version %s: %s", templateKubeVersion, err.Error()))
+			}
+			stream = validate(stream, version, k8s)
+		}
+		exit(stream(os.Stdout))
 	},
 }
 
+// validate returns a stream, which writes the rendered documents unchanged, if they are valid for a kubernetes version
+func validate(stream k8s.Stream, version *semver.Version, k k8s.K8s) k8s.Stream {
+	return func(w io.Writer) error {
+		buffer := &bytes.Buffer{}
+		if err := stream(buffer); err != nil {
+			return err
+		}
+		data := buffer.Bytes()
+		err := k8s.Decode(func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}).Validate(k8s.NewValidator(version, k, os.Stderr, validatorOptions()...))(func(obj *k8s.Object) error { return nil })
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+}
+
+// validatorOptions returns the options to load the schemas of kubernetes versions. They are only downloaded, if kdo
+// isn't offline.
+func validatorOptions() []k8s.ValidatorOption {
+	options := []k8s.ValidatorOption{k8s.WithSchemaDir(templateSchemaDir)}
+	if !offline {
+		options = append(options, k8s.WithSchemaURL(k8s.DefaultSchemaURL))
+	}
+	return options
+}
+
 func template(url string, k k8s.K8s) k8s.Stream {
 
 	thread := &starlark.Thread{Name: "main", Load: rootExecuteOptions.load}
@@ -50,4 +96,11 @@ func template(url string, k k8s.K8s) k8s.Stream {
 func init() {
 	templateChartArgs.AddFlags(templateCmd.Flags())
 	templateK8sArgs.AddFlags(templateCmd.Flags())
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	templateCmd.Flags().BoolVar(&templateValidate, "validate", false, "Validate the rendered objects against the schemas and the apis of --kube-version")
+	templateCmd.Flags().StringVar(&templateKubeVersion, "kube-version", "1.17", "Kubernetes version, whose schemas and apis are used for validation")
+	templateCmd.Flags().StringVar(&templateSchemaDir, "schema-dir", path.Join(homedir, ".kdo", "schemas"), "Directory with the OpenAPI schemas of kubernetes versions, e.g. v1.27.json. Missing schemas are downloaded unless --offline is set")
 }
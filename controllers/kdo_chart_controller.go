@@ -55,6 +55,8 @@ type KdoChartReconciler struct {
 	K8s      func(configs ...k8s.Config) (k8s.K8s, error)
 	Load     func(thread *starlark.Thread, module string) (dict starlark.StringDict, err error)
 	Recorder record.EventRecorder
+	// Options are passed to all charts, e.g. to skip their validation
+	Options []kdo.ChartOption
 }
 
 type kdoChartPredicate struct {
@@ -135,7 +137,7 @@ func (r *KdoChartReconciler) apply(spec *kdov1a2.ChartSpec, progressCb k8s.Progr
 		return err
 	}
 	thread := &starlark.Thread{Name: "main", Load: r.Load}
-	chart, err := r.Repo.GetFromSpec(thread, spec)
+	chart, err := r.Repo.GetFromSpec(thread, spec, r.Options...)
 	if err != nil {
 		return err
 	}
@@ -156,7 +158,7 @@ func (r *KdoChartReconciler) delete(spec *kdov1a2.ChartSpec, progressCb k8s.Prog
 		return err
 	}
 	thread := &starlark.Thread{Name: "main", Load: r.Load}
-	chart, err := r.Repo.GetFromSpec(thread, spec)
+	chart, err := r.Repo.GetFromSpec(thread, spec, r.Options...)
 	if err != nil {
 		return err
 	}
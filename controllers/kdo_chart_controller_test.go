@@ -14,6 +14,7 @@ import (
 	"time"
 
 	semver "github.com/Masterminds/semver/v3"
+	"github.com/k14s/starlark-go/starlark"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo"
 
@@ -188,6 +189,33 @@ var _ = Describe("KdoChartReconciler", func() {
 			event := <-recorder.Events
 			Expect(event).To(ContainSubstring("delete error"))
 		})
+		It("passes its options to the charts", func() {
+			chart = &kdov1a2.KdoChart{
+				Spec: kdov1a2.ChartSpec{
+					ChartTgz: chartTgz,
+				},
+			}
+			repo := &optionsRecordingRepo{Repo: reconciler.Repo}
+			reconciler.Repo = repo
+			reconciler.Options = []kdo.ChartOption{kdo.WithSkipValidation(true)}
+			_, err := reconciler.Reconcile(ctrl.Request{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.options).To(HaveLen(1))
+		})
+		It("refuses unsigned charts if verification is enforced", func() {
+			chart = &kdov1a2.KdoChart{
+				Spec: kdov1a2.ChartSpec{
+					ChartTgz: chartTgz,
+				},
+			}
+			repo, err := kdo.NewRepo(kdo.WithVerification("", kdo.VerificationEnforce))
+			Expect(err).NotTo(HaveOccurred())
+			reconciler.Repo = repo
+			_, err = reconciler.Reconcile(ctrl.Request{})
+			Expect(err).To(MatchError(ContainSubstring("chart isn't signed")))
+			Expect(chart.Status.LastOp.Type).To(Equal(applyErrorStatus))
+			Expect(k.ApplyCallCount()).To(Equal(0))
+		})
 	})
 
 	Context("Predicate", func() {
@@ -211,3 +239,13 @@ var _ = Describe("KdoChartReconciler", func() {
 	})
 
 })
+
+type optionsRecordingRepo struct {
+	kdo.Repo
+	options []kdo.ChartOption
+}
+
+func (r *optionsRecordingRepo) GetFromSpec(thread *starlark.Thread, spec *kdov1a2.ChartSpec, options ...kdo.ChartOption) (kdo.ChartValue, error) {
+	r.options = options
+	return r.Repo.GetFromSpec(thread, spec, options...)
+}
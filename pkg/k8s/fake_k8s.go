@@ -22,6 +22,18 @@ type FakeK8s struct {
 	applyReturnsOnCall map[int]struct {
 		result1 error
 	}
+	CapabilitiesStub        func() (*Capabilities, error)
+	capabilitiesMutex       sync.RWMutex
+	capabilitiesArgsForCall []struct {
+	}
+	capabilitiesReturns struct {
+		result1 *Capabilities
+		result2 error
+	}
+	capabilitiesReturnsOnCall map[int]struct {
+		result1 *Capabilities
+		result2 error
+	}
 	ConfigContentStub        func() *string
 	configContentMutex       sync.RWMutex
 	configContentArgsForCall []struct {
@@ -184,6 +196,18 @@ type FakeK8s struct {
 	namespaceReturnsOnCall map[int]struct {
 		result1 *string
 	}
+	OpenAPISchemaStub        func() ([]byte, error)
+	openAPISchemaMutex       sync.RWMutex
+	openAPISchemaArgsForCall []struct {
+	}
+	openAPISchemaReturns struct {
+		result1 []byte
+		result2 error
+	}
+	openAPISchemaReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
 	PatchStub        func(string, string, types.PatchType, string, *Options) (*Object, error)
 	patchMutex       sync.RWMutex
 	patchArgsForCall []struct {
@@ -337,6 +361,61 @@ func (fake *FakeK8s) ApplyReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeK8s) Capabilities() (*Capabilities, error) {
+	fake.capabilitiesMutex.Lock()
+	ret, specificReturn := fake.capabilitiesReturnsOnCall[len(fake.capabilitiesArgsForCall)]
+	fake.capabilitiesArgsForCall = append(fake.capabilitiesArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Capabilities", []interface{}{})
+	fake.capabilitiesMutex.Unlock()
+	if fake.CapabilitiesStub != nil {
+		return fake.CapabilitiesStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.capabilitiesReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeK8s) CapabilitiesCallCount() int {
+	fake.capabilitiesMutex.RLock()
+	defer fake.capabilitiesMutex.RUnlock()
+	return len(fake.capabilitiesArgsForCall)
+}
+
+func (fake *FakeK8s) CapabilitiesCalls(stub func() (*Capabilities, error)) {
+	fake.capabilitiesMutex.Lock()
+	defer fake.capabilitiesMutex.Unlock()
+	fake.CapabilitiesStub = stub
+}
+
+func (fake *FakeK8s) CapabilitiesReturns(result1 *Capabilities, result2 error) {
+	fake.capabilitiesMutex.Lock()
+	defer fake.capabilitiesMutex.Unlock()
+	fake.CapabilitiesStub = nil
+	fake.capabilitiesReturns = struct {
+		result1 *Capabilities
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeK8s) CapabilitiesReturnsOnCall(i int, result1 *Capabilities, result2 error) {
+	fake.capabilitiesMutex.Lock()
+	defer fake.capabilitiesMutex.Unlock()
+	fake.CapabilitiesStub = nil
+	if fake.capabilitiesReturnsOnCall == nil {
+		fake.capabilitiesReturnsOnCall = make(map[int]struct {
+			result1 *Capabilities
+			result2 error
+		})
+	}
+	fake.capabilitiesReturnsOnCall[i] = struct {
+		result1 *Capabilities
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeK8s) ConfigContent() *string {
 	fake.configContentMutex.Lock()
 	ret, specificReturn := fake.configContentReturnsOnCall[len(fake.configContentArgsForCall)]
@@ -355,6 +434,8 @@ func (fake *FakeK8s) ConfigContent() *string {
 }
 
 func (fake *FakeK8s) ConfigContentCallCount() int {
+	fake.capabilitiesMutex.RLock()
+	defer fake.capabilitiesMutex.RUnlock()
 	fake.configContentMutex.RLock()
 	defer fake.configContentMutex.RUnlock()
 	return len(fake.configContentArgsForCall)
@@ -1119,6 +1200,61 @@ func (fake *FakeK8s) NamespaceReturnsOnCall(i int, result1 *string) {
 	}{result1}
 }
 
+func (fake *FakeK8s) OpenAPISchema() ([]byte, error) {
+	fake.openAPISchemaMutex.Lock()
+	ret, specificReturn := fake.openAPISchemaReturnsOnCall[len(fake.openAPISchemaArgsForCall)]
+	fake.openAPISchemaArgsForCall = append(fake.openAPISchemaArgsForCall, struct {
+	}{})
+	fake.recordInvocation("OpenAPISchema", []interface{}{})
+	fake.openAPISchemaMutex.Unlock()
+	if fake.OpenAPISchemaStub != nil {
+		return fake.OpenAPISchemaStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.openAPISchemaReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeK8s) OpenAPISchemaCallCount() int {
+	fake.openAPISchemaMutex.RLock()
+	defer fake.openAPISchemaMutex.RUnlock()
+	return len(fake.openAPISchemaArgsForCall)
+}
+
+func (fake *FakeK8s) OpenAPISchemaCalls(stub func() ([]byte, error)) {
+	fake.openAPISchemaMutex.Lock()
+	defer fake.openAPISchemaMutex.Unlock()
+	fake.OpenAPISchemaStub = stub
+}
+
+func (fake *FakeK8s) OpenAPISchemaReturns(result1 []byte, result2 error) {
+	fake.openAPISchemaMutex.Lock()
+	defer fake.openAPISchemaMutex.Unlock()
+	fake.OpenAPISchemaStub = nil
+	fake.openAPISchemaReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeK8s) OpenAPISchemaReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.openAPISchemaMutex.Lock()
+	defer fake.openAPISchemaMutex.Unlock()
+	fake.OpenAPISchemaStub = nil
+	if fake.openAPISchemaReturnsOnCall == nil {
+		fake.openAPISchemaReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.openAPISchemaReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeK8s) Patch(arg1 string, arg2 string, arg3 types.PatchType, arg4 string, arg5 *Options) (*Object, error) {
 	fake.patchMutex.Lock()
 	ret, specificReturn := fake.patchReturnsOnCall[len(fake.patchArgsForCall)]
@@ -1578,6 +1714,8 @@ func (fake *FakeK8s) Invocations() map[string][][]interface{} {
 	defer fake.listMutex.RUnlock()
 	fake.namespaceMutex.RLock()
 	defer fake.namespaceMutex.RUnlock()
+	fake.openAPISchemaMutex.RLock()
+	defer fake.openAPISchemaMutex.RUnlock()
 	fake.patchMutex.RLock()
 	defer fake.patchMutex.RUnlock()
 	fake.progressMutex.RLock()
@@ -13,8 +13,10 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -73,6 +75,14 @@ type K8s interface {
 	Tool() Tool
 	SetTool(tool Tool)
 	Namespace(options *Options) *string
+	Capabilities() (*Capabilities, error)
+	OpenAPISchema() ([]byte, error)
+}
+
+// Capabilities - the version and the api versions served by a cluster
+type Capabilities struct {
+	KubeVersion *semver.Version
+	APIVersions []string
 }
 
 // ProgressSubscription -
@@ -161,6 +171,11 @@ func WithVerbose(value int) Config {
 	return func(options *Configs) error { options.verbose = value; return nil }
 }
 
+// WithKubeConfig -
+func WithKubeConfig(file string) Config {
+	return func(options *Configs) error { options.kubeConfig = file; return nil }
+}
+
 // WithKubeConfigContent -
 func WithKubeConfigContent(value string) Config {
 	if value == "" {
@@ -227,6 +242,7 @@ func (k *k8sImpl) connect() (K8s, error) {
 	if err != nil {
 		return nil, err
 	}
+	k.discovery = &discovery{}
 	if len(config.Host) != 0 {
 		u, err := url.Parse(config.Host)
 		if err != nil {
@@ -249,10 +265,21 @@ type k8sImpl struct {
 	app              string
 	version          *semver.Version
 	client           *k8sClient
+	discovery        *discovery
 	host             string
 	ctx              context.Context
 }
 
+// discovery caches the capabilities and the OpenAPI schema of a cluster
+type discovery struct {
+	once         sync.Once
+	capabilities *Capabilities
+	err          error
+	schemaOnce   sync.Once
+	schema       []byte
+	schemaErr    error
+}
+
 var (
 	_ K8s = (*k8sImpl)(nil)
 )
@@ -278,6 +305,9 @@ func (k *k8sImpl) progressCb(matched int, count int) {
 
 // Apply -
 func (k *k8sImpl) Apply(output ObjectStream, options *Options) (err error) {
+	sources := objectSources{}
+	output = output.Map(sources.record)
+	defer func() { err = sources.wrap(err) }()
 	if k.tool == ToolKapp {
 		writer, stream := prepareKapp(output, false, k.objMapper(), k.progressCb)
 		err = runWithStdin(k.kapp("deploy", options, "-f", "-"), stream, writer, k.verbose)
@@ -309,7 +339,7 @@ func (k *k8sImpl) addProgressSubscription() ProgressSubscription {
 }
 
 func (k *k8sImpl) clone() *k8sImpl {
-	return &k8sImpl{namespace: k.namespace, app: k.app, version: k.version, client: k.client, host: k.host, ctx: k.ctx,
+	return &k8sImpl{namespace: k.namespace, app: k.app, version: k.version, client: k.client, discovery: k.discovery, host: k.host, ctx: k.ctx,
 		Configs: Configs{
 			progressSubscription: k.addProgressSubscription(),
 			kubeConfig:           k.kubeConfig,
@@ -347,6 +377,34 @@ func (k *k8sImpl) Delete(output ObjectStream, options *Options) (err error) {
 	return err
 }
 
+// objectSources remembers the template sources of objects by name to point to them in errors
+type objectSources map[string][]string
+
+func (s objectSources) record(obj *Object) *Object {
+	if source := obj.Source(); source != "" {
+		s[obj.MetaData.Name] = append(s[obj.MetaData.Name], fmt.Sprintf("%s %s in %s", obj.Kind, obj.MetaData.Name, source))
+	}
+	return obj
+}
+
+// wrap adds the sources of the objects mentioned in the error message
+func (s objectSources) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	hints := []string{}
+	for name, sources := range s {
+		if strings.Contains(err.Error(), `"`+name+`"`) {
+			hints = append(hints, sources...)
+		}
+	}
+	if len(hints) == 0 {
+		return err
+	}
+	sort.Strings(hints)
+	return errors.Wrapf(err, "Rendered from %s", strings.Join(hints, ", "))
+}
+
 var invalidValueRegex = regexp.MustCompile("[^a-zA-Z0-9\\-_\\.]")
 
 // FixLabelValue -
@@ -605,6 +663,34 @@ func (k *k8sImpl) Namespace(options *Options) *string {
 	return &namespace
 }
 
+// Capabilities - discovers the version and api versions of the cluster. Returns nil, if kdo isn't connected.
+func (k *k8sImpl) Capabilities() (*Capabilities, error) {
+	if k.client == nil {
+		return nil, nil
+	}
+	if k.discovery == nil {
+		return k.client.capabilities()
+	}
+	k.discovery.once.Do(func() {
+		k.discovery.capabilities, k.discovery.err = k.client.capabilities()
+	})
+	return k.discovery.capabilities, k.discovery.err
+}
+
+// OpenAPISchema - reads the OpenAPI v2 schema served by the cluster. Returns nil, if kdo isn't connected.
+func (k *k8sImpl) OpenAPISchema() ([]byte, error) {
+	if k.client == nil {
+		return nil, nil
+	}
+	if k.discovery == nil {
+		return k.client.openAPISchema()
+	}
+	k.discovery.schemaOnce.Do(func() {
+		k.discovery.schema, k.discovery.schemaErr = k.client.openAPISchema()
+	})
+	return k.discovery.schema, k.discovery.schemaErr
+}
+
 func (k *k8sImpl) kubectl(command string, options *Options, flags ...string) *exec.Cmd {
 	if len(k.kubeConfig) != 0 {
 		flags = append([]string{command, "--kubeconfig", k.kubeConfig}, flags...)
@@ -8,7 +8,9 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"k8s.io/apimachinery/pkg/types"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -175,6 +177,76 @@ func (r result) Error() error {
 	return r.Result.Error()
 }
 
+// capabilities reads the server version and the api versions of all groups
+func (k *k8sClient) capabilities() (*Capabilities, error) {
+	var info struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	if err := k.getJSON(&info, "version"); err != nil {
+		return nil, err
+	}
+	version, err := semver.NewVersion(info.GitVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid server version %s: %s", info.GitVersion, err.Error())
+	}
+	var core struct {
+		Versions []string `json:"versions"`
+	}
+	if err := k.getJSON(&core, "api"); err != nil {
+		return nil, err
+	}
+	var groups struct {
+		Groups []struct {
+			Versions []struct {
+				GroupVersion string `json:"groupVersion"`
+			} `json:"versions"`
+		} `json:"groups"`
+	}
+	if err := k.getJSON(&groups, "apis"); err != nil {
+		return nil, err
+	}
+	apiVersions := core.Versions
+	for _, group := range groups.Groups {
+		for _, v := range group.Versions {
+			apiVersions = append(apiVersions, v.GroupVersion)
+		}
+	}
+	sort.Strings(apiVersions)
+	return &Capabilities{KubeVersion: version, APIVersions: apiVersions}, nil
+}
+
+// openAPISchema reads the OpenAPI v2 schema of all types served by the cluster
+func (k *k8sClient) openAPISchema() ([]byte, error) {
+	return k.client.Get().AbsPath("openapi/v2").Timeout(discoveryTimeout).Do().Raw()
+}
+
+// discoveryTimeout - the time kdo waits for the version and api versions of the cluster
+var discoveryTimeout = 10 * time.Second
+
+func (k *k8sClient) getJSON(v interface{}, path string) error {
+	data, err := k.client.Get().AbsPath(path).Timeout(discoveryTimeout).Do().Raw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// KnownAPIVersions - the api versions known to kdo, which are assumed, if the cluster isn't known
+func KnownAPIVersions() []string {
+	versions := map[string]bool{}
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		versions[gvk.GroupVersion().String()] = true
+	}
+	result := make([]string, 0, len(versions))
+	for v := range versions {
+		if !strings.HasSuffix(v, "/__internal") && v != "__internal" {
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 func homeDir() string {
 	if h := os.Getenv("HOME"); h != "" {
 		return h
@@ -1,8 +1,12 @@
 package k8s
 
 import (
+	"net/http"
+	"net/http/httptest"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
 )
 
 var _ = Describe("k8s client", func() {
@@ -50,4 +54,37 @@ var _ = Describe("k8s client", func() {
 		Expect(obj.Kind).To(Equal("Deployment"))
 	})
 
+	It("discovers capabilities", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/version":
+				w.Write([]byte(`{"gitVersion":"v1.21.3-gke.100"}`))
+			case "/api":
+				w.Write([]byte(`{"versions":["v1"]}`))
+			case "/apis":
+				w.Write([]byte(`{"groups":[{"name":"apps","versions":[{"groupVersion":"apps/v1"}]},{"name":"batch","versions":[{"groupVersion":"batch/v1"},{"groupVersion":"batch/v1beta1"}]}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		client, err := newK8sClient(&rest.Config{Host: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		k := &k8sImpl{client: client, discovery: &discovery{}}
+		capabilities, err := k.Capabilities()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capabilities.KubeVersion.Original()).To(Equal("v1.21.3-gke.100"))
+		Expect(capabilities.APIVersions).To(Equal([]string{"apps/v1", "batch/v1", "batch/v1beta1", "v1"}))
+		server.Close()
+		capabilities, err = k.Capabilities()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capabilities.KubeVersion.Minor()).To(Equal(uint64(21)))
+	})
+
+	It("knows api versions of kubernetes", func() {
+		Expect(KnownAPIVersions()).To(ContainElement("apps/v1"))
+		Expect(KnownAPIVersions()).To(ContainElement("v1"))
+		Expect(KnownAPIVersions()).NotTo(ContainElement(ContainSubstring("__internal")))
+	})
 })
@@ -4,18 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 )
 
 // K8sInMemory in memory implementation of K8s
 type K8sInMemory struct {
-	namespace string
-	objects   map[string]Object
+	namespace     string
+	objects       map[string]Object
+	capabilities  *Capabilities
+	openAPISchema []byte
 }
 
 type notFoundError string
@@ -47,12 +51,12 @@ func (k K8sInMemory) Host() string {
 
 // ForSubChart -
 func (k K8sInMemory) ForSubChart(namespace string, app string, version *semver.Version, children int) K8s {
-	return &K8sInMemory{namespace: namespace, objects: k.objects}
+	return &K8sInMemory{namespace: namespace, objects: k.objects, capabilities: k.capabilities, openAPISchema: k.openAPISchema}
 }
 
 // WithContext -
 func (k K8sInMemory) WithContext(ctx context.Context) K8s {
-	return &K8sInMemory{namespace: k.namespace, objects: k.objects}
+	return &K8sInMemory{namespace: k.namespace, objects: k.objects, capabilities: k.capabilities, openAPISchema: k.openAPISchema}
 }
 
 // Inspect -
@@ -103,7 +107,7 @@ func (k K8sInMemory) DeleteObject(kind string, name string, options *Options) er
 // Apply -
 func (k K8sInMemory) Apply(output ObjectStream, options *Options) error {
 	return output(func(obj *Object) error {
-		k.objects[k.key(obj.Kind, obj.MetaData.Name, obj.MetaData.Namespace, options)] = *obj
+		k.objects[k.key(obj.Kind, obj.MetaData.Name, obj.MetaData.Namespace, options)] = *obj.WithoutSource()
 		return nil
 	})
 }
@@ -153,7 +157,34 @@ func (k K8sInMemory) Patch(kind string, name string, pt types.PatchType, patchJS
 
 // List -
 func (k K8sInMemory) List(kind string, options *Options, listOptions *ListOptions) (*Object, error) {
-	return nil, errors.New("Not implemented")
+	kind = strings.TrimSuffix(strings.ToLower(kind), "s")
+	namespace := k.namespace
+	if options != nil && options.Namespace != "" {
+		namespace = options.Namespace
+	}
+	keys := make([]string, 0)
+	for key, obj := range k.objects {
+		if strings.ToLower(obj.Kind) != kind {
+			continue
+		}
+		if isNameSpaced(kind) && (listOptions == nil || !listOptions.AllNamespaces) && !strings.HasPrefix(key, namespace+"/") {
+			continue
+		}
+		if listOptions != nil && listOptions.LabelSelector != nil && !listOptions.LabelSelector.Matches(labels.Set(obj.MetaData.Labels)) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]Object, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, k.objects[key])
+	}
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{APIVersion: "v1", Kind: "List", Additional: map[string]json.RawMessage{"items": itemsJSON}}, nil
 }
 
 // IsNotExist -
@@ -231,3 +262,23 @@ func (k K8sInMemory) Progress(progress int) {
 func (k K8sInMemory) Namespace(options *Options) *string {
 	return &options.Namespace
 }
+
+// Capabilities - returns the capabilities set by SetCapabilities or nil
+func (k K8sInMemory) Capabilities() (*Capabilities, error) {
+	return k.capabilities, nil
+}
+
+// SetCapabilities -
+func (k *K8sInMemory) SetCapabilities(capabilities *Capabilities) {
+	k.capabilities = capabilities
+}
+
+// OpenAPISchema - returns the schema set by SetOpenAPISchema or nil
+func (k K8sInMemory) OpenAPISchema() ([]byte, error) {
+	return k.openAPISchema, nil
+}
+
+// SetOpenAPISchema -
+func (k *K8sInMemory) SetOpenAPISchema(schema []byte) {
+	k.openAPISchema = schema
+}
@@ -2,7 +2,10 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/Masterminds/semver/v3"
@@ -76,6 +79,31 @@ var _ = Describe("k8s_in_memory", func() {
 		Expect(obj.MetaData.Annotations).NotTo(HaveKey("test"))
 
 	})
+	It("list works", func() {
+		other := Object{Kind: "Secret", MetaData: MetaData{Name: "other", Namespace: namespace, Labels: map[string]string{"label": "other"}}}
+		foreign := Object{Kind: "Secret", MetaData: MetaData{Name: "foreign", Namespace: "foreign"}}
+		k8s = NewK8sInMemory(namespace, secret, other, foreign)
+		requirement, err := labels.NewRequirement("label", selection.Equals, []string{"other"})
+		Expect(err).NotTo(HaveOccurred())
+		for _, tc := range []struct {
+			listOptions *ListOptions
+			names       []string
+		}{
+			{&ListOptions{}, []string{"other", "test"}},
+			{&ListOptions{AllNamespaces: true}, []string{"foreign", "other", "test"}},
+			{&ListOptions{LabelSelector: labels.NewSelector().Add(*requirement)}, []string{"other"}},
+		} {
+			obj, err := k8s.List("secrets", &Options{}, tc.listOptions)
+			Expect(err).NotTo(HaveOccurred())
+			var items []Object
+			Expect(json.Unmarshal(obj.Additional["items"], &items)).To(Succeed())
+			names := []string{}
+			for _, item := range items {
+				names = append(names, item.MetaData.Name)
+			}
+			Expect(names).To(Equal(tc.names))
+		}
+	})
 	It("ConfigContent works", func() {
 		dir := NewTestDir()
 		defer dir.Remove()
@@ -22,6 +22,46 @@ type Object struct {
 	Additional map[string]json.RawMessage `json:",inline"`
 }
 
+// SourceAnnotation - the template file and line an object was rendered from. It's set by Decode and removed by Encode,
+// so that it's never applied.
+const SourceAnnotation = "kdo.sap.github.com/source"
+
+// Source - returns the template file and line the object was rendered from or an empty string
+func (o *Object) Source() string {
+	return o.MetaData.Annotations[SourceAnnotation]
+}
+
+// Describe - returns the kind and name of the object preceded by its source, if it's known, e.g. for error messages
+func (o *Object) Describe() string {
+	result := o.Kind + " " + o.MetaData.Name
+	if source := o.Source(); source != "" {
+		result = source + " " + result
+	}
+	return result
+}
+
+// WithoutSource - returns a copy of the object without the source annotation
+func (o *Object) WithoutSource() *Object {
+	if _, ok := o.MetaData.Annotations[SourceAnnotation]; !ok {
+		return o
+	}
+	result := *o
+	result.MetaData.Annotations = map[string]string{}
+	for k, v := range o.MetaData.Annotations {
+		if k != SourceAnnotation {
+			result.MetaData.Annotations[k] = v
+		}
+	}
+	return &result
+}
+
+func (o *Object) setSource(source string) {
+	if o.MetaData.Annotations == nil {
+		o.MetaData.Annotations = map[string]string{}
+	}
+	o.MetaData.Annotations[SourceAnnotation] = source
+}
+
 // MarshalJSON -
 func (m MetaData) MarshalJSON() ([]byte, error) {
 	r := map[string]json.RawMessage{}
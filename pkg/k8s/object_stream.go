@@ -1,14 +1,20 @@
 package k8s
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"sort"
+	"strings"
+
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/renderer"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	k8sjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	k8syaml "sigs.k8s.io/yaml"
 )
 
 // ObjectConsumer -
@@ -71,7 +77,10 @@ func (o ObjectStream) Encode() Stream {
 		enc := json.NewEncoder(w)
 		return o(func(obj *Object) error {
 			w.Write([]byte("\n---\n"))
-			return enc.Encode(obj)
+			if source := obj.Source(); source != "" {
+				fmt.Fprintf(w, "%s%s\n", renderer.SourceComment, source)
+			}
+			return enc.Encode(obj.WithoutSource())
 		})
 	}
 }
@@ -114,7 +123,8 @@ func (o ObjectStream) Filter(filter func(obj *Object) bool) ObjectStream {
 	}
 }
 
-// Decode -
+// Decode - decodes the yaml documents of a stream. The source comments written by the renderer are added as
+// annotation to the objects and prefixed to decode errors.
 func Decode(in Stream) ObjectStream {
 	return func(w ObjectConsumer) error {
 		buffer := &bytes.Buffer{}
@@ -125,16 +135,27 @@ func Decode(in Stream) ObjectStream {
 		if buffer.Len() == 0 {
 			return nil
 		}
-		dec := yaml.NewYAMLToJSONDecoder(buffer)
+		reader := yaml.NewYAMLReader(bufio.NewReader(buffer))
 		for {
-			var doc Object
-			if err = dec.Decode(&doc); err != nil {
+			data, err := reader.Read()
+			if err != nil {
 				if err == io.EOF {
 					return nil
 				}
 				return err
 			}
+			source := sourceComment(data)
+			var doc Object
+			if err = k8syaml.Unmarshal(data, &doc); err != nil {
+				if source != "" {
+					return fmt.Errorf("%s: %s", source, err.Error())
+				}
+				return err
+			}
 			if !(doc.Kind == "" && len(doc.Additional) == 0 && doc.MetaData.Name == "") {
+				if source != "" {
+					doc.setSource(source)
+				}
 				err := w(&doc)
 				if err != nil {
 					return err
@@ -144,6 +165,20 @@ func Decode(in Stream) ObjectStream {
 	}
 }
 
+// sourceComment returns the source of the comments in front of a yaml document
+func sourceComment(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, renderer.SourceComment) {
+			return strings.TrimPrefix(line, renderer.SourceComment)
+		}
+		if line != "" && line != "---" && !strings.HasPrefix(line, "#") {
+			return ""
+		}
+	}
+	return ""
+}
+
 func concat(streams ...ObjectStream) ObjectStream {
 	return func(w ObjectConsumer) error {
 		for _, s := range streams {
@@ -1,6 +1,10 @@
 package k8s
 
 import (
+	"bytes"
+	"errors"
+	"io"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -30,6 +34,39 @@ var _ = Describe("k8s", func() {
 				Expect(kind).To(Equal("xxx"))
 			}
 		})
+		It("keeps the sources of rendered documents", func() {
+			in := func(w io.Writer) error {
+				_, err := io.WriteString(w, "---\n# Source: templates/config.yaml:3\nkind: ConfigMap\nmetadata:\n  name: config\n---\nkind: Secret\n")
+				return err
+			}
+			objects := []*Object{}
+			Expect(Decode(in)(func(obj *Object) error { objects = append(objects, obj); return nil })).To(Succeed())
+			Expect(objects).To(HaveLen(2))
+			Expect(objects[0].Source()).To(Equal("templates/config.yaml:3"))
+			Expect(objects[1].Source()).To(BeEmpty())
+
+			buf := &bytes.Buffer{}
+			Expect(Decode(in).Encode()(buf)).To(Succeed())
+			Expect(buf.String()).To(ContainSubstring("# Source: templates/config.yaml:3\n"))
+			Expect(buf.String()).NotTo(ContainSubstring(SourceAnnotation))
+		})
+		It("reports the sources of invalid documents", func() {
+			in := func(w io.Writer) error {
+				_, err := io.WriteString(w, "---\n# Source: templates/deployment.yaml:42\nkind: [\n")
+				return err
+			}
+			err := Decode(in)(func(obj *Object) error { return nil })
+			Expect(err).To(MatchError(HavePrefix("templates/deployment.yaml:42: ")))
+		})
+		It("points to the sources of objects in errors", func() {
+			sources := objectSources{}
+			sources.record(&Object{Kind: "Deployment", MetaData: MetaData{Name: "web", Annotations: map[string]string{SourceAnnotation: "templates/web.yaml:1"}}})
+			sources.record(&Object{Kind: "Service", MetaData: MetaData{Name: "db"}})
+			err := sources.wrap(errors.New(`The Deployment "web" is invalid`))
+			Expect(err).To(MatchError(`Rendered from Deployment web in templates/web.yaml:1: The Deployment "web" is invalid`))
+			Expect(sources.wrap(errors.New(`The Service "db" is invalid`))).To(MatchError(`The Service "db" is invalid`))
+			Expect(sources.wrap(nil)).To(Succeed())
+		})
 		It("buffers", func() {
 			stream := ObjectStream(func(w ObjectConsumer) error {
 				w(&Object{Kind: "test"})
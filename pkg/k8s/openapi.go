@@ -0,0 +1,199 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// DefaultSchemaURL - the url of the OpenAPI v2 schemas of a kubernetes version. The placeholders are the major and the
+// minor version.
+const DefaultSchemaURL = "https://raw.githubusercontent.com/kubernetes/kubernetes/v%d.%d.0/api/openapi-spec/swagger.json"
+
+// schemaDownloadTimeout - the time kdo waits for the download of the schemas of a kubernetes version
+var schemaDownloadTimeout = 60 * time.Second
+
+// quantityDefinition - the name of resource.Quantity, which is declared as string, but accepts numbers, too
+const quantityDefinition = "io.k8s.apimachinery.pkg.api.resource.Quantity"
+
+// parseOpenAPISchemas returns the schemas of the kinds of an OpenAPI v2 document by api version and kind, e.g.
+// apps/v1/Deployment
+func parseOpenAPISchemas(data []byte) (map[string]*openAPISchema, error) {
+	var document struct {
+		Definitions map[string]json.RawMessage `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("Invalid OpenAPI schema: %s", err.Error())
+	}
+	if len(document.Definitions) == 0 {
+		return nil, fmt.Errorf("Invalid OpenAPI schema: no definitions found")
+	}
+	definitions := map[string]*openAPISchema{}
+	result := map[string]*openAPISchema{}
+	for name, raw := range document.Definitions {
+		var definition struct {
+			GroupVersionKinds []struct {
+				Group   string `json:"group"`
+				Version string `json:"version"`
+				Kind    string `json:"kind"`
+			} `json:"x-kubernetes-group-version-kind"`
+		}
+		s := &openAPISchema{}
+		if err := json.Unmarshal(raw, s); err != nil {
+			return nil, fmt.Errorf("Invalid OpenAPI schema %s: %s", name, err.Error())
+		}
+		if err := json.Unmarshal(raw, &definition); err != nil {
+			return nil, fmt.Errorf("Invalid OpenAPI schema %s: %s", name, err.Error())
+		}
+		if name == quantityDefinition || s.Format == "int-or-string" {
+			s = &openAPISchema{IntOrString: true}
+		}
+		definitions[name] = s
+		for _, gvk := range definition.GroupVersionKinds {
+			apiVersion := gvk.Version
+			if gvk.Group != "" {
+				apiVersion = gvk.Group + "/" + gvk.Version
+			}
+			result[apiVersion+"/"+gvk.Kind] = s
+		}
+	}
+	resolved := map[*openAPISchema]bool{}
+	for _, s := range definitions {
+		s.resolve(definitions, resolved)
+	}
+	return result, nil
+}
+
+// resolve replaces the references of the schema and its children by the referenced definitions and merges allOf
+func (s *openAPISchema) resolve(definitions map[string]*openAPISchema, resolved map[*openAPISchema]bool) {
+	if resolved[s] {
+		return
+	}
+	resolved[s] = true
+	ref := func(child *openAPISchema) *openAPISchema {
+		if child == nil {
+			return nil
+		}
+		if child.Ref != "" {
+			target, ok := definitions[strings.TrimPrefix(child.Ref, "#/definitions/")]
+			if !ok {
+				return anySchema
+			}
+			child = target
+		}
+		child.resolve(definitions, resolved)
+		return child
+	}
+	for name, property := range s.Properties {
+		s.Properties[name] = ref(property)
+	}
+	s.Items = ref(s.Items)
+	if s.AdditionalProperties != nil {
+		s.AdditionalProperties.schema = ref(s.AdditionalProperties.schema)
+	}
+	for _, a := range s.AllOf {
+		a = ref(a)
+		if s.Type == "" {
+			s.Type = a.Type
+		}
+		if s.Items == nil {
+			s.Items = a.Items
+		}
+		if s.AdditionalProperties == nil {
+			s.AdditionalProperties = a.AdditionalProperties
+		}
+		if len(a.Properties) != 0 && s.Properties == nil {
+			s.Properties = map[string]*openAPISchema{}
+		}
+		for name, property := range a.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = property
+			}
+		}
+		s.Required = append(s.Required, a.Required...)
+		s.Enum = append(s.Enum, a.Enum...)
+		s.IntOrString = s.IntOrString || a.IntOrString
+		s.PreserveUnknownFields = s.PreserveUnknownFields || a.PreserveUnknownFields
+	}
+	s.AllOf = nil
+}
+
+// sameMinor returns true, if both versions have the same major and minor version
+func sameMinor(a *semver.Version, b *semver.Version) bool {
+	return a != nil && b != nil && a.Major() == b.Major() && a.Minor() == b.Minor()
+}
+
+// loadSchemas returns the schemas of the kinds of the kubernetes version of the validator. They are read from the
+// cluster, if it has this version, or from the schema dir. Missing schemas are downloaded into the schema dir first.
+// Returns nil, if there are no schemas of this version.
+func (v *Validator) loadSchemas() (map[string]*openAPISchema, error) {
+	if v.k != nil {
+		if c, err := v.k.Capabilities(); err == nil && c != nil && sameMinor(c.KubeVersion, v.kubeVersion) {
+			if data, err := v.k.OpenAPISchema(); err == nil && data != nil {
+				return parseOpenAPISchemas(data)
+			}
+		}
+	}
+	if v.schemaDir == "" {
+		return nil, nil
+	}
+	file := path.Join(v.schemaDir, fmt.Sprintf("v%d.%d.json", v.kubeVersion.Major(), v.kubeVersion.Minor()))
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		return parseOpenAPISchemas(data)
+	}
+	if !os.IsNotExist(err) || v.schemaURL == "" {
+		return nil, err
+	}
+	url := fmt.Sprintf(v.schemaURL, v.kubeVersion.Major(), v.kubeVersion.Minor())
+	data, err = download(url)
+	if err != nil {
+		return nil, fmt.Errorf("Download of %s failed: %s", url, err.Error())
+	}
+	schemas, err := parseOpenAPISchemas(data)
+	if err != nil {
+		return nil, fmt.Errorf("Download of %s failed: %s", url, err.Error())
+	}
+	return schemas, writeFileAtomic(file, data)
+}
+
+func download(url string) ([]byte, error) {
+	client := &http.Client{Timeout: schemaDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
+
+// writeFileAtomic writes data to a temporary file first and renames it, so readers never see partial content
+func writeFileAtomic(file string, data []byte) error {
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(path.Dir(file), ".download-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,570 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// schemaKubeVersion - the kubernetes version of the api types compiled into kdo, which are used as schemas
+var schemaKubeVersion = semver.MustParse("1.17.0")
+
+// apiLifecycle - the kubernetes versions, in which an api version of a kind was introduced or removed. An empty kind
+// matches all kinds of the api version.
+type apiLifecycle struct {
+	apiVersion  string
+	kind        string
+	introduced  string
+	removed     string
+	replacement string
+}
+
+var apiLifecycles = []apiLifecycle{
+	{apiVersion: "extensions/v1beta1", kind: "Deployment", removed: "1.16", replacement: "apps/v1"},
+	{apiVersion: "extensions/v1beta1", kind: "DaemonSet", removed: "1.16", replacement: "apps/v1"},
+	{apiVersion: "extensions/v1beta1", kind: "ReplicaSet", removed: "1.16", replacement: "apps/v1"},
+	{apiVersion: "extensions/v1beta1", kind: "NetworkPolicy", removed: "1.16", replacement: "networking.k8s.io/v1"},
+	{apiVersion: "extensions/v1beta1", kind: "PodSecurityPolicy", removed: "1.16", replacement: "policy/v1beta1"},
+	{apiVersion: "extensions/v1beta1", kind: "Ingress", removed: "1.22", replacement: "networking.k8s.io/v1"},
+	{apiVersion: "apps/v1beta1", removed: "1.16", replacement: "apps/v1"},
+	{apiVersion: "apps/v1beta2", removed: "1.16", replacement: "apps/v1"},
+	{apiVersion: "admissionregistration.k8s.io/v1beta1", removed: "1.22", replacement: "admissionregistration.k8s.io/v1"},
+	{apiVersion: "apiextensions.k8s.io/v1beta1", removed: "1.22", replacement: "apiextensions.k8s.io/v1"},
+	{apiVersion: "apiregistration.k8s.io/v1beta1", removed: "1.22", replacement: "apiregistration.k8s.io/v1"},
+	{apiVersion: "authentication.k8s.io/v1beta1", removed: "1.22", replacement: "authentication.k8s.io/v1"},
+	{apiVersion: "authorization.k8s.io/v1beta1", removed: "1.22", replacement: "authorization.k8s.io/v1"},
+	{apiVersion: "certificates.k8s.io/v1beta1", removed: "1.22", replacement: "certificates.k8s.io/v1"},
+	{apiVersion: "coordination.k8s.io/v1beta1", removed: "1.22", replacement: "coordination.k8s.io/v1"},
+	{apiVersion: "networking.k8s.io/v1beta1", removed: "1.22", replacement: "networking.k8s.io/v1"},
+	{apiVersion: "rbac.authorization.k8s.io/v1alpha1", removed: "1.22", replacement: "rbac.authorization.k8s.io/v1"},
+	{apiVersion: "rbac.authorization.k8s.io/v1beta1", removed: "1.22", replacement: "rbac.authorization.k8s.io/v1"},
+	{apiVersion: "scheduling.k8s.io/v1beta1", removed: "1.22", replacement: "scheduling.k8s.io/v1"},
+	{apiVersion: "storage.k8s.io/v1beta1", kind: "CSIDriver", removed: "1.22", replacement: "storage.k8s.io/v1"},
+	{apiVersion: "storage.k8s.io/v1beta1", kind: "CSINode", removed: "1.22", replacement: "storage.k8s.io/v1"},
+	{apiVersion: "storage.k8s.io/v1beta1", kind: "StorageClass", removed: "1.22", replacement: "storage.k8s.io/v1"},
+	{apiVersion: "storage.k8s.io/v1beta1", kind: "VolumeAttachment", removed: "1.22", replacement: "storage.k8s.io/v1"},
+	{apiVersion: "storage.k8s.io/v1beta1", kind: "CSIStorageCapacity", removed: "1.27", replacement: "storage.k8s.io/v1"},
+	{apiVersion: "batch/v1beta1", removed: "1.25", replacement: "batch/v1"},
+	{apiVersion: "discovery.k8s.io/v1beta1", removed: "1.25", replacement: "discovery.k8s.io/v1"},
+	{apiVersion: "events.k8s.io/v1beta1", removed: "1.25", replacement: "events.k8s.io/v1"},
+	{apiVersion: "autoscaling/v2beta1", removed: "1.25", replacement: "autoscaling/v2"},
+	{apiVersion: "autoscaling/v2beta2", removed: "1.26", replacement: "autoscaling/v2"},
+	{apiVersion: "policy/v1beta1", kind: "PodDisruptionBudget", removed: "1.25", replacement: "policy/v1"},
+	{apiVersion: "policy/v1beta1", kind: "PodSecurityPolicy", removed: "1.25"},
+	{apiVersion: "node.k8s.io/v1beta1", removed: "1.25", replacement: "node.k8s.io/v1"},
+	{apiVersion: "flowcontrol.apiserver.k8s.io/v1beta1", removed: "1.26", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{apiVersion: "flowcontrol.apiserver.k8s.io/v1beta2", removed: "1.29", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{apiVersion: "apiextensions.k8s.io/v1", introduced: "1.16"},
+	{apiVersion: "admissionregistration.k8s.io/v1", introduced: "1.16"},
+	{apiVersion: "networking.k8s.io/v1", kind: "Ingress", introduced: "1.19"},
+	{apiVersion: "networking.k8s.io/v1", kind: "IngressClass", introduced: "1.19"},
+	{apiVersion: "certificates.k8s.io/v1", introduced: "1.19"},
+	{apiVersion: "events.k8s.io/v1", introduced: "1.19"},
+	{apiVersion: "node.k8s.io/v1", introduced: "1.20"},
+	{apiVersion: "batch/v1", kind: "CronJob", introduced: "1.21"},
+	{apiVersion: "policy/v1", introduced: "1.21"},
+	{apiVersion: "discovery.k8s.io/v1", introduced: "1.21"},
+	{apiVersion: "autoscaling/v2", introduced: "1.23"},
+	{apiVersion: "flowcontrol.apiserver.k8s.io/v1", introduced: "1.29"},
+}
+
+// ValidationError - the problems found by validating objects
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("Validation of %d object(s) failed:\n  %s", len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// Validator checks objects against the OpenAPI schemas of a kubernetes version and the schemas of custom resource
+// definitions
+type Validator struct {
+	kubeVersion *semver.Version
+	k           K8s
+	warnings    io.Writer
+	schemaDir   string
+	schemaURL   string
+	schemas     map[string]*openAPISchema
+	loaded      bool
+	types       map[reflect.Type]*openAPISchema
+	crds        map[string]*openAPISchema
+	clusterCRDs bool
+}
+
+// ValidatorOption - option of NewValidator
+type ValidatorOption func(v *Validator)
+
+// WithSchemaDir - the directory containing the OpenAPI v2 schemas of kubernetes versions, e.g. v1.27.json
+func WithSchemaDir(dir string) ValidatorOption {
+	return func(v *Validator) { v.schemaDir = dir }
+}
+
+// WithSchemaURL - the url, from which missing schemas are downloaded into the schema dir, see DefaultSchemaURL
+func WithSchemaURL(url string) ValidatorOption {
+	return func(v *Validator) { v.schemaURL = url }
+}
+
+// NewValidator - creates a validator for a kubernetes version. The version selects the api versions, which are
+// available, and the OpenAPI schemas the fields are checked against. The schemas are read from k, if the cluster has
+// this version, or from the schema dir. Without schemas, the api types of kubernetes 1.17 compiled into kdo are used.
+// Custom resource definitions are read from k, if they aren't part of the validated objects. Problems, which don't
+// prevent an apply, are written to warnings.
+func NewValidator(kubeVersion *semver.Version, k K8s, warnings io.Writer, options ...ValidatorOption) *Validator {
+	v := &Validator{
+		kubeVersion: kubeVersion,
+		k:           k,
+		warnings:    warnings,
+		types:       map[reflect.Type]*openAPISchema{},
+		crds:        map[string]*openAPISchema{},
+	}
+	for _, option := range options {
+		option(v)
+	}
+	return v
+}
+
+// Validate - returns a stream of the objects of o, which fails with all problems found, if one of them is invalid.
+// Custom resource definitions contained in the stream are used to validate custom resources.
+func (o ObjectStream) Validate(v *Validator) ObjectStream {
+	return func(w ObjectConsumer) error {
+		var objects []*Object
+		err := o(func(obj *Object) error {
+			objects = append(objects, obj)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		errors := []string{}
+		for _, obj := range objects {
+			if err := v.AddCustomResourceDefinition(obj); err != nil {
+				errors = append(errors, obj.Describe()+": "+err.Error())
+			}
+		}
+		for _, obj := range objects {
+			objErrors, warnings := v.validate(obj)
+			errors = append(errors, objErrors...)
+			if v.warnings != nil {
+				for _, warning := range warnings {
+					fmt.Fprintf(v.warnings, "Warning: %s\n", warning)
+				}
+			}
+		}
+		if len(errors) != 0 {
+			return &ValidationError{Problems: errors}
+		}
+		for _, obj := range objects {
+			if err := w(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AddCustomResourceDefinition - adds the schemas of a custom resource definition. Other objects are ignored.
+func (v *Validator) AddCustomResourceDefinition(obj *Object) error {
+	if obj.Kind != "CustomResourceDefinition" || !strings.HasPrefix(obj.APIVersion, "apiextensions.k8s.io/") {
+		return nil
+	}
+	var spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Version  string `json:"version"`
+		Versions []struct {
+			Name   string `json:"name"`
+			Schema *struct {
+				OpenAPIV3Schema *openAPISchema `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+		Validation *struct {
+			OpenAPIV3Schema *openAPISchema `json:"openAPIV3Schema"`
+		} `json:"validation"`
+	}
+	if err := json.Unmarshal(obj.Additional["spec"], &spec); err != nil {
+		return fmt.Errorf("Invalid custom resource definition: %s", err.Error())
+	}
+	add := func(version string, s *openAPISchema) {
+		if s != nil {
+			v.crds[spec.Group+"/"+version+"/"+spec.Names.Kind] = s.withObjectMeta()
+		}
+	}
+	if spec.Version != "" && spec.Validation != nil {
+		add(spec.Version, spec.Validation.OpenAPIV3Schema)
+	}
+	for _, version := range spec.Versions {
+		if version.Schema != nil {
+			add(version.Name, version.Schema.OpenAPIV3Schema)
+		} else if spec.Validation != nil {
+			add(version.Name, spec.Validation.OpenAPIV3Schema)
+		}
+	}
+	return nil
+}
+
+// validate returns the errors and warnings found for an object
+func (v *Validator) validate(obj *Object) ([]string, []string) {
+	errors := []string{}
+	warnings := []string{}
+	if obj.Kind == "" || obj.APIVersion == "" {
+		return errors, warnings
+	}
+	prefix := obj.Describe() + ": "
+	for _, l := range apiLifecycles {
+		if l.apiVersion != obj.APIVersion || (l.kind != "" && l.kind != obj.Kind) {
+			continue
+		}
+		if l.removed != "" && !v.kubeVersion.LessThan(semver.MustParse(l.removed)) {
+			msg := fmt.Sprintf("%s %s was removed in kubernetes %s", obj.APIVersion, obj.Kind, l.removed)
+			if l.replacement != "" {
+				msg += fmt.Sprintf(", use %s instead", l.replacement)
+			}
+			errors = append(errors, prefix+msg)
+		}
+		if l.introduced != "" && v.kubeVersion.LessThan(semver.MustParse(l.introduced)) {
+			errors = append(errors, prefix+fmt.Sprintf("%s %s isn't available before kubernetes %s", obj.APIVersion, obj.Kind, l.introduced))
+		}
+	}
+	s, versioned := v.schema(obj)
+	if s == nil {
+		return errors, warnings
+	}
+	data, err := json.Marshal(obj.WithoutSource())
+	if err != nil {
+		return append(errors, prefix+err.Error()), warnings
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return append(errors, prefix+err.Error()), warnings
+	}
+	problems := []validationProblem{}
+	s.validate("", value, &problems)
+	for _, p := range problems {
+		// without schemas of the version, fields added after the version of the compiled api types can't be known
+		if p.unknownField && !versioned && v.builtin(obj) && schemaKubeVersion.LessThan(v.kubeVersion) {
+			warnings = append(warnings, prefix+p.String())
+		} else {
+			errors = append(errors, prefix+p.String())
+		}
+	}
+	return errors, warnings
+}
+
+func (v *Validator) builtin(obj *Object) bool {
+	_, err := scheme.Scheme.New(schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind))
+	return err == nil
+}
+
+// schema returns the schema of an object or nil, if it's unknown. Custom resource definitions take precedence over the
+// OpenAPI schemas of the kubernetes version, which take precedence over the compiled api types. versioned is true,
+// unless the schema is a compiled api type, which doesn't match the kubernetes version.
+func (v *Validator) schema(obj *Object) (s *openAPISchema, versioned bool) {
+	key := obj.APIVersion + "/" + obj.Kind
+	if s, ok := v.crds[key]; ok {
+		return s, true
+	}
+	if s, ok := v.versionedSchemas()[key]; ok {
+		return s, true
+	}
+	if v.schemas == nil {
+		if typed, err := scheme.Scheme.New(schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind)); err == nil {
+			return schemaOf(reflect.TypeOf(typed), v.types), sameMinor(schemaKubeVersion, v.kubeVersion)
+		}
+	}
+	if !v.clusterCRDs && v.k != nil {
+		v.clusterCRDs = true
+		v.addClusterCRDs()
+	}
+	return v.crds[key], true
+}
+
+// versionedSchemas returns the OpenAPI schemas of the kubernetes version or nil, if they aren't available
+func (v *Validator) versionedSchemas() map[string]*openAPISchema {
+	if v.loaded {
+		return v.schemas
+	}
+	v.loaded = true
+	schemas, err := v.loadSchemas()
+	if err != nil && v.warnings != nil {
+		fmt.Fprintf(v.warnings, "Warning: OpenAPI schemas of kubernetes %d.%d aren't available: %s\n", v.kubeVersion.Major(), v.kubeVersion.Minor(), err.Error())
+	}
+	if err == nil && schemas != nil {
+		v.schemas = schemas
+	} else if !sameMinor(schemaKubeVersion, v.kubeVersion) && v.warnings != nil {
+		fmt.Fprintf(v.warnings, "Warning: Fields are checked against the api types of kubernetes %s, unknown fields are only reported as warnings\n", schemaKubeVersion.Original())
+	}
+	return v.schemas
+}
+
+// addClusterCRDs adds the custom resource definitions of the cluster. They are ignored, if the cluster can't be read.
+func (v *Validator) addClusterCRDs() {
+	list, err := v.k.List("customresourcedefinitions", &Options{Quiet: true}, &ListOptions{LabelSelector: labels.Everything(), AllNamespaces: true})
+	if err != nil || list == nil {
+		return
+	}
+	var items []*Object
+	if err := json.Unmarshal(list.Additional["items"], &items); err != nil {
+		return
+	}
+	for _, item := range items {
+		if item != nil {
+			_ = v.AddCustomResourceDefinition(item)
+		}
+	}
+}
+
+// openAPISchema - the part of an OpenAPI v3 schema used for validation
+type openAPISchema struct {
+	Type                  string                    `json:"type,omitempty"`
+	Properties            map[string]*openAPISchema `json:"properties,omitempty"`
+	Items                 *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties  *schemaOrBool             `json:"additionalProperties,omitempty"`
+	Required              []string                  `json:"required,omitempty"`
+	Enum                  []interface{}             `json:"enum,omitempty"`
+	PreserveUnknownFields bool                      `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	IntOrString           bool                      `json:"x-kubernetes-int-or-string,omitempty"`
+	Format                string                    `json:"format,omitempty"`
+	Ref                   string                    `json:"$ref,omitempty"`
+	AllOf                 []*openAPISchema          `json:"allOf,omitempty"`
+}
+
+type schemaOrBool struct {
+	schema *openAPISchema
+	allows bool
+}
+
+func (s *schemaOrBool) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.allows); err == nil {
+		return nil
+	}
+	s.schema = &openAPISchema{}
+	return json.Unmarshal(data, s.schema)
+}
+
+type validationProblem struct {
+	path         string
+	msg          string
+	unknownField bool
+}
+
+func (p validationProblem) String() string {
+	if p.path == "" {
+		return p.msg
+	}
+	return p.path + ": " + p.msg
+}
+
+var anySchema = &openAPISchema{PreserveUnknownFields: true}
+
+// withObjectMeta returns a copy of a custom resource schema, which accepts the fields common to all objects
+func (s *openAPISchema) withObjectMeta() *openAPISchema {
+	result := *s
+	result.Properties = map[string]*openAPISchema{}
+	for k, v := range s.Properties {
+		result.Properties[k] = v
+	}
+	for _, name := range []string{"apiVersion", "kind", "metadata"} {
+		if _, ok := result.Properties[name]; !ok {
+			result.Properties[name] = anySchema
+		}
+	}
+	return &result
+}
+
+func (s *openAPISchema) validate(path string, value interface{}, problems *[]validationProblem) {
+	if value == nil {
+		return
+	}
+	problem := func(format string, args ...interface{}) {
+		*problems = append(*problems, validationProblem{path: path, msg: fmt.Sprintf(format, args...)})
+	}
+	if s.IntOrString {
+		switch value.(type) {
+		case string, float64:
+		default:
+			problem("must be an integer or a string")
+		}
+		return
+	}
+	if len(s.Enum) != 0 && !containsValue(s.Enum, value) {
+		problem("unsupported value %v", value)
+	}
+	switch s.Type {
+	case "string":
+		if _, ok := value.(string); !ok {
+			problem("must be a string")
+		}
+	case "integer":
+		if f, ok := value.(float64); !ok || f != float64(int64(f)) {
+			problem("must be an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problem("must be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problem("must be a boolean")
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			problem("must be an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case "object", "":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			if s.Type == "object" {
+				problem("must be an object")
+			}
+			return
+		}
+		s.validateObject(path, m, problems)
+	}
+}
+
+func (s *openAPISchema) validateObject(path string, m map[string]interface{}, problems *[]validationProblem) {
+	for _, name := range s.Required {
+		if _, ok := m[name]; !ok {
+			*problems = append(*problems, validationProblem{path: path, msg: fmt.Sprintf("missing required field %q", name)})
+		}
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		if property, ok := s.Properties[name]; ok {
+			property.validate(fieldPath, m[name], problems)
+			continue
+		}
+		if s.AdditionalProperties != nil {
+			if s.AdditionalProperties.schema != nil {
+				s.AdditionalProperties.schema.validate(fieldPath, m[name], problems)
+				continue
+			}
+			if s.AdditionalProperties.allows {
+				continue
+			}
+		}
+		if s.PreserveUnknownFields || len(s.Properties) == 0 {
+			continue
+		}
+		*problems = append(*problems, validationProblem{path: fieldPath, msg: "unknown field", unknownField: true})
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	unmarshalerType  = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	intOrStringTypes = []reflect.Type{reflect.TypeOf(intstr.IntOrString{}), reflect.TypeOf(resource.Quantity{})}
+	stringTypes      = []reflect.Type{reflect.TypeOf(metav1.Time{}), reflect.TypeOf(metav1.MicroTime{}), reflect.TypeOf(metav1.Duration{})}
+)
+
+// schemaOf derives the schema of an api type from its json fields
+func schemaOf(t reflect.Type, cache map[reflect.Type]*openAPISchema) *openAPISchema {
+	if s, ok := cache[t]; ok {
+		return s
+	}
+	for _, ios := range intOrStringTypes {
+		if t == ios {
+			return &openAPISchema{IntOrString: true}
+		}
+	}
+	for _, st := range stringTypes {
+		if t == st {
+			return &openAPISchema{Type: "string"}
+		}
+	}
+	if t.Kind() != reflect.Ptr && (t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType)) {
+		return anySchema
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), cache)
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string"}
+		}
+		return &openAPISchema{Type: "array", Items: schemaOf(t.Elem(), cache)}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: &schemaOrBool{schema: schemaOf(t.Elem(), cache)}}
+	case reflect.Struct:
+		s := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+		cache[t] = s
+		addFields(t, s, cache)
+		return s
+	}
+	return anySchema
+}
+
+func addFields(t reflect.Type, s *openAPISchema, cache map[reflect.Type]*openAPISchema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		inline := field.Anonymous && name == ""
+		for _, option := range tag[1:] {
+			inline = inline || option == "inline"
+		}
+		if inline {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				addFields(fieldType, s, cache)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = schemaOf(field.Type, cache)
+	}
+}
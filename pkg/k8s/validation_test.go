@@ -0,0 +1,278 @@
+package k8s
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+
+	"github.com/Masterminds/semver/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validator", func() {
+	decode := func(data string) ObjectStream {
+		return Decode(func(w io.Writer) error {
+			_, err := io.WriteString(w, data)
+			return err
+		})
+	}
+	validate := func(version string, k K8s, data string, options ...ValidatorOption) ([]string, string, error) {
+		warnings := &bytes.Buffer{}
+		names := []string{}
+		err := decode(data).Validate(NewValidator(semver.MustParse(version), k, warnings, options...))(func(obj *Object) error {
+			names = append(names, obj.MetaData.Name)
+			return nil
+		})
+		return names, warnings.String(), err
+	}
+	const crd = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: backups.example.com
+spec:
+  group: example.com
+  names:
+    kind: Backup
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [schedule]
+            properties:
+              schedule:
+                type: string
+              retention:
+                type: integer
+`
+
+	const swagger = `{
+  "definitions": {
+    "io.k8s.api.batch.v1.CronJob": {
+      "type": "object",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"$ref": "#/definitions/io.k8s.api.batch.v1.CronJobSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "batch", "kind": "CronJob", "version": "v1"}]
+    },
+    "io.k8s.api.batch.v1.CronJobSpec": {
+      "type": "object",
+      "required": ["schedule"],
+      "properties": {
+        "schedule": {"type": "string"},
+        "timeZone": {"type": "string"},
+        "limits": {"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.api.resource.Quantity"}},
+        "port": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.util.intstr.IntOrString"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "io.k8s.apimachinery.pkg.api.resource.Quantity": {"type": "string"},
+    "io.k8s.apimachinery.pkg.util.intstr.IntOrString": {"type": "string", "format": "int-or-string"}
+  }
+}`
+	const cronJob = `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: nightly
+spec:
+  schedule: "@daily"
+  timeZone: UTC
+  limits:
+    cpu: 1
+    memory: 1Gi
+  port: 8080
+  shedule: typo
+`
+
+	It("passes valid objects", func() {
+		names, warnings, err := validate("1.17.0", nil, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+        ports:
+        - containerPort: 8080
+        resources:
+          requests:
+            cpu: 100m
+            memory: 1Gi
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(BeEmpty())
+		Expect(names).To(Equal([]string{"app"}))
+	})
+
+	It("reports unknown fields and wrong types", func() {
+		_, _, err := validate("1.17.0", nil, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: many
+  template:
+    spec:
+      containers:
+      - name: app
+        imagePullPolicy: Always
+        imag: app:1.0
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Deployment app: spec.replicas: must be an integer"))
+		Expect(err.Error()).To(ContainSubstring("Deployment app: spec.template.spec.containers[0].imag: unknown field"))
+	})
+
+	It("names the source of invalid objects", func() {
+		_, _, err := validate("1.17.0", nil, "---\n# Source: templates/config.yaml:1\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata: 1\n")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("templates/config.yaml:1 ConfigMap config: data: must be an object"))
+	})
+
+	It("reports apis removed in the target version", func() {
+		data := `
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: app
+`
+		_, _, err := validate("1.17.0", nil, data)
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = validate("1.27.0", nil, data)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("networking.k8s.io/v1beta1 Ingress was removed in kubernetes 1.22, use networking.k8s.io/v1 instead"))
+	})
+
+	It("warns about unknown fields of versions newer than the known schemas", func() {
+		names, warnings, err := validate("1.27.0", nil, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  minReadySeconds: 1
+  newField: true
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"app"}))
+		Expect(warnings).To(ContainSubstring("Warning: Deployment app: spec.newField: unknown field"))
+	})
+
+	It("validates with the schemas of the cluster, if it has the kubernetes version", func() {
+		k := NewK8sInMemory("default")
+		k.SetCapabilities(&Capabilities{KubeVersion: semver.MustParse("1.27.3")})
+		k.SetOpenAPISchema([]byte(swagger))
+		_, warnings, err := validate("1.27.0", k, cronJob)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*ValidationError).Problems).To(Equal([]string{"CronJob nightly: spec.shedule: unknown field"}))
+		Expect(warnings).To(BeEmpty())
+
+		k.SetCapabilities(&Capabilities{KubeVersion: semver.MustParse("1.26.0")})
+		names, warnings, err := validate("1.27.0", k, cronJob)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"nightly"}))
+		Expect(warnings).To(ContainSubstring("Warning: Fields are checked against the api types of kubernetes 1.17.0"))
+	})
+
+	It("downloads the schemas of the kubernetes version into the schema dir", func() {
+		dir, err := ioutil.TempDir("", "kdo-schemas-")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		requests := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.Path)
+			io.WriteString(w, swagger)
+		}))
+		defer server.Close()
+		options := []ValidatorOption{WithSchemaDir(dir), WithSchemaURL(server.URL + "/v%d.%d.0/swagger.json")}
+
+		_, _, err = validate("1.27.1", nil, cronJob, options...)
+		Expect(err).To(MatchError(ContainSubstring("CronJob nightly: spec.shedule: unknown field")))
+		Expect(requests).To(Equal([]string{"/v1.27.0/swagger.json"}))
+		Expect(path.Join(dir, "v1.27.json")).To(BeAnExistingFile())
+
+		_, _, err = validate("1.27.0", nil, cronJob, options...)
+		Expect(err).To(MatchError(ContainSubstring("CronJob nightly: spec.shedule: unknown field")))
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("falls back to the compiled api types, if the schemas can't be downloaded", func() {
+		dir, err := ioutil.TempDir("", "kdo-schemas-")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+		names, warnings, err := validate("1.27.0", nil, cronJob, WithSchemaDir(dir), WithSchemaURL(server.URL+"/v%d.%d.0/swagger.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"nightly"}))
+		Expect(warnings).To(ContainSubstring("Warning: OpenAPI schemas of kubernetes 1.27 aren't available: Download of " + server.URL + "/v1.27.0/swagger.json failed: 404 Not Found"))
+	})
+
+	It("validates custom resources with definitions of the stream", func() {
+		_, _, err := validate("1.17.0", nil, crd+`
+---
+apiVersion: example.com/v1
+kind: Backup
+metadata:
+  name: daily
+spec:
+  retention: 7
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`Backup daily: spec: missing required field "schedule"`))
+	})
+
+	It("validates custom resources with definitions of the cluster", func() {
+		k := NewK8sInMemory("default")
+		Expect(k.Apply(decode(crd), &Options{})).To(Succeed())
+		_, _, err := validate("1.17.0", k, `
+apiVersion: example.com/v1
+kind: Backup
+metadata:
+  name: daily
+spec:
+  schedule: "@daily"
+  retention: weekly
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Backup daily: spec.retention: must be an integer"))
+	})
+
+	It("ignores unknown kinds", func() {
+		names, _, err := validate("1.17.0", nil, `
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: any
+spec:
+  anything: 1
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"any"}))
+	})
+})
@@ -0,0 +1,276 @@
+package kdo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/k14s/starlark-go/syntax"
+	"gopkg.in/yaml.v2"
+)
+
+const bundleManifestFile = "bundle.yaml"
+
+// remoteChartURL matches urls, which are loaded by the repo instead of being relative to the chart directory
+var remoteChartURL = regexp.MustCompile(`^[a-z][a-z0-9+.-]*:`)
+
+// bundleManifest maps the urls of all charts in a bundle to their cached content
+type bundleManifest struct {
+	APIVersion string        `yaml:"apiVersion"`
+	Chart      string        `yaml:"chart"`
+	Package    string        `yaml:"package,omitempty"`
+	Entries    []bundleEntry `yaml:"entries"`
+}
+
+type bundleEntry struct {
+	URL  string `yaml:"url"`
+	ETag string `yaml:"etag,omitempty"`
+	Path string `yaml:"path"`
+}
+
+// Bundle - the result of creating or importing a bundle
+type Bundle struct {
+	// Chart is the url of the root chart or the file name of the packaged root chart
+	Chart string
+	// URLs are the urls of all charts, indexes and repositories in the bundle
+	URLs []string
+}
+
+// CreateBundle writes all charts loaded by a chart into a bundle. Charts are discovered by loading the chart and
+// resolving its dependencies and by scanning Chart.star files for chart, helm_chart and depends_on calls.
+func CreateBundle(thread *starlark.Thread, url string, writer io.Writer, config ...RepoConfig) (*Bundle, error) {
+	dir, err := ioutil.TempDir("", "kdo-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	config = append(config, WithCacheDir(path.Join(dir, "cache")))
+	repo, err := NewRepo(config...)
+	if err != nil {
+		return nil, err
+	}
+	r := repo.(*repoImpl)
+	c, err := r.Get(thread, url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Resolve(thread, c, nil); err != nil {
+		return nil, err
+	}
+	cache, err := NewCache(config...)
+	if err != nil {
+		return nil, err
+	}
+	chartDirs := []string{}
+	if ci, ok := c.(*chartImpl); ok {
+		chartDirs = append(chartDirs, ci.dir)
+	}
+	entries, err := cache.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		chartDirs = append(chartDirs, entry.Dir)
+	}
+	seen := map[string]bool{}
+	for len(chartDirs) > 0 {
+		refs, err := chartReferences(chartDirs[0])
+		if err != nil {
+			return nil, err
+		}
+		chartDirs = chartDirs[1:]
+		for _, ref := range refs {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			d, err := r.cache(ref)
+			if err != nil {
+				log.Printf("Warning: %s isn't bundled: %s\n", ref, err.Error())
+				continue
+			}
+			chartDirs = append(chartDirs, d)
+		}
+	}
+
+	manifest := &bundleManifest{APIVersion: "v1", Chart: url}
+	gz := gzip.NewWriter(writer)
+	tw := tar.NewWriter(gz)
+	if !remoteChartURL.MatchString(url) {
+		manifest.Package = path.Base(c.GetName()) + ".tgz"
+		if c.GetVersion() != nil {
+			manifest.Package = path.Base(c.GetName()) + "-" + c.GetVersion().String() + ".tgz"
+		}
+		manifest.Chart = manifest.Package
+		if err := writeFile(tw, path.Join("charts", manifest.Package), func(w io.Writer) error {
+			return c.Package(w, false)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if entries, err = cache.List(); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Dir == "" {
+			continue
+		}
+		p := path.Join("cache", entry.key)
+		if err := writeDir(tw, p, entry.Dir); err != nil {
+			return nil, err
+		}
+		manifest.Entries = append(manifest.Entries, bundleEntry{URL: entry.Name, ETag: entry.etag, Path: p})
+	}
+	if err := writeFile(tw, bundleManifestFile, func(w io.Writer) error {
+		return yaml.NewEncoder(w).Encode(manifest)
+	}); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest.bundle(), nil
+}
+
+// ImportBundle stores the content of a bundle in the cache, so the urls in the bundle can be loaded offline.
+// A packaged root chart is written into chartDir. Bundled charts can't be verified, i.e. they are rejected, if
+// verification is enforced for their urls.
+func ImportBundle(reader io.Reader, chartDir string, config ...RepoConfig) (*Bundle, error) {
+	dir, err := ioutil.TempDir("", "kdo-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := tarExtract(gz, dir, regexp.MustCompile("^")); err != nil {
+		return nil, err
+	}
+	manifest := &bundleManifest{}
+	if err := readYamlFile(path.Join(dir, bundleManifestFile), manifest); err != nil {
+		return nil, fmt.Errorf("Invalid bundle: %s", err.Error())
+	}
+	repo, err := NewRepo(config...)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := NewCache(config...)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range manifest.Entries {
+		entryDir := path.Join(dir, filepath.FromSlash(path.Clean("/"+entry.Path)))
+		if !isHelmIndexDir(entryDir) {
+			if err := repo.(*repoImpl).verifier.checkUnsigned(entry.URL); err != nil {
+				return nil, fmt.Errorf("Can't import %s: %s", entry.URL, err.Error())
+			}
+		}
+		if err := cache.seed(entry.URL, entry.ETag, entryDir); err != nil {
+			return nil, fmt.Errorf("Can't import %s: %s", entry.URL, err.Error())
+		}
+	}
+	bundle := manifest.bundle()
+	if manifest.Package != "" {
+		data, err := ioutil.ReadFile(path.Join(dir, "charts", path.Base(manifest.Package)))
+		if err != nil {
+			return nil, err
+		}
+		bundle.Chart = path.Join(chartDir, path.Base(manifest.Package))
+		if err := ioutil.WriteFile(bundle.Chart, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return bundle, nil
+}
+
+func (m *bundleManifest) bundle() *Bundle {
+	bundle := &Bundle{Chart: m.Chart, URLs: make([]string, 0, len(m.Entries))}
+	for _, entry := range m.Entries {
+		bundle.URLs = append(bundle.URLs, entry.URL)
+	}
+	return bundle
+}
+
+// isHelmIndexDir returns true, if the directory contains a cached helm index instead of a chart
+func isHelmIndexDir(dir string) bool {
+	if _, err := os.Stat(path.Join(dir, "Chart.yaml")); err == nil {
+		return false
+	}
+	_, err := os.Stat(path.Join(dir, "index.yaml"))
+	return err == nil
+}
+
+// chartReferences returns the literal urls of chart, helm_chart and depends_on calls in all Chart.star files of a directory
+func chartReferences(dir string) ([]string, error) {
+	refs := map[string]bool{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != "Chart.star" {
+			return nil
+		}
+		f, err := syntax.Parse(p, nil, 0)
+		if err != nil {
+			return nil
+		}
+		syntax.Walk(f, func(n syntax.Node) bool {
+			call, ok := n.(*syntax.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			fn, ok := call.Fn.(*syntax.Ident)
+			if !ok || (fn.Name != "chart" && fn.Name != "helm_chart" && fn.Name != "depends_on") {
+				return true
+			}
+			if lit, ok := call.Args[0].(*syntax.Literal); ok && lit.Token == syntax.STRING {
+				if url := lit.Value.(string); remoteChartURL.MatchString(url) {
+					refs[url] = true
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortedStrings(refs), nil
+}
+
+// writeDir adds all files of a directory to a tar archive
+func writeDir(tw *tar.Writer, prefix string, dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return writeFile(tw, path.Join(prefix, filepath.ToSlash(rel)), func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+	})
+}
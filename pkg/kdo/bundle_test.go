@@ -0,0 +1,111 @@
+package kdo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Bundle", func() {
+	var dir TestDir
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("bundles all charts loaded by a chart and imports them for offline use", func() {
+		repo, err := NewRepo(WithCacheDir(dir.Join("online")))
+		Expect(err).NotTo(HaveOccurred())
+		dir.MkdirAll("charts", 0755)
+		for _, name := range []string{"used", "conditional"} {
+			dir.MkdirAll("src/"+name, 0755)
+			dir.WriteFile("src/"+name+"/Chart.yaml", []byte("name: "+name+"\nversion: 1.0.0\n"), 0644)
+			c, err := repo.Get(thread, dir.Join("src", name))
+			Expect(err).NotTo(HaveOccurred())
+			buf := &bytes.Buffer{}
+			Expect(c.Package(buf, false)).To(Succeed())
+			dir.WriteFile("charts/"+name+".tgz", buf.Bytes(), 0644)
+		}
+		server := httptest.NewServer(http.FileServer(http.Dir(dir.Join("charts"))))
+		defer server.Close()
+
+		dir.MkdirAll("top", 0755)
+		dir.WriteFile("top/Chart.yaml", []byte("name: top\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("top/Chart.star", []byte(`
+def init(self):
+  self.used = chart("`+server.URL+`/used.tgz")
+  if False:
+    self.conditional = chart("`+server.URL+`/conditional.tgz")
+`), 0644)
+
+		buf := &bytes.Buffer{}
+		bundle, err := CreateBundle(thread, dir.Join("top"), buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bundle.Chart).To(Equal("top-1.0.0.tgz"))
+		Expect(bundle.URLs).To(ConsistOf(server.URL+"/used.tgz", server.URL+"/conditional.tgz"))
+		server.Close()
+
+		dir.MkdirAll("import", 0755)
+		imported, err := ImportBundle(buf, dir.Join("import"), WithCacheDir(dir.Join("offline")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported.Chart).To(Equal(dir.Join("import", "top-1.0.0.tgz")))
+		Expect(imported.URLs).To(ConsistOf(bundle.URLs))
+
+		offline, err := NewRepo(WithCacheDir(dir.Join("offline")), WithOffline())
+		Expect(err).NotTo(HaveOccurred())
+		top, err := offline.Get(thread, imported.Chart)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(top.GetName()).To(Equal("top"))
+		conditional, err := offline.Get(thread, server.URL+"/conditional.tgz")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conditional.GetName()).To(Equal("conditional"))
+		_, err = offline.Get(thread, server.URL+"/missing.tgz")
+		Expect(err).To(MatchError(ContainSubstring("can't be loaded in offline mode")))
+	})
+
+	It("rejects bundled charts, if verification is enforced", func() {
+		dir.MkdirAll("charts", 0755)
+		dir.MkdirAll("src", 0755)
+		dir.WriteFile("src/Chart.yaml", []byte("name: used\nversion: 1.0.0\n"), 0644)
+		repo, err := NewRepo(WithCacheDir(dir.Join("online")))
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("src"))
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(c.Package(buf, false)).To(Succeed())
+		dir.WriteFile("charts/used.tgz", buf.Bytes(), 0644)
+		server := httptest.NewServer(http.FileServer(http.Dir(dir.Join("charts"))))
+		defer server.Close()
+
+		buf.Reset()
+		_, err = CreateBundle(thread, server.URL+"/used.tgz", buf)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ImportBundle(buf, dir.Root(), WithCacheDir(dir.Join("offline")), WithVerification(server.URL, VerificationEnforce))
+		Expect(err).To(MatchError(ContainSubstring("Can't import " + server.URL + "/used.tgz: Verification of chart " + server.URL + "/used.tgz failed: chart isn't signed")))
+	})
+
+	It("rejects bundles with entries outside of the target directory", func() {
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gz)
+		data := []byte("evil")
+		Expect(tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Size: int64(len(data))})).To(Succeed())
+		_, err := tw.Write(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+		_, err = ImportBundle(buf, dir.Root(), WithCacheDir(dir.Join("cache")))
+		Expect(err).To(MatchError(ContainSubstring("Invalid archive entry ../evil outside of the target directory")))
+	})
+})
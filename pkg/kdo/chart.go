@@ -34,6 +34,8 @@ type Chart interface {
 	Package(writer io.Writer, helmFormat bool) error
 	AddUsedBy(reference string, k k8s.K8s) (int, error)
 	RemoveUsedBy(reference string, k k8s.K8s) (int, error)
+	Schema() map[string]interface{}
+	Docs(writer io.Writer, format string) error
 }
 
 // ChartValue -
@@ -51,6 +53,8 @@ type chartImpl struct {
 	dir      string
 	repo     Repo
 	initFunc *starlark.Function
+	// asDependency is true, if the chart is applied as dependency of another chart
+	asDependency bool
 }
 
 var (
@@ -93,7 +97,9 @@ func newChart(thread *starlark.Thread, repo Repo, dir string, opts ...ChartOptio
 	if err := c.init(thread, hasChartYaml, co); err != nil {
 		return nil, err
 	}
-	c.SetValue(co.properties.GetValue())
+	if err := c.SetValue(co.properties.GetValue()); err != nil {
+		return nil, err
+	}
 	return c, nil
 
 }
@@ -225,7 +231,7 @@ func (c *chartImpl) Attr(name string) (starlark.Value, error) {
 	if ok {
 		value = property.GetValueOrDefault()
 	}
-	return starutils.WrapDict(value), nil
+	return starutils.WrapDict(resolvedValue(value)), nil
 }
 
 // AttrNames returns a new sorted list of the struct fields.
@@ -249,10 +255,10 @@ func (c *chartImpl) SetField(name string, val starlark.Value) error {
 			if ok {
 				err := subchart.SetValue(property)
 				if err != nil {
-					return err
+					return withPath(name, err)
 				}
 			} else {
-				return property.SetValue(val)
+				return withPath(name, property.SetValue(val))
 			}
 		}
 	}
@@ -269,7 +275,7 @@ func (c *chartImpl) Get(name starlark.Value) (starlark.Value, bool, error) {
 	if ok {
 		value = property.GetValueOrDefault()
 	}
-	return starutils.WrapDict(value), true, nil
+	return starutils.WrapDict(resolvedValue(value)), true, nil
 }
 
 func (c *chartImpl) SetKey(name, value starlark.Value) error {
@@ -331,7 +337,7 @@ func (c *chartImpl) applyLocal(thread *starlark.Thread, k k8s.K8sValue, k8sOptio
 		return nil
 	}
 	k8sOptions.ClusterScoped = true
-	return k.Apply(k8s.Decode(c.template(thread, glob, k)), k8sOptions)
+	return c.applyWithHooks(thread, k, k8sOptions, glob)
 }
 
 func (c *chartImpl) objName() string {
@@ -350,13 +356,21 @@ func (c *chartImpl) configMap() *k8s.Object {
 				"kdo.sap.github.com/genus":   c.GetGenus(),
 				"kdo.sap.github.com/version": c.GetVersionString(),
 			},
-			Annotations: map[string]string{
-				"kapp.k14s.io/disable-original": "true",
-			},
+			Annotations: c.configMapAnnotations(),
 		},
 	}
 }
 
+func (c *chartImpl) configMapAnnotations() map[string]string {
+	annotations := map[string]string{
+		"kapp.k14s.io/disable-original": "true",
+	}
+	if c.asDependency {
+		annotations[dependencyAnnotation] = "true"
+	}
+	return annotations
+}
+
 func (c *chartImpl) secret() *k8s.Object {
 	return &k8s.Object{
 		APIVersion: corev1.SchemeGroupVersion.String(),
@@ -376,15 +390,35 @@ func (c *chartImpl) secret() *k8s.Object {
 	}
 }
 
+// updateLabels updates the labels of an existing object, e.g. the version after an upgrade
+func updateLabels(obj *k8s.Object, labels map[string]string) {
+	if obj.MetaData.Labels == nil {
+		obj.MetaData.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		obj.MetaData.Labels[k] = v
+	}
+}
+
 func (c *chartImpl) modifyConfigMap(obj *k8s.Object) error {
+	updateLabels(obj, c.configMap().MetaData.Labels)
+	// charts applied directly aren't removed by the garbage collection
+	if !c.asDependency {
+		delete(obj.MetaData.Annotations, dependencyAnnotation)
+	}
 	buffer := &bytes.Buffer{}
 	if err := c.Package(buffer, false); err != nil {
 		return err
 	}
+	schema, err := json.Marshal(c.Schema())
+	if err != nil {
+		return err
+	}
 	data, err := json.Marshal(map[string]string{
 		"genus":   c.GetGenus(),
 		"version": c.GetVersion().String(),
 		"chart":   base64.StdEncoding.EncodeToString(buffer.Bytes()),
+		"schema":  string(schema),
 	})
 	if err != nil {
 		return err
@@ -394,24 +428,31 @@ func (c *chartImpl) modifyConfigMap(obj *k8s.Object) error {
 	}
 	return nil
 }
-func (c *chartImpl) modifySecret(obj *k8s.Object) error {
-	byteData := map[string][]byte{}
-	// only persist properties
-	for _, t := range c.GetValue().(starlark.IterableMapping).Items() {
-		j, err := json.Marshal(starutils.ToGo(t.Index(1)))
+func (c *chartImpl) modifySecret(k k8s.K8sReader) func(obj *k8s.Object) error {
+	return func(obj *k8s.Object) error {
+		updateLabels(obj, c.secret().MetaData.Labels)
+		byteData := map[string][]byte{}
+		// only persist properties
+		for _, t := range c.GetValue().(starlark.IterableMapping).Items() {
+			j, err := json.Marshal(toPersistedGo(t.Index(1)))
+			if err != nil {
+				return err
+			}
+			byteData[t.Index(0).(starlark.String).GoString()] = j
+		}
+		byteData, err := encryptProperties(k, c.keyProvider, byteData)
 		if err != nil {
 			return err
 		}
-		byteData[t.Index(0).(starlark.String).GoString()] = j
-	}
-	data, err := json.Marshal(byteData)
-	if err != nil {
-		return err
-	}
-	obj.Additional = map[string]json.RawMessage{
-		"data": data,
+		data, err := json.Marshal(byteData)
+		if err != nil {
+			return err
+		}
+		obj.Additional = map[string]json.RawMessage{
+			"data": data,
+		}
+		return nil
 	}
-	return nil
 }
 
 func (c *chartImpl) Delete(thread *starlark.Thread, k k8s.K8s, options *DeleteOptions) error {
@@ -462,7 +503,7 @@ func (c *chartImpl) deleteLocal(thread *starlark.Thread, k k8s.K8sValue, k8sOpti
 		return nil
 	}
 	k8sOptions.ClusterScoped = true
-	err := k.Delete(k8s.Decode(c.template(thread, glob, k)), k8sOptions)
+	err := c.deleteWithHooks(thread, k, k8sOptions, glob)
 	if err != nil {
 		return err
 	}
@@ -494,6 +535,22 @@ func (c *chartImpl) wrapApply(callable starlark.Callable) starlark.Callable {
 		if !ok {
 			return nil, fmt.Errorf("Invalid first argument to %s", callable.Name())
 		}
+		if err := c.checkRequired(); err != nil {
+			return starlark.None, err
+		}
+		if !c.skipChart {
+			if err := c.checkCompatibility(k, c.warnings); err != nil {
+				return starlark.None, err
+			}
+		}
+		if c.repo != nil && resolvedDependencies(thread) == nil {
+			lock, err := c.repo.Resolve(thread, c, k)
+			if err != nil {
+				return starlark.None, err
+			}
+			thread.SetLocal(resolvedDependenciesKey, lock)
+			defer thread.SetLocal(resolvedDependenciesKey, nil)
+		}
 		for _, v := range c.values {
 			dependency, ok := v.(*dependency)
 			if ok {
@@ -510,11 +567,11 @@ func (c *chartImpl) wrapApply(callable starlark.Callable) starlark.Callable {
 		if !c.skipChart {
 			_, err = k.CreateOrUpdate(c.configMap(), c.modifyConfigMap, &k8s.Options{Quiet: true})
 			if err != nil {
-				return starlark.None, nil
+				return starlark.None, errors.Wrapf(err, "Unable to persist properties of %s", c.GetName())
 			}
-			_, err = k.CreateOrUpdate(c.secret(), c.modifySecret, &k8s.Options{Quiet: true})
+			_, err = k.CreateOrUpdate(c.secret(), c.modifySecret(k), &k8s.Options{Quiet: true})
 			if err != nil {
-				return starlark.None, nil
+				return starlark.None, errors.Wrapf(err, "Unable to persist properties of %s", c.GetName())
 			}
 		}
 		return value, err
@@ -634,13 +691,42 @@ func (c *chartImpl) SetValue(value starlark.Value) error {
 
 }
 
+// checkRequired -
+func (c *chartImpl) checkRequired() error {
+	properties := make(map[string]PropertyValue)
+	for name, v := range c.values {
+		if property, ok := v.(PropertyValue); ok {
+			properties[name] = property
+		}
+	}
+	return checkRequired(properties)
+}
+
+// resolveSecretRefs reads the content of all secret references from k. If secrets must not be revealed, the content is redacted instead.
+func (c *chartImpl) resolveSecretRefs(thread *starlark.Thread, k k8s.K8sReader) error {
+	redact := thread.Local("redact-secrets") != nil
+	for _, v := range c.values {
+		err := eachSecretRef(v, func(ref *secretRef) error {
+			if redact {
+				ref.redact()
+				return nil
+			}
+			return ref.resolve(k, c.namespace)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func remainingReferences(obj *k8s.Object) int {
 	counter := 0
 	if obj == nil {
 		return 0
 	}
 	for k := range obj.MetaData.Annotations {
-		if strings.HasPrefix(k, "kdo-usedby-") {
+		if strings.HasPrefix(k, usedByPrefix) {
 			counter++
 		}
 	}
@@ -648,21 +734,31 @@ func remainingReferences(obj *k8s.Object) int {
 }
 
 func usedByAnnotation(reference string) string {
-	return "kdo-usedby-" + k8s.FixLabelValue(reference)
+	return usedByPrefix + k8s.FixLabelValue(reference)
 }
 
 func (c *chartImpl) AddUsedBy(reference string, k k8s.K8s) (int, error) {
-	patch := fmt.Sprintf(`[{"op": "add", "path": "/metadata/annotations/%s", "value" : "True"}]`, usedByAnnotation(reference))
-	obj, err := k.Patch("configmap", c.objName(), types.JSONPatchType, patch, &k8s.Options{Namespace: c.namespace})
-	if err != nil {
-		return 0, fmt.Errorf("can't add reference to configmap %s in namespace %s: %v %v. error during application of patch `%s`", c.objName(), c.namespace, err, obj, patch)
-	}
-	return remainingReferences(obj), nil
+	return c.addUsedBy(reference, unknownConstraint, k)
 }
 
 func (c *chartImpl) RemoveUsedBy(reference string, k k8s.K8s) (int, error) {
-	patch := fmt.Sprintf(`[{"op": "remove", "path": "/metadata/annotations/%s"}]`, usedByAnnotation(reference))
-	obj, err := k.Patch("configmap", c.objName(), types.JSONPatchType, patch, &k8s.Options{Namespace: c.namespace, IgnoreNotFound: true})
+	obj, err := k.Get("configmap", c.objName(), &k8s.Options{Namespace: c.namespace, IgnoreNotFound: true})
+	if err != nil || obj == nil {
+		return 0, err
+	}
+	// remove the reference written by older versions of kdo as well
+	operations := make([]string, 0, 2)
+	for _, annotation := range []string{usedByAnnotation(reference), usedByAnnotation(legacyReference(reference))} {
+		operation := fmt.Sprintf(`{"op": "remove", "path": "/metadata/annotations/%s"}`, annotation)
+		if _, ok := obj.MetaData.Annotations[annotation]; ok && !containsString(operations, operation) {
+			operations = append(operations, operation)
+		}
+	}
+	if len(operations) == 0 {
+		return remainingReferences(obj), nil
+	}
+	patch := "[" + strings.Join(operations, ", ") + "]"
+	obj, err = k.Patch("configmap", c.objName(), types.JSONPatchType, patch, &k8s.Options{Namespace: c.namespace, IgnoreNotFound: true})
 	if err != nil {
 		return 0, fmt.Errorf("can't remove reference from configmap %s in namespace %s: %verror during application of patch `%s`", c.objName(), c.namespace, err, patch)
 	}
@@ -0,0 +1,202 @@
+package kdo
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
+)
+
+type propertyDoc struct {
+	Name        string
+	Type        string
+	Default     string
+	Description string
+	Required    bool
+}
+
+type dependencyDoc struct {
+	Name       string
+	URL        string
+	Constraint string
+	Namespace  string
+}
+
+type subChartDoc struct {
+	Name    string
+	Chart   string
+	Version string
+}
+
+type jewelDoc struct {
+	Name   string
+	Kind   string
+	Secret string
+}
+
+type chartDocs struct {
+	Name         string
+	Version      string
+	Description  string
+	Args         []string
+	Properties   []propertyDoc
+	Dependencies []dependencyDoc
+	SubCharts    []subChartDoc
+	Jewels       []jewelDoc
+}
+
+// initParams returns the names of the parameters of init without self
+func (c *chartImpl) initParams() []string {
+	args := make([]string, 0)
+	if c.initFunc != nil {
+		for i := 1; i < c.initFunc.NumParams(); i++ {
+			arg, _ := c.initFunc.Param(i)
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+func (c *chartImpl) docs() *chartDocs {
+	result := &chartDocs{
+		Name:        c.GetName(),
+		Version:     c.GetVersionString(),
+		Description: c.clazz.Description,
+		Args:        c.initParams(),
+	}
+	names := make([]string, 0, len(c.values))
+	for name := range c.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch v := c.values[name].(type) {
+		case *property, *structProperty:
+			result.Properties = append(result.Properties, propertyDocs(name, v)...)
+		case *dependency:
+			result.Dependencies = append(result.Dependencies, dependencyDoc{Name: name, URL: v.url, Constraint: v.constraint.String(), Namespace: v.namespace})
+		case *chartImpl:
+			result.SubCharts = append(result.SubCharts, subChartDoc{Name: name, Chart: v.GetName(), Version: v.GetVersionString()})
+		case *jewel:
+			result.Jewels = append(result.Jewels, jewelDoc{Name: name, Kind: v.backend.Name(), Secret: v.name})
+		}
+	}
+	return result
+}
+
+// propertyDocs flattens struct properties using paths like db.port
+func propertyDocs(path string, value starlark.Value) []propertyDoc {
+	switch v := value.(type) {
+	case *property:
+		doc := propertyDoc{Name: path, Description: v.description, Required: v.required}
+		if v.typed {
+			doc.Type = v.typ
+		}
+		if v.dflt != starlark.None {
+			if v.secret {
+				doc.Default = redactedValue
+			} else if data, err := json.Marshal(starutils.ToGo(v.dflt)); err == nil {
+				doc.Default = string(data)
+			}
+		}
+		return []propertyDoc{doc}
+	case *structProperty:
+		names := v.AttrNames()
+		sort.Strings(names)
+		result := make([]propertyDoc, 0)
+		for _, name := range names {
+			result = append(result, propertyDocs(path+"."+name, v.properties[name])...)
+		}
+		return result
+	}
+	return nil
+}
+
+// Docs writes a reference of the chart in markdown or html format
+func (c *chartImpl) Docs(writer io.Writer, format string) error {
+	switch format {
+	case "markdown", "md", "":
+		return template.Must(template.New("docs").Funcs(template.FuncMap{"cell": markdownCell}).Parse(markdownDocsTemplate)).Execute(writer, c.docs())
+	case "html":
+		return htmltemplate.Must(htmltemplate.New("docs").Parse(htmlDocsTemplate)).Execute(writer, c.docs())
+	}
+	return fmt.Errorf("Unknown format %s. Supported formats are markdown and html", format)
+}
+
+// markdownCell escapes a value, so that it stays within a cell of a markdown table
+func markdownCell(value interface{}) string {
+	return strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>").Replace(fmt.Sprint(value))
+}
+
+const markdownDocsTemplate = `# {{ .Name }}{{ if .Version }} {{ .Version }}{{ end }}
+{{ if .Description }}
+{{ .Description }}
+{{ end }}{{ if .Args }}
+## Arguments
+
+{{ range .Args }}* ` + "`{{ . }}`" + `
+{{ end }}{{ end }}{{ if .Properties }}
+## Properties
+
+| Name | Type | Default | Required | Description |
+| ---- | ---- | ------- | -------- | ----------- |
+{{ range .Properties }}| ` + "`{{ cell .Name }}`" + ` | {{ cell .Type }} | {{ if .Default }}` + "`{{ cell .Default }}`" + `{{ end }} | {{ if .Required }}yes{{ end }} | {{ cell .Description }} |
+{{ end }}{{ end }}{{ if .Dependencies }}
+## Dependencies
+
+| Name | URL | Constraint | Namespace |
+| ---- | --- | ---------- | --------- |
+{{ range .Dependencies }}| ` + "`{{ cell .Name }}`" + ` | {{ cell .URL }} | {{ cell .Constraint }} | {{ cell .Namespace }} |
+{{ end }}{{ end }}{{ if .SubCharts }}
+## Sub charts
+
+| Name | Chart | Version |
+| ---- | ----- | ------- |
+{{ range .SubCharts }}| ` + "`{{ cell .Name }}`" + ` | {{ cell .Chart }} | {{ cell .Version }} |
+{{ end }}{{ end }}{{ if .Jewels }}
+## Secrets
+
+| Name | Kind | Secret |
+| ---- | ---- | ------ |
+{{ range .Jewels }}| ` + "`{{ cell .Name }}`" + ` | {{ cell .Kind }} | {{ cell .Secret }} |
+{{ end }}{{ end }}`
+
+const htmlDocsTemplate = `<!DOCTYPE html>
+<html>
+<head><title>{{ .Name }}</title></head>
+<body>
+<h1>{{ .Name }}{{ if .Version }} {{ .Version }}{{ end }}</h1>
+{{ if .Description }}<p>{{ .Description }}</p>
+{{ end }}{{ if .Args }}<h2>Arguments</h2>
+<ul>
+{{ range .Args }}<li><code>{{ . }}</code></li>
+{{ end }}</ul>
+{{ end }}{{ if .Properties }}<h2>Properties</h2>
+<table>
+<tr><th>Name</th><th>Type</th><th>Default</th><th>Required</th><th>Description</th></tr>
+{{ range .Properties }}<tr><td><code>{{ .Name }}</code></td><td>{{ .Type }}</td><td>{{ if .Default }}<code>{{ .Default }}</code>{{ end }}</td><td>{{ if .Required }}yes{{ end }}</td><td>{{ .Description }}</td></tr>
+{{ end }}</table>
+{{ end }}{{ if .Dependencies }}<h2>Dependencies</h2>
+<table>
+<tr><th>Name</th><th>URL</th><th>Constraint</th><th>Namespace</th></tr>
+{{ range .Dependencies }}<tr><td><code>{{ .Name }}</code></td><td>{{ .URL }}</td><td>{{ .Constraint }}</td><td>{{ .Namespace }}</td></tr>
+{{ end }}</table>
+{{ end }}{{ if .SubCharts }}<h2>Sub charts</h2>
+<table>
+<tr><th>Name</th><th>Chart</th><th>Version</th></tr>
+{{ range .SubCharts }}<tr><td><code>{{ .Name }}</code></td><td>{{ .Chart }}</td><td>{{ .Version }}</td></tr>
+{{ end }}</table>
+{{ end }}{{ if .Jewels }}<h2>Secrets</h2>
+<table>
+<tr><th>Name</th><th>Kind</th><th>Secret</th></tr>
+{{ range .Jewels }}<tr><td><code>{{ .Name }}</code></td><td>{{ .Kind }}</td><td>{{ .Secret }}</td></tr>
+{{ end }}</table>
+{{ end }}</body>
+</html>
+`
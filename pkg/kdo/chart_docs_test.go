@@ -0,0 +1,81 @@
+package kdo
+
+import (
+	"bytes"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Chart docs", func() {
+	var dir TestDir
+	var c *chartImpl
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("chart/sub", 0755)
+		dir.WriteFile("chart/Chart.yaml", []byte("name: chart\nversion: 1.0.0\ndescription: A test chart\n"), 0644)
+		dir.WriteFile("chart/sub/Chart.star", []byte(""), 0644)
+		dir.WriteFile("chart/Chart.star", []byte(`
+def init(self, domain):
+	self.port = property(type="int", default=80, description="Port of the <service>", required=True)
+	self.db = struct_property(password = property(secret=True, default="xxx"), user = property())
+	self.base = depends_on("base", ">= 1.0")
+	self.sub = chart("sub")
+	self.credential = user_credential("db-credential")
+`), 0644)
+		repo, _ := NewRepo()
+		var err error
+		c, err = newChart(thread, repo, dir.Join("chart"), WithArgs(starlark.Tuple{starlark.String("example.com")}))
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("loads charts without the arguments of init", func() {
+		repo, _ := NewRepo()
+		value, err := repo.Get(thread, dir.Join("chart"), WithPlaceholderArgs(true))
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(value.(*chartImpl).Docs(buf, "markdown")).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("| `port` | int | `80` | yes | Port of the <service> |"))
+		Expect(value.(*chartImpl).Schema()).NotTo(BeNil())
+	})
+
+	It("renders markdown", func() {
+		buf := &bytes.Buffer{}
+		Expect(c.Docs(buf, "markdown")).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("# chart 1.0.0\n\nA test chart\n"))
+		Expect(buf.String()).To(ContainSubstring("* `domain`"))
+		Expect(buf.String()).To(ContainSubstring("| `port` | int | `80` | yes | Port of the <service> |"))
+		Expect(buf.String()).To(ContainSubstring("| `db.password` |  | `" + redactedValue + "` |  |  |"))
+		Expect(buf.String()).To(ContainSubstring("| `db.user` |  |  |  |  |"))
+		Expect(buf.String()).To(ContainSubstring("| `base` | base | >=1.0 | default |"))
+		Expect(buf.String()).To(ContainSubstring("| `sub` | sub |  |"))
+		Expect(buf.String()).To(ContainSubstring("| `credential` | user_credential | db-credential |"))
+	})
+	It("escapes table cells in markdown", func() {
+		dir.WriteFile("chart/Chart.star", []byte(`
+def init(self):
+	self.mode = property(enum=["a|b"], default="a|b", description="Either a|b\nor nothing")
+`), 0644)
+		repo, _ := NewRepo()
+		value, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(value.(*chartImpl).Docs(buf, "markdown")).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("| `mode` |  | `\"a\\|b\"` |  | Either a\\|b<br>or nothing |"))
+	})
+	It("renders html", func() {
+		buf := &bytes.Buffer{}
+		Expect(c.Docs(buf, "html")).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("<td>Port of the &lt;service&gt;</td>"))
+	})
+	It("rejects unknown formats", func() {
+		Expect(c.Docs(&bytes.Buffer{}, "pdf")).To(HaveOccurred())
+	})
+})
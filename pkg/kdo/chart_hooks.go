@@ -0,0 +1,84 @@
+package kdo
+
+import (
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+)
+
+// templateWithHooks renders the templates of the chart and separates the objects annotated with helm.sh/hook. The
+// rendered objects are validated and checked against the policies before, if check is set.
+func (c *chartImpl) templateWithHooks(thread *starlark.Thread, glob string, k k8s.K8s, check bool) (k8s.ObjectStream, []helmHook, error) {
+	stream := k8s.Decode(c.template(thread, glob, k))
+	if check {
+		stream = c.validate(stream, k)
+	}
+	var objects []*k8s.Object
+	err := stream(func(obj *k8s.Object) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if check {
+		if err := c.checkPolicies(thread, objects, c.warnings); err != nil {
+			return nil, nil, err
+		}
+	}
+	manifest, hooks, err := splitHooks(objects)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifestStream(manifest), hooks, nil
+}
+
+// hookEvent returns upgrade, if the chart is already installed, install otherwise
+func (c *chartImpl) hookEvent(k k8s.K8sReader) (string, error) {
+	obj, err := k.Get("configmap", c.objName(), &k8s.Options{Namespace: c.namespace, IgnoreNotFound: true, Quiet: true})
+	if err != nil {
+		return "", err
+	}
+	if obj == nil {
+		return "install", nil
+	}
+	return "upgrade", nil
+}
+
+// applyWithHooks applies the objects of the chart and runs the install or upgrade hooks around them
+func (c *chartImpl) applyWithHooks(thread *starlark.Thread, k k8s.K8s, k8sOptions *k8s.Options, glob string) error {
+	objects, hooks, err := c.templateWithHooks(thread, glob, k, true)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return k.Apply(objects, k8sOptions)
+	}
+	event, err := c.hookEvent(k)
+	if err != nil {
+		return err
+	}
+	engine := newHelmEngine(c, k)
+	if err := engine.runHooks(hooks, "pre-"+event); err != nil {
+		return err
+	}
+	if err := k.Apply(objects, k8sOptions); err != nil {
+		return err
+	}
+	return engine.runHooks(hooks, "post-"+event)
+}
+
+// deleteWithHooks deletes the objects of the chart and runs the delete hooks around them
+func (c *chartImpl) deleteWithHooks(thread *starlark.Thread, k k8s.K8s, k8sOptions *k8s.Options, glob string) error {
+	objects, hooks, err := c.templateWithHooks(thread, glob, k, false)
+	if err != nil {
+		return err
+	}
+	engine := newHelmEngine(c, k)
+	if err := engine.runHooks(hooks, "pre-delete"); err != nil {
+		return err
+	}
+	if err := k.Delete(objects, k8sOptions); err != nil {
+		return err
+	}
+	return engine.runHooks(hooks, "post-delete")
+}
@@ -0,0 +1,98 @@
+package kdo
+
+import (
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Chart hooks", func() {
+	var dir TestDir
+	var chart *chartImpl
+	var kim *k8s.K8sInMemory
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("app/templates", 0755)
+		dir.WriteFile("app/Chart.yaml", []byte("name: app\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("app/templates/objects.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-delete-policy: hook-succeeded
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: upgraded
+  annotations:
+    helm.sh/hook: post-upgrade
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cleanup
+  annotations:
+    helm.sh/hook: pre-delete
+`), 0644)
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		value, err := NewChartFunction(repo, dir.Root(), WithNamespace("ns"))(thread, nil, starlark.Tuple{starlark.String("app")}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		chart = value.(*chartImpl)
+		kim = k8s.NewK8sInMemory("ns")
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	exists := func(kind string, name string) bool {
+		_, err := kim.Get(kind, name, &k8s.Options{Namespace: "ns"})
+		if kim.IsNotExist(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	It("runs install and upgrade hooks", func() {
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		Expect(exists("configmap", "config")).To(BeTrue())
+		Expect(exists("job", "migrate")).To(BeFalse())
+		Expect(exists("configmap", "upgraded")).To(BeFalse())
+		Expect(exists("configmap", "cleanup")).To(BeFalse())
+
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		Expect(exists("configmap", "upgraded")).To(BeTrue())
+	})
+
+	It("runs delete hooks", func() {
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		Expect(chart.Delete(thread, kim, &DeleteOptions{})).To(Succeed())
+		Expect(exists("configmap", "config")).To(BeFalse())
+		Expect(exists("configmap", "cleanup")).To(BeTrue())
+	})
+
+	It("separates hooks from the other objects", func() {
+		objects, hooks, err := chart.templateWithHooks(thread, "", kim, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hooks).To(HaveLen(3))
+		names := []string{}
+		Expect(objects(func(obj *k8s.Object) error {
+			names = append(names, obj.MetaData.Name)
+			return nil
+		})).To(Succeed())
+		Expect(names).To(ConsistOf("config"))
+	})
+})
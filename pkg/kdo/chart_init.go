@@ -10,6 +10,7 @@ import (
 
 	"github.com/k14s/starlark-go/starlark"
 	"github.com/k14s/starlark-go/starlarkstruct"
+	"github.com/k14s/starlark-go/syntax"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
 )
@@ -81,6 +82,7 @@ func NewChartFunction(repo Repo, dir string, options ...ChartOption) func(thread
 		}
 
 		co := chartOptions(options)
+		co.helmDependencies = false
 		parser := &starutils.KwArgsParser{KwArgs: kwargs}
 		parser.Arg("namespace", func(value starlark.Value) {
 			co.namespace = value.(starlark.String).GoString()
@@ -88,6 +90,9 @@ func NewChartFunction(repo Repo, dir string, options ...ChartOption) func(thread
 		parser.Arg("suffix", func(value starlark.Value) {
 			co.suffix = value.(starlark.String).GoString()
 		})
+		parser.Arg("helm_dependencies", func(value starlark.Value) {
+			co.helmDependencies = bool(value.Truth())
+		})
 		WithKwArgs(parser.Parse())(co)
 		return repo.Get(thread, url, co.Merge())
 	}
@@ -113,7 +118,7 @@ func (c *chartImpl) init(thread *starlark.Thread, hasChartYaml bool, co *ChartOp
 			return fmt.Errorf("Neither Chart.star nor Chart.yaml nor values.yaml exists in %s", c.dir)
 		}
 	} else {
-		usedBy := func() string { return fmt.Sprintf("%s-%s", c.namespace, c.genus) }
+		usedBy := func() string { return chartReference(c.namespace, c.genus) }
 		internal := starlark.StringDict{
 			"version":         starlark.String(version),
 			"kube_version":    starlark.String(kubeVersion),
@@ -125,6 +130,7 @@ func (c *chartImpl) init(thread *starlark.Thread, hasChartYaml bool, co *ChartOp
 			"depends_on":      c.builtin("dependency", makeDependency(usedBy, c.repo, c.namespace)),
 			"property":        c.builtin("property", makeProperty),
 			"struct_property": c.builtin("struct_property", makeStructProperty),
+			"secret_ref":      c.builtin("secret_ref", makeSecretRef),
 			"struct":          starlark.NewBuiltin("struct", starlarkstruct.Make),
 			"inject":          starlark.NewBuiltin("inject", makeInjectedFiles(c.dir)),
 		}
@@ -144,7 +150,15 @@ func (c *chartImpl) init(thread *starlark.Thread, hasChartYaml bool, co *ChartOp
 		}
 
 		if c.initFunc != nil {
-			_, err := starlark.Call(thread, c.initFunc, append([]starlark.Value{c}, co.args...), co.KwArgs(c.initFunc))
+			args := append([]starlark.Value{c}, co.args...)
+			kwargs := co.KwArgs(c.initFunc)
+			if co.placeholderArgs {
+				kwargs, err = placeholderArgs(file, len(args), kwargs)
+				if err != nil {
+					return err
+				}
+			}
+			_, err = starlark.Call(thread, c.initFunc, args, kwargs)
 			if err != nil {
 				return err
 			}
@@ -188,3 +202,28 @@ func (c *chartImpl) wrapNamespace(callable starlark.Callable) starlark.Callable
 
 	})
 }
+
+// placeholderArgs adds None for all parameters of init in file without default value, which aren't given by the first
+// positional arguments or by kwargs
+func placeholderArgs(file string, positional int, kwargs []starlark.Tuple) ([]starlark.Tuple, error) {
+	f, err := syntax.Parse(file, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	given := map[string]bool{}
+	for _, kwarg := range kwargs {
+		given[string(kwarg.Index(0).(starlark.String))] = true
+	}
+	for _, stmt := range f.Stmts {
+		def, ok := stmt.(*syntax.DefStmt)
+		if !ok || def.Name.Name != "init" {
+			continue
+		}
+		for i, param := range def.Params {
+			if ident, ok := param.(*syntax.Ident); ok && i >= positional && !given[ident.Name] {
+				kwargs = append(kwargs, starlark.Tuple{starlark.String(ident.Name), starlark.None})
+			}
+		}
+	}
+	return kwargs, nil
+}
@@ -2,6 +2,7 @@ package kdo
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -72,7 +73,7 @@ func (p *Properties) set(key string, value starlark.Value) {
 
 func (p *Properties) setWithMap(values map[string]interface{}) {
 	for k, v := range values {
-		p.set(k, starutils.ToStarlark(v))
+		p.set(k, toStarlarkWithSecretRefs(v))
 	}
 }
 
@@ -170,6 +171,31 @@ func (p propertiesEnvVar) Type() string {
 	return "properties-env"
 }
 
+type propertiesSecretVar struct {
+	properties *Properties
+}
+
+func (p propertiesSecretVar) String() string {
+	return p.properties.String()
+}
+
+// Set -
+func (p *propertiesSecretVar) Set(val string) error {
+	return parseSet(val, func(key string, value string) error {
+		ref, err := parseSecretRef(value)
+		if err != nil {
+			return err
+		}
+		p.properties.set(key, ref)
+		return nil
+	})
+}
+
+// Type -
+func (p propertiesSecretVar) Type() string {
+	return "secret-ref"
+}
+
 type propertiesFile struct {
 	properties *Properties
 }
@@ -201,6 +227,9 @@ type GenusAndVersion struct {
 
 func NewGenusAndVersion(url string) *GenusAndVersion {
 	var match []string
+	if g, err := parseGitReference(url); err == nil {
+		return extractGenusAndVersion(g.name(), g.ref)
+	}
 	if match = githubRelease.FindStringSubmatch(url); match != nil {
 		return extractGenusAndVersion(match[1], match[2])
 	}
@@ -216,18 +245,32 @@ func NewGenusAndVersion(url string) *GenusAndVersion {
 	if match = catalogURL.FindStringSubmatch(url); match != nil {
 		return extractGenusAndVersion(match[1], "")
 	}
+	if ref, err := parseHelmReference(url); err == nil {
+		return extractGenusAndVersion(ref.chart, ref.version)
+	}
+	if match = ociURL.FindStringSubmatch(url); match != nil {
+		return extractGenusAndVersion(match[1]+"/"+match[2], match[3])
+	}
 	return extractGenusAndVersion(path.Base(url), "")
 }
 
 // ChartOptions -
 type ChartOptions struct {
 	GenusAndVersion
-	namespace  string
-	suffix     string
-	args       starlark.Tuple
-	properties Properties
-	skipChart  bool
-	readOnly   bool
+	namespace   string
+	suffix      string
+	args        starlark.Tuple
+	properties  Properties
+	skipChart   bool
+	readOnly    bool
+	keyProvider KeyProvider
+	images      imageMappings
+	policies    policyConfig
+	warnings    io.Writer
+
+	helmDependencies bool
+	skipValidation   bool
+	placeholderArgs  bool
 }
 
 // ChartOption -
@@ -298,6 +341,38 @@ func WithSkipChart(value bool) ChartOption {
 	return func(options *ChartOptions) { options.skipChart = value }
 }
 
+func withKeyProvider(provider KeyProvider) ChartOption {
+	return func(options *ChartOptions) { options.keyProvider = provider }
+}
+
+func withImageMappings(images imageMappings) ChartOption {
+	return func(options *ChartOptions) { options.images = images }
+}
+
+func withPolicies(policies policyConfig) ChartOption {
+	return func(options *ChartOptions) { options.policies = policies }
+}
+
+func withWarnings(writer io.Writer) ChartOption {
+	return func(options *ChartOptions) { options.warnings = writer }
+}
+
+// WithHelmDependencies -
+func WithHelmDependencies(value bool) ChartOption {
+	return func(options *ChartOptions) { options.helmDependencies = value }
+}
+
+// WithPlaceholderArgs passes None for all arguments of init without default value, which aren't given. It's used to
+// inspect charts without installing them.
+func WithPlaceholderArgs(value bool) ChartOption {
+	return func(options *ChartOptions) { options.placeholderArgs = value }
+}
+
+// WithSkipValidation -
+func WithSkipValidation(value bool) ChartOption {
+	return func(options *ChartOptions) { options.skipValidation = value }
+}
+
 // WithReadOnly -
 func WithReadOnly(value bool) ChartOption {
 	return func(options *ChartOptions) { options.readOnly = value }
@@ -313,6 +388,7 @@ func (v *ChartOptions) AddFlags(flagsSet *pflag.FlagSet) {
 	flagsSet.Var(&propertiesYamlVar{properties: &v.properties}, "set-yaml", "Set values from respective YAML files (key=path).")
 	flagsSet.Var(&proeprtiesFileVar{properties: &v.properties}, "set-file", "Set values from respective files (key=path).")
 	flagsSet.Var(&propertiesEnvVar{properties: &v.properties}, "set-env", "Set values from respective environment variable (key=env).")
+	flagsSet.Var(&propertiesSecretVar{properties: &v.properties}, "set-secret", "Set values from respective kubernetes secrets (key=namespace/name#field).")
 	flagsSet.StringVarP(&v.namespace, "namespace", "n", defaultNamespace, "namespace for installation")
 	flagsSet.StringVarP(&v.suffix, "suffix", "s", "", "Suffix which is used to build the chart name")
 	flagsSet.VarP(&propertiesFile{properties: &v.properties}, "values", "f", "Load additional values from a file")
@@ -345,5 +421,8 @@ func chartOptions(opts []ChartOption) *ChartOptions {
 	if co.namespace == "" {
 		co.namespace = "default"
 	}
+	if co.warnings == nil {
+		co.warnings = os.Stderr
+	}
 	return &co
 }
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -37,13 +38,7 @@ func (c *chartImpl) packageHelm(writer io.Writer) error {
 	}); err != nil {
 		return err
 	}
-	args := make([]string, 0)
-	if c.initFunc != nil {
-		for i := 1; i < c.initFunc.NumParams(); i++ {
-			arg, _ := c.initFunc.Param(i)
-			args = append(args, arg)
-		}
-	}
+	args := c.initParams()
 	// add properties
 	for _, t := range c.GetValueOrDefault().(starlark.IterableMapping).Items() {
 		args = append(args, t.Index(0).(starlark.String).GoString())
@@ -139,6 +134,9 @@ func tarExtract(in io.Reader, dir string, prefix *regexp.Regexp) error {
 			continue
 		}
 		fn := path.Join(dir, prefix.ReplaceAllString(hdr.Name, ""))
+		if !strings.HasPrefix(fn, path.Clean(dir)+"/") {
+			return fmt.Errorf("Invalid archive entry %s outside of the target directory", hdr.Name)
+		}
 		if err := os.MkdirAll(path.Dir(fn), 0755); err != nil {
 			return err
 		}
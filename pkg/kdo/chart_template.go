@@ -2,12 +2,15 @@ package kdo
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	gotemplate "text/template"
 
 	"github.com/pkg/errors"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
@@ -20,6 +23,7 @@ import (
 
 	"github.com/k14s/ytt/pkg/cmd/template"
 	"github.com/k14s/ytt/pkg/workspace"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type release struct {
@@ -49,6 +53,18 @@ type kubeVersions struct {
 	Version    string
 }
 
+// helmContext - the data passed to helm templates
+type helmContext struct {
+	Values       map[string]interface{}
+	Methods      map[string]interface{}
+	Chart        chart
+	Release      release
+	Files        renderer.Files
+	Template     templateSpec
+	Capabilities capabilities
+	K8s          k8s.K8s
+}
+
 type apiVersions []string
 
 func (a apiVersions) Has(version string) bool {
@@ -66,6 +82,12 @@ type capabilities struct {
 }
 
 func (c *chartImpl) Template(thread *starlark.Thread, k k8s.K8s) k8s.Stream {
+	// secrets referenced by properties are never revealed in template output
+	thread.SetLocal("redact-secrets", true)
+	defer thread.SetLocal("redact-secrets", nil)
+	if err := c.checkRequired(); err != nil {
+		return k8s.ErrorStream(err)
+	}
 	streams := []k8s.Stream{}
 	err := c.eachSubChart(func(subChart *chartImpl) error {
 		streams = append(streams, subChart.template(thread, "", k))
@@ -78,7 +100,12 @@ func (c *chartImpl) Template(thread *starlark.Thread, k k8s.K8s) k8s.Stream {
 	return k8s.YamlConcat(streams...)
 }
 
+// template renders the objects of the chart. Secret references are resolved before, so all template engines and
+// starlark code see their content.
 func (c *chartImpl) template(thread *starlark.Thread, glob string, k k8s.K8s) k8s.Stream {
+	if err := c.resolveSecretRefs(thread, k); err != nil {
+		return k8s.ErrorStream(err)
+	}
 	kwargs := []starlark.Tuple{}
 	template := c.methods["template"]
 	templateFunction, ok := template.(*chartMethod)
@@ -95,7 +122,7 @@ func (c *chartImpl) template(thread *starlark.Thread, glob string, k k8s.K8s) k8
 			kwargs = append(kwargs, starlark.Tuple{starlark.String("glob"), starlark.String(glob)})
 		}
 	}
-	return k8s.YamlConcat(c.jewelStream().Encode(), k8s.ToStream(starlark.Call(thread, template, nil, kwargs)))
+	return c.relocateImages(k8s.YamlConcat(c.jewelStream().Encode(), k8s.ToStream(starlark.Call(thread, template, nil, kwargs))), thread)
 }
 
 func (c *chartImpl) helmTemplateFunction() starlark.Callable {
@@ -106,7 +133,7 @@ func (c *chartImpl) helmTemplateFunction() starlark.Callable {
 		if err := starlark.UnpackArgs("helm", args, kwargs, "dir", &dir, "glob?", &glob, "k8s?", &k); err != nil {
 			return nil, err
 		}
-		s := c.helmTemplate(thread, dir, glob, k8sFromValue(k))
+		s := c.helmTemplate(thread, dir, glob, k8sFromValue(k), false)
 		return k8s.NewStreamValue(s), nil
 	})
 }
@@ -119,7 +146,7 @@ func (c *chartImpl) templateFunction() starlark.Callable {
 			return nil, err
 		}
 		k := k8sFromValue(v)
-		s := c.helmTemplate(thread, "templates", glob, k)
+		s := c.helmTemplate(thread, "templates", glob, k, true)
 		yttTemplateDir := path.Join(c.dir, "ytt-templates")
 		if _, err := os.Stat(yttTemplateDir); err == nil {
 			s = k8s.YamlConcat(s, c.yttTemplate(thread, starlark.Tuple{
@@ -133,7 +160,9 @@ func (c *chartImpl) templateFunction() starlark.Callable {
 	})
 }
 
-func (c *chartImpl) helmTemplate(thread *starlark.Thread, dir string, glob string, k k8s.K8s) k8s.Stream {
+// helmTemplate renders the helm templates in dir. With sources, each document is preceded by a comment with the file
+// and line it was rendered from.
+func (c *chartImpl) helmTemplate(thread *starlark.Thread, dir string, glob string, k k8s.K8s, sources bool) k8s.Stream {
 	values := starutils.StringDictToGo(c.values)
 	methods := make(map[string]interface{})
 	for k, f := range c.methods {
@@ -143,16 +172,7 @@ func (c *chartImpl) helmTemplate(thread *starlark.Thread, dir string, glob strin
 			return value, err
 		}
 	}
-	helmFileRenderer := renderer.HelmFileRenderer(c.path(), struct {
-		Values       interface{}
-		Methods      map[string]interface{}
-		Chart        chart
-		Release      release
-		Files        renderer.Files
-		Template     templateSpec
-		Capabilities capabilities
-		K8s          k8s.K8s
-	}{
+	context := helmContext{
 		Values:  values,
 		Methods: methods,
 		Chart: chart{
@@ -160,29 +180,24 @@ func (c *chartImpl) helmTemplate(thread *starlark.Thread, dir string, glob strin
 			AppVersion: c.GetVersionString(),
 			Version:    c.GetVersionString(),
 		},
-		Release: release{
-			Name:      c.GetName(),
-			Namespace: c.namespace,
-			Service:   c.GetName(),
-			Revision:  1,
-			IsInstall: false,
-			IsUpgrade: true,
-		},
+		Release: c.helmRelease(thread),
 		Template: templateSpec{
 			BasePath: ".",
 		},
-		Capabilities: capabilities{
-			APIVersions: apiVersions{"v1"},
-			KubeVersion: kubeVersions{
-				GitVersion: kubeSemver.String(),
-				Version:    kubeSemver.String(),
-				Major:      int(kubeSemver.Major()),
-				Minor:      int(kubeSemver.Minor()),
-			},
-		},
-		Files: renderer.Files{Dir: c.dir},
-		K8s:   k,
-	})
+		Capabilities: helmCapabilities(k),
+		Files:        renderer.Files{Dir: c.dir},
+		K8s:          k,
+	}
+	funcs := gotemplate.FuncMap{"lookup": lookup(k)}
+	if c.helmDependencies && dir == "templates" {
+		context.Values = helmValues(c.values)
+		return c.helmDependencyTemplate(context, glob, funcs, sources)
+	}
+	helmFileRenderer := renderer.HelmFileRenderer(c.path(), context, funcs)
+	source := ""
+	if sources {
+		source = path.Clean(dir)
+	}
 
 	return func(writer io.Writer) error {
 
@@ -190,11 +205,68 @@ func (c *chartImpl) helmTemplate(thread *starlark.Thread, dir string, glob strin
 			renderer.DirSpec{
 				Dir:          path.Join(c.dir, dir),
 				FileRenderer: helmFileRenderer,
+				Source:       source,
 			})(writer)
 
 	}
 }
 
+// helmCapabilities returns the capabilities of the cluster or the defaults of kdo, if it isn't connected or can't
+// be reached, e.g. while templating without access to the cluster
+func helmCapabilities(k k8s.K8s) capabilities {
+	version := kubeSemver
+	versions := k8s.KnownAPIVersions()
+	if k != nil {
+		if discovered, err := k.Capabilities(); err == nil && discovered != nil {
+			version = discovered.KubeVersion
+			versions = discovered.APIVersions
+		}
+	}
+	return capabilities{
+		APIVersions: apiVersions(versions),
+		KubeVersion: kubeVersions{
+			GitVersion: version.Original(),
+			Version:    version.Original(),
+			Major:      int(version.Major()),
+			Minor:      int(version.Minor()),
+		},
+	}
+}
+
+// lookup returns an object or a list of objects as map like the helm function lookup. A missing object is returned
+// as empty map.
+func lookup(k k8s.K8sReader) func(apiVersion string, kind string, namespace string, name string) (map[string]interface{}, error) {
+	return func(apiVersion string, kind string, namespace string, name string) (map[string]interface{}, error) {
+		resource := strings.ToLower(kind)
+		if parts := strings.SplitN(apiVersion, "/", 2); len(parts) == 2 {
+			resource += "." + parts[0]
+		}
+		options := &k8s.Options{Namespace: namespace, ClusterScoped: namespace == "", IgnoreNotFound: true, Quiet: true}
+		var obj *k8s.Object
+		var err error
+		if name == "" {
+			obj, err = k.List(resource, options, &k8s.ListOptions{LabelSelector: labels.Everything(), AllNamespaces: namespace == ""})
+		} else {
+			obj, err = k.Get(resource, name, options)
+		}
+		if err != nil {
+			if k.IsNotExist(err) {
+				return map[string]interface{}{}, nil
+			}
+			return nil, err
+		}
+		result := map[string]interface{}{}
+		if obj == nil {
+			return result, nil
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		return result, json.Unmarshal(data, &result)
+	}
+}
+
 func (c *chartImpl) yttTemplate(thread *starlark.Thread, fileTuple starlark.Tuple) k8s.Stream {
 	return func(writer io.Writer) error {
 		context := injectedContext{}
@@ -318,7 +318,49 @@ def init(self):
 			buf := &bytes.Buffer{}
 			err := c.Template(thread, k8s.NewK8sInMemoryEmpty())(buf)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(buf.String()).To(Equal("---\nnamespace: namespace\n"))
+			Expect(buf.String()).To(Equal("---\n# Source: templates/deployment.yaml:1\nnamespace: namespace\n"))
+		})
+
+		It("writes the template line of each document", func() {
+			dir.WriteFile("templates/deployment.yaml", []byte(`{{- /* comment
+spanning lines */}}
+kind: ConfigMap
+---
+{{- range list "a" "b" }}
+kind: {{ . }}
+---
+{{- end }}
+kind: Last
+`), 0644)
+			buf := &bytes.Buffer{}
+			Expect(c.Template(thread, k8s.NewK8sInMemoryEmpty())(buf)).To(Succeed())
+			Expect(buf.String()).To(Equal("---\n# Source: templates/deployment.yaml:3\nkind: ConfigMap\n" +
+				"---\n# Source: templates/deployment.yaml:6\nkind: a\n" +
+				"---\n# Source: templates/deployment.yaml:6\nkind: b\n" +
+				"---\n# Source: templates/deployment.yaml:9\nkind: Last\n\n"))
+		})
+
+		It("reports the template of invalid documents", func() {
+			dir.WriteFile("templates/deployment.yaml", []byte("kind: ConfigMap\n---\nkind: [\n"), 0644)
+			err := c.Apply(thread, k8s.NewK8sInMemory("namespace"))
+			Expect(err).To(MatchError(ContainSubstring("templates/deployment.yaml:3: ")))
+		})
+
+		It("templates with capabilities and objects of the cluster", func() {
+			dir.WriteFile("templates/deployment.yaml", []byte(`kube: {{ .Capabilities.KubeVersion.Version }}
+apps: {{ .Capabilities.APIVersions.Has "apps/v1" }}
+secret: {{ (lookup "v1" "Secret" "namespace" "db").metadata.name }}
+missing: {{ lookup "v1" "Secret" "namespace" "missing" | len }}`), 0644)
+			buf := &bytes.Buffer{}
+			Expect(c.Template(thread, k8s.NewK8sInMemoryEmpty())(buf)).To(Succeed())
+			Expect(buf.String()).To(ContainSubstring("kube: " + kubeVersion))
+			Expect(buf.String()).To(ContainSubstring("apps: true"))
+
+			kim := k8s.NewK8sInMemory("namespace", k8s.Object{APIVersion: "v1", Kind: "Secret", MetaData: k8s.MetaData{Name: "db", Namespace: "namespace"}})
+			kim.SetCapabilities(&k8s.Capabilities{KubeVersion: semver.MustParse("v1.21.3"), APIVersions: []string{"v1"}})
+			buf.Reset()
+			Expect(c.Template(thread, kim)(buf)).To(Succeed())
+			Expect(buf.String()).To(Equal("---\n# Source: templates/deployment.yaml:1\nkube: v1.21.3\napps: false\nsecret: db\nmissing: 0\n"))
 		})
 
 		It("applies a chart", func() {
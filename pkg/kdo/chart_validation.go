@@ -0,0 +1,25 @@
+package kdo
+
+import (
+	"github.com/Masterminds/semver/v3"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+)
+
+// validate returns a stream, which fails, if the objects use apis not available in the kubernetes version of k or don't
+// match the OpenAPI schemas of the cluster
+func (c *chartImpl) validate(objects k8s.ObjectStream, k k8s.K8s) k8s.ObjectStream {
+	if c.skipValidation {
+		return objects
+	}
+	return objects.Validate(k8s.NewValidator(clusterKubeVersion(k), k, c.warnings))
+}
+
+// clusterKubeVersion returns the discovered kubernetes version of k or the version kdo was built for
+func clusterKubeVersion(k k8s.K8s) *semver.Version {
+	if k != nil {
+		if discovered, err := k.Capabilities(); err == nil && discovered != nil && discovered.KubeVersion != nil {
+			return discovered.KubeVersion
+		}
+	}
+	return kubeSemver
+}
@@ -0,0 +1,61 @@
+package kdo
+
+import (
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Chart validation", func() {
+	var dir TestDir
+	var kim *k8s.K8sInMemory
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("app/templates", 0755)
+		dir.WriteFile("app/Chart.yaml", []byte("name: app\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("app/templates/objects.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  prots:
+  - port: 80
+`), 0644)
+		kim = k8s.NewK8sInMemory("ns")
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	load := func(options ...ChartOption) *chartImpl {
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		value, err := NewChartFunction(repo, dir.Root(), append(options, WithNamespace("ns"))...)(thread, nil, starlark.Tuple{starlark.String("app")}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return value.(*chartImpl)
+	}
+
+	It("doesn't apply invalid objects", func() {
+		err := load().Apply(thread, kim)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("templates/objects.yaml:7 Service app: spec.prots: unknown field"))
+		_, err = kim.Get("configmap", "config", &k8s.Options{Namespace: "ns"})
+		Expect(kim.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("applies invalid objects, if validation is skipped", func() {
+		Expect(load(WithSkipValidation(true)).Apply(thread, kim)).To(Succeed())
+		_, err := kim.Get("service", "app", &k8s.Options{Namespace: "ns"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,317 @@
+package kdo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+)
+
+// ChangeKind -
+type ChangeKind int
+
+const (
+	// Compatible changes don't affect existing users of a chart
+	Compatible ChangeKind = iota
+	// Deprecated changes still work, but will break in a future version
+	Deprecated
+	// Breaking changes require changes of existing users of a chart
+	Breaking
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Deprecated:
+		return "DEPRECATED"
+	case Breaking:
+		return "BREAKING"
+	}
+	return "COMPATIBLE"
+}
+
+// Change - a change of a property between two versions of a chart
+type Change struct {
+	Path    string
+	Kind    ChangeKind
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Kind, c.Path, c.Message)
+}
+
+// CompatReport - result of the comparison of the properties of two chart versions
+type CompatReport struct {
+	OldVersion *semver.Version
+	NewVersion *semver.Version
+	Changes    []Change
+}
+
+// CompareCharts compares the properties of two versions of a chart
+func CompareCharts(old Chart, new Chart) *CompatReport {
+	return &CompatReport{
+		OldVersion: old.GetVersion(),
+		NewVersion: new.GetVersion(),
+		Changes:    compareSchemas("", old.Schema(), new.Schema()),
+	}
+}
+
+func compareSchemas(path string, old map[string]interface{}, new map[string]interface{}) []Change {
+	changes := make([]Change, 0)
+	add := func(kind ChangeKind, format string, args ...interface{}) {
+		changes = append(changes, Change{Path: path, Kind: kind, Message: fmt.Sprintf(format, args...)})
+	}
+	if old["type"] != nil && !reflect.DeepEqual(old["type"], new["type"]) {
+		// untyped properties accept values of any type
+		if new["type"] == nil {
+			add(Compatible, "type changed from %v to %v", old["type"], typeOrAny(new["type"]))
+		} else {
+			add(Breaking, "type changed from %v to %v", old["type"], new["type"])
+			return changes
+		}
+	}
+	if deprecated, _ := new["deprecated"].(bool); deprecated {
+		if wasDeprecated, _ := old["deprecated"].(bool); !wasDeprecated {
+			add(Deprecated, "property is deprecated")
+		}
+	}
+	if oldEnum, ok := old["enum"].([]interface{}); ok || new["enum"] != nil {
+		newEnum, _ := new["enum"].([]interface{})
+		if !ok && new["enum"] != nil {
+			add(Breaking, "values are restricted to %v", newEnum)
+		} else if newEnum != nil {
+			for _, value := range oldEnum {
+				if !containsValue(newEnum, value) {
+					add(Breaking, "value %v isn't allowed anymore", value)
+				}
+			}
+		}
+	}
+	if new["pattern"] != nil && !reflect.DeepEqual(old["pattern"], new["pattern"]) {
+		add(Breaking, "pattern changed from %v to %v", old["pattern"], new["pattern"])
+	}
+	for _, key := range []string{"minimum", "minLength", "minItems"} {
+		if new[key] != nil && (old[key] == nil || toFloat(new[key]) > toFloat(old[key])) {
+			add(Breaking, "%s raised from %v to %v", key, old[key], new[key])
+		}
+	}
+	for _, key := range []string{"maximum", "maxLength", "maxItems"} {
+		if new[key] != nil && (old[key] == nil || toFloat(new[key]) < toFloat(old[key])) {
+			add(Breaking, "%s lowered from %v to %v", key, old[key], new[key])
+		}
+	}
+	if old["default"] != nil && !reflect.DeepEqual(old["default"], new["default"]) {
+		add(Compatible, "default changed from %v to %v", old["default"], new["default"])
+	}
+	if additional, ok := new["additionalProperties"].(bool); ok && !additional {
+		if oldAdditional, ok := old["additionalProperties"].(bool); ok && oldAdditional {
+			add(Breaking, "additional properties aren't allowed anymore")
+		}
+	}
+	oldProperties, _ := old["properties"].(map[string]interface{})
+	newProperties, _ := new["properties"].(map[string]interface{})
+	oldRequired := stringSet(old["required"])
+	newRequired := stringSet(new["required"])
+	for _, name := range sortedKeys(oldProperties, newProperties) {
+		childPath := name
+		if path != "" {
+			childPath = path + "." + name
+		}
+		oldProperty, inOld := oldProperties[name].(map[string]interface{})
+		newProperty, inNew := newProperties[name].(map[string]interface{})
+		switch {
+		case inOld && !inNew:
+			changes = append(changes, Change{Path: childPath, Kind: Breaking, Message: "property removed"})
+		case !inOld && inNew:
+			if newRequired[name] && newProperty["default"] == nil {
+				changes = append(changes, Change{Path: childPath, Kind: Breaking, Message: "new required property"})
+			} else {
+				changes = append(changes, Change{Path: childPath, Kind: Compatible, Message: "property added"})
+			}
+		default:
+			if newRequired[name] && !oldRequired[name] && newProperty["default"] == nil {
+				changes = append(changes, Change{Path: childPath, Kind: Breaking, Message: "property is required"})
+			}
+			changes = append(changes, compareSchemas(childPath, oldProperty, newProperty)...)
+		}
+	}
+	return changes
+}
+
+func typeOrAny(typ interface{}) interface{} {
+	if typ == nil {
+		return "any"
+	}
+	return typ
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringSet(value interface{}) map[string]bool {
+	result := map[string]bool{}
+	switch v := value.(type) {
+	case []string:
+		for _, s := range v {
+			result[s] = true
+		}
+	case []interface{}:
+		for _, s := range v {
+			result[fmt.Sprint(s)] = true
+		}
+	}
+	return result
+}
+
+func sortedKeys(maps ...map[string]interface{}) []string {
+	set := map[string]bool{}
+	for _, m := range maps {
+		for k := range m {
+			set[k] = true
+		}
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *CompatReport) changes(kind ChangeKind) []Change {
+	result := make([]Change, 0)
+	for _, c := range r.Changes {
+		if c.Kind == kind {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// HasBreakingChanges -
+func (r *CompatReport) HasBreakingChanges() bool {
+	return len(r.changes(Breaking)) != 0
+}
+
+// IsMajorUpgrade returns true, if the version bump allows breaking changes. For versions 0.x a minor bump is sufficient.
+func (r *CompatReport) IsMajorUpgrade() bool {
+	if r.OldVersion == nil || r.NewVersion == nil {
+		return false
+	}
+	if r.NewVersion.Major() > r.OldVersion.Major() {
+		return true
+	}
+	return r.OldVersion.Major() == 0 && r.NewVersion.Major() == 0 && r.NewVersion.Minor() > r.OldVersion.Minor()
+}
+
+// IsDowngrade returns true, if an older version replaces a newer one, e.g. during a rollback
+func (r *CompatReport) IsDowngrade() bool {
+	if r.OldVersion == nil || r.NewVersion == nil {
+		return false
+	}
+	return r.NewVersion.LessThan(r.OldVersion)
+}
+
+// Check returns an error, if breaking changes aren't allowed by the version bump. Downgrades aren't checked.
+func (r *CompatReport) Check() error {
+	breaking := r.changes(Breaking)
+	if len(breaking) == 0 || r.IsMajorUpgrade() || r.IsDowngrade() {
+		return nil
+	}
+	messages := make([]string, 0, len(breaking))
+	for _, c := range breaking {
+		messages = append(messages, c.String())
+	}
+	return fmt.Errorf("Incompatible property changes from version %v to %v require a major version bump:\n%s", r.OldVersion, r.NewVersion, strings.Join(messages, "\n"))
+}
+
+// Warnings returns the deprecated changes and the breaking changes allowed by a major version bump or a downgrade
+func (r *CompatReport) Warnings() []Change {
+	warnings := r.changes(Deprecated)
+	if r.IsMajorUpgrade() || r.IsDowngrade() {
+		warnings = append(warnings, r.changes(Breaking)...)
+	}
+	return warnings
+}
+
+// Write -
+func (r *CompatReport) Write(writer io.Writer) error {
+	for _, c := range r.Changes {
+		if _, err := fmt.Fprintln(writer, c.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCompatibility compares the properties with the schema persisted by the installed version of the chart. The
+// installed chart isn't instantiated, because its init arguments aren't known.
+func (c *chartImpl) checkCompatibility(k k8s.K8s, warnings io.Writer) error {
+	obj, err := k.Get("configmap", c.objName(), &k8s.Options{Namespace: c.namespace, IgnoreNotFound: true, Quiet: true})
+	if err != nil {
+		return err
+	}
+	if obj == nil || obj.Additional["data"] == nil {
+		return nil
+	}
+	var data map[string]string
+	if err := json.Unmarshal(obj.Additional["data"], &data); err != nil {
+		return err
+	}
+	// charts installed by older versions of kdo don't persist their schema
+	if data["schema"] == "" {
+		return nil
+	}
+	var oldSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(data["schema"]), &oldSchema); err != nil {
+		return err
+	}
+	oldVersion, err := semver.NewVersion(data["version"])
+	if err != nil {
+		return err
+	}
+	// compare the json representation of both schemas
+	newJSON, err := json.Marshal(c.Schema())
+	if err != nil {
+		return err
+	}
+	var newSchema map[string]interface{}
+	if err := json.Unmarshal(newJSON, &newSchema); err != nil {
+		return err
+	}
+	report := &CompatReport{
+		OldVersion: oldVersion,
+		NewVersion: c.GetVersion(),
+		Changes:    compareSchemas("", oldSchema, newSchema),
+	}
+	if err := report.Check(); err != nil {
+		return err
+	}
+	for _, w := range report.Warnings() {
+		fmt.Fprintf(warnings, "Warning: %s\n", w.String())
+	}
+	return nil
+}
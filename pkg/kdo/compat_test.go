@@ -0,0 +1,154 @@
+package kdo
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Compat", func() {
+	var dir TestDir
+	thread := &starlark.Thread{Name: "main"}
+	var repo Repo
+
+	writeChart := func(version string, init string) string {
+		chartDir := version + "/chart"
+		dir.MkdirAll(chartDir+"/templates", 0755)
+		dir.WriteFile(chartDir+"/Chart.yaml", []byte(fmt.Sprintf("name: chart\nversion: %s\n", version)), 0644)
+		dir.WriteFile(chartDir+"/Chart.star", []byte("def init(self):\n"+init), 0644)
+		return dir.Join(chartDir)
+	}
+	compare := func(old string, new string) *CompatReport {
+		oldChart, err := repo.Get(thread, writeChart("1.0.0", old))
+		Expect(err).NotTo(HaveOccurred())
+		newChart, err := repo.Get(thread, writeChart("1.1.0", new))
+		Expect(err).NotTo(HaveOccurred())
+		return CompareCharts(oldChart, newChart)
+	}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		repo, _ = NewRepo()
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("classifies changes", func() {
+		report := compare(`
+	self.port = property(type="int")
+	self.mode = property(enum=["a","b"])
+	self.old = property()
+	self.db = struct_property(user = property(), host = property())
+`, `
+	self.port = property(type="string")
+	self.mode = property(enum=["a"])
+	self.db = struct_property(user = property(deprecated=True), host = property(), password = property(required=True))
+	self.optional = property()
+`)
+		Expect(report.Changes).To(ConsistOf(
+			Change{Path: "db.password", Kind: Breaking, Message: "new required property"},
+			Change{Path: "db.user", Kind: Deprecated, Message: "property is deprecated"},
+			Change{Path: "mode", Kind: Breaking, Message: "value b isn't allowed anymore"},
+			Change{Path: "old", Kind: Breaking, Message: "property removed"},
+			Change{Path: "optional", Kind: Compatible, Message: "property added"},
+			Change{Path: "port", Kind: Breaking, Message: "type changed from integer to string"},
+		))
+		Expect(report.HasBreakingChanges()).To(BeTrue())
+		Expect(report.Check()).To(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(report.Write(buf)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("BREAKING old: property removed\n"))
+	})
+
+	It("accepts compatible changes", func() {
+		report := compare(`
+	self.port = property(type="int", max=100, default=80)
+`, `
+	self.port = property(type="int", max=200, default=90)
+	self.host = property(required=True, default="localhost")
+`)
+		Expect(report.HasBreakingChanges()).To(BeFalse())
+		Expect(report.Check()).To(Succeed())
+	})
+
+	It("allows breaking changes with a major version bump", func() {
+		report := &CompatReport{OldVersion: semver.MustParse("1.2.0"), NewVersion: semver.MustParse("2.0.0"), Changes: []Change{{Path: "x", Kind: Breaking}}}
+		Expect(report.Check()).To(Succeed())
+		Expect(report.Warnings()).To(HaveLen(1))
+		report.OldVersion, report.NewVersion = semver.MustParse("0.1.0"), semver.MustParse("0.2.0")
+		Expect(report.Check()).To(Succeed())
+		report.OldVersion, report.NewVersion = semver.MustParse("1.1.0"), semver.MustParse("1.2.0")
+		Expect(report.Check()).To(HaveOccurred())
+	})
+
+	It("doesn't check downgrades", func() {
+		report := &CompatReport{OldVersion: semver.MustParse("2.0.0"), NewVersion: semver.MustParse("1.2.0"), Changes: []Change{{Path: "x", Kind: Breaking}}}
+		Expect(report.IsDowngrade()).To(BeTrue())
+		Expect(report.Check()).To(Succeed())
+		Expect(report.Warnings()).To(HaveLen(1))
+	})
+
+	It("accepts properties, which aren't typed anymore", func() {
+		report := compare(`
+	self.port = property(type="int", default=80)
+`, `
+	self.port = property(default=80)
+`)
+		Expect(report.Changes).To(ConsistOf(Change{Path: "port", Kind: Compatible, Message: "type changed from integer to any"}))
+		Expect(report.Check()).To(Succeed())
+	})
+
+	It("blocks incompatible upgrades during apply", func() {
+		kim := k8s.NewK8sInMemoryEmpty()
+		c, err := repo.Get(thread, writeChart("1.0.0", "\tself.port = property()\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Apply(thread, kim)).To(Succeed())
+		c, err = repo.Get(thread, writeChart("1.1.0", "\tpass\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Apply(thread, kim)).To(MatchError(ContainSubstring("BREAKING port: property removed")))
+		c, err = repo.Get(thread, writeChart("2.0.0", "\tpass\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Apply(thread, kim)).To(Succeed())
+	})
+
+	It("writes warnings to the writer of the repo", func() {
+		kim := k8s.NewK8sInMemoryEmpty()
+		warnings := &bytes.Buffer{}
+		repo, _ = NewRepo(WithWarnings(warnings))
+		c, err := repo.Get(thread, writeChart("1.0.0", "\tself.port = property()\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Apply(thread, kim)).To(Succeed())
+		c, err = repo.Get(thread, writeChart("1.1.0", "\tself.port = property(deprecated=True)\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Apply(thread, kim)).To(Succeed())
+		Expect(warnings.String()).To(Equal("Warning: DEPRECATED port: property is deprecated\n"))
+	})
+
+	It("reapplies charts with init arguments", func() {
+		kim := k8s.NewK8sInMemoryEmpty()
+		dir.MkdirAll("chart", 0755)
+		dir.WriteFile("chart/Chart.yaml", []byte("name: chart\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("chart/Chart.star", []byte("def init(self, domain):\n\tself.domain = property(default=domain)\n"), 0644)
+		for i := 0; i < 2; i++ {
+			c, err := repo.Get(thread, dir.Join("chart"), WithArgs(starlark.Tuple{starlark.String("example.com")}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Apply(thread, kim)).To(Succeed())
+		}
+	})
+
+	It("reapplies charts with int properties", func() {
+		kim := k8s.NewK8sInMemoryEmpty()
+		for i := 0; i < 2; i++ {
+			c, err := repo.Get(thread, writeChart("1.0.0", "\tself.port = property(type=\"int\")\n"), WithValues(map[string]interface{}{"port": 8080}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Apply(thread, kim)).To(Succeed())
+		}
+	})
+})
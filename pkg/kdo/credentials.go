@@ -0,0 +1,393 @@
+package kdo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+)
+
+// Credentials - the credentials sent with requests to a repository
+type Credentials struct {
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Token    string            `json:"token,omitempty"`
+	Bearer   string            `json:"bearer,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+// CredentialProvider - provides the credentials of urls, e.g. from a vault
+type CredentialProvider interface {
+	// Credentials returns the credentials of the url or nil, if there aren't any
+	Credentials(url string) (*Credentials, error)
+}
+
+// credential - an entry of the credentials in the kdo config. The credentials are given in the entry itself or
+// are read from environment variables, a netrc file, a credential helper or a kubernetes secret.
+type credential struct {
+	URL      string            `yaml:"url,omitempty"`
+	Token    string            `yaml:"token,omitempty"`
+	Username string            `yaml:"username,omitempty"`
+	Password string            `yaml:"password,omitempty"`
+	Bearer   string            `yaml:"bearer,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Env      string            `yaml:"env,omitempty"`
+	Netrc    string            `yaml:"netrc,omitempty"`
+	Command  []string          `yaml:"command,omitempty"`
+	Secret   string            `yaml:"secret,omitempty"`
+}
+
+type credentialSource struct {
+	url      string
+	provider CredentialProvider
+	headers  map[string]string
+}
+
+// credentials selects the credential provider with the longest matching url
+type credentials struct {
+	sources []credentialSource
+}
+
+func newCredentials(entries []credential, providers []credentialSource, secrets k8s.K8sReader) *credentials {
+	sources := make([]credentialSource, 0, len(entries)+len(providers))
+	for _, entry := range entries {
+		sources = append(sources, credentialSource{url: entry.URL, provider: entry.provider(secrets), headers: entry.Headers})
+	}
+	return &credentials{sources: append(sources, providers...)}
+}
+
+func (c credential) provider(secrets k8s.K8sReader) CredentialProvider {
+	switch {
+	case c.Env != "":
+		return envProvider(c.Env)
+	case c.Netrc != "":
+		return netrcProvider(c.Netrc)
+	case len(c.Command) != 0:
+		return &execProvider{command: c.Command, cache: map[string]*execCredentials{}}
+	case c.Secret != "":
+		return &secretProvider{secret: c.Secret, k8s: secrets}
+	}
+	return &staticProvider{Credentials{Username: c.Username, Password: c.Password, Token: c.Token, Bearer: c.Bearer}}
+}
+
+// invalidatingProvider - a credential provider caching credentials, which are dropped after they were rejected
+type invalidatingProvider interface {
+	invalidate(url string)
+}
+
+// source returns the source with the longest matching url prefix or nil. A git+ prefix of the configured url is
+// ignored.
+func (c *credentials) source(uri string) *credentialSource {
+	if c == nil {
+		return nil
+	}
+	maxMatch := -1
+	var bestMatch *credentialSource
+	for i, source := range c.sources {
+		u := strings.TrimPrefix(source.url, "git+")
+		if strings.HasPrefix(uri, u) && len(u) > maxMatch {
+			maxMatch = len(u)
+			bestMatch = &c.sources[i]
+		}
+	}
+	return bestMatch
+}
+
+// invalidate drops the cached credentials of the url. It returns false, if the provider doesn't cache credentials.
+func (c *credentials) invalidate(uri string) bool {
+	source := c.source(uri)
+	if source == nil {
+		return false
+	}
+	provider, ok := source.provider.(invalidatingProvider)
+	if ok {
+		provider.invalidate(uri)
+	}
+	return ok
+}
+
+// lookup returns the credentials of the provider with the longest matching url prefix or nil
+func (c *credentials) lookup(uri string) (*Credentials, error) {
+	bestMatch := c.source(uri)
+	if bestMatch == nil {
+		return nil, nil
+	}
+	creds, err := bestMatch.provider.Credentials(uri)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get credentials for %s: %s", uri, err.Error())
+	}
+	if len(bestMatch.headers) != 0 {
+		if creds == nil {
+			creds = &Credentials{}
+		}
+		headers := map[string]string{}
+		for k, v := range bestMatch.headers {
+			headers[k] = v
+		}
+		for k, v := range creds.Headers {
+			headers[k] = v
+		}
+		creds.Headers = headers
+	}
+	return creds, nil
+}
+
+// authorization returns the value of the authorization header
+func (c *Credentials) authorization() string {
+	switch {
+	case c.Bearer != "":
+		return "Bearer " + c.Bearer
+	case c.Token != "":
+		return "token " + c.Token
+	case c.Username != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+	}
+	return ""
+}
+
+// authorize adds the credentials with the longest matching url to the request
+func authorize(request *http.Request, credentials *credentials) error {
+	creds, err := credentials.lookup(request.URL.String())
+	if err != nil || creds == nil {
+		return err
+	}
+	if auth := creds.authorization(); auth != "" {
+		request.Header.Set("Authorization", auth)
+	}
+	for k, v := range creds.Headers {
+		request.Header.Set(k, v)
+	}
+	return nil
+}
+
+// doAuthorizedRequest sends a request with the credentials of its url. If the credentials are rejected and were
+// cached by the provider, the request is sent again with fresh credentials.
+func doAuthorizedRequest(client *http.Client, request *http.Request, credentials *credentials) (*http.Response, error) {
+	if err := authorize(request, credentials); err != nil {
+		return nil, err
+	}
+	res, err := doRequest(client, request)
+	if err != nil || res.StatusCode != http.StatusUnauthorized || !credentials.invalidate(request.URL.String()) {
+		return res, err
+	}
+	res.Body.Close()
+	if request.GetBody != nil {
+		if request.Body, err = request.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if err := authorize(request, credentials); err != nil {
+		return nil, err
+	}
+	return doRequest(client, request)
+}
+
+// retryBackoff are the delays between retries of requests failing with a 5xx status
+var retryBackoff = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+
+// doRequest sends a request and retries it with backoff as long as the server responds with a 5xx status
+func doRequest(client *http.Client, request *http.Request) (*http.Response, error) {
+	for i := 0; ; i++ {
+		res, err := client.Do(request)
+		if err != nil || res.StatusCode < 500 || i == len(retryBackoff) {
+			return res, err
+		}
+		res.Body.Close()
+		time.Sleep(retryBackoff[i])
+		if request.GetBody != nil {
+			if request.Body, err = request.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+type staticProvider struct {
+	credentials Credentials
+}
+
+func (p *staticProvider) Credentials(url string) (*Credentials, error) {
+	creds := p.credentials
+	return &creds, nil
+}
+
+// envProvider reads the credentials from the environment variables <prefix>_USERNAME, <prefix>_PASSWORD,
+// <prefix>_TOKEN and <prefix>_BEARER
+type envProvider string
+
+func (p envProvider) Credentials(url string) (*Credentials, error) {
+	creds := &Credentials{
+		Username: os.Getenv(string(p) + "_USERNAME"),
+		Password: os.Getenv(string(p) + "_PASSWORD"),
+		Token:    os.Getenv(string(p) + "_TOKEN"),
+		Bearer:   os.Getenv(string(p) + "_BEARER"),
+	}
+	if creds.authorization() == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+// netrcProvider reads the login of the host of an url from a netrc file
+type netrcProvider string
+
+func (p netrcProvider) Credentials(uri string) (*Credentials, error) {
+	filename := string(p)
+	if strings.HasPrefix(filename, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		filename = path.Join(home, filename[2:])
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	return parseNetrc(string(data), u.Hostname()), nil
+}
+
+// parseNetrc returns the login of a machine or the default login
+func parseNetrc(content string, host string) *Credentials {
+	var result, dflt, current *Credentials
+	fields := strings.Fields(content)
+	for i := 0; i < len(fields); i++ {
+		next := func() string {
+			if i+1 < len(fields) {
+				i++
+				return fields[i]
+			}
+			return ""
+		}
+		switch fields[i] {
+		case "machine":
+			current = nil
+			if next() == host && result == nil {
+				result = &Credentials{}
+				current = result
+			}
+		case "default":
+			current = nil
+			if dflt == nil {
+				dflt = &Credentials{}
+				current = dflt
+			}
+		case "login":
+			if login := next(); current != nil {
+				current.Username = login
+			}
+		case "password":
+			if password := next(); current != nil {
+				current.Password = password
+			}
+		case "account":
+			next()
+		}
+	}
+	if result == nil {
+		return dflt
+	}
+	return result
+}
+
+// execCredentialTTL is the time credentials of a credential helper are cached, if the helper doesn't return expiresAt
+var execCredentialTTL = 5 * time.Minute
+
+// execProvider runs a credential helper. The url is written to stdin, the credentials are read as json from stdout.
+// Besides the fields of Credentials, the output of docker credential helpers (Username and Secret) is accepted.
+// The credentials are cached until expiresAt (RFC 3339) of the output or for execCredentialTTL.
+type execProvider struct {
+	command []string
+	mutex   sync.Mutex
+	cache   map[string]*execCredentials
+}
+
+type execCredentials struct {
+	credentials *Credentials
+	expiresAt   time.Time
+}
+
+func (p *execProvider) Credentials(url string) (*Credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if cached, ok := p.cache[url]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.credentials, nil
+	}
+	cmd := exec.Command(p.command[0], p.command[1:]...)
+	cmd.Stdin = strings.NewReader(url + "\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %v %s", p.command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	var output struct {
+		Credentials
+		Secret    string    `json:"secret,omitempty"`
+		ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("Invalid output of %s: %s", p.command[0], err.Error())
+	}
+	creds := &output.Credentials
+	if creds.Password == "" {
+		creds.Password = output.Secret
+	}
+	expiresAt := output.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(execCredentialTTL)
+	}
+	p.cache[url] = &execCredentials{credentials: creds, expiresAt: expiresAt}
+	return creds, nil
+}
+
+func (p *execProvider) invalidate(url string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.cache, url)
+}
+
+// secretProvider reads the keys username, password, token and bearer of a kubernetes secret <namespace>/<name>
+type secretProvider struct {
+	secret string
+	k8s    k8s.K8sReader
+}
+
+func (p *secretProvider) Credentials(url string) (*Credentials, error) {
+	if p.k8s == nil {
+		return nil, fmt.Errorf("Secret %s can't be read without access to kubernetes", p.secret)
+	}
+	parts := strings.SplitN(p.secret, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid secret %s, expected <namespace>/<name>", p.secret)
+	}
+	obj, err := p.k8s.Get("secret", parts[1], &k8s.Options{Namespace: parts[0], Quiet: true})
+	if err != nil {
+		return nil, err
+	}
+	var data map[string][]byte
+	if err := json.Unmarshal(obj.Additional["data"], &data); err != nil {
+		return nil, err
+	}
+	return &Credentials{
+		Username: string(data["username"]),
+		Password: string(data["password"]),
+		Token:    string(data["token"]),
+		Bearer:   string(data["bearer"]),
+	}, nil
+}
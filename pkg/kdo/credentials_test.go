@@ -0,0 +1,139 @@
+package kdo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Credentials", func() {
+
+	authorization := func(creds *credentials, url string) http.Header {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authorize(request, creds)).To(Succeed())
+		return request.Header
+	}
+
+	It("uses the credentials with the longest matching url", func() {
+		creds := newCredentials([]credential{
+			{URL: "https://charts.example.com/", Token: "token"},
+			{URL: "https://charts.example.com/private/", Bearer: "bearer", Headers: map[string]string{"X-Tenant": "a"}},
+			{URL: "https://other.example.com/", Username: "user", Password: "pass"},
+		}, nil, nil)
+		Expect(authorization(creds, "https://charts.example.com/base.tgz").Get("Authorization")).To(Equal("token token"))
+		header := authorization(creds, "https://charts.example.com/private/base.tgz")
+		Expect(header.Get("Authorization")).To(Equal("Bearer bearer"))
+		Expect(header.Get("X-Tenant")).To(Equal("a"))
+		Expect(authorization(creds, "https://other.example.com/base.tgz").Get("Authorization")).To(Equal("Basic dXNlcjpwYXNz"))
+		Expect(authorization(creds, "https://unknown.example.com/base.tgz").Get("Authorization")).To(BeEmpty())
+	})
+
+	It("reads credentials from environment variables", func() {
+		os.Setenv("KDO_TEST_CHARTS_BEARER", "from-env")
+		defer os.Unsetenv("KDO_TEST_CHARTS_BEARER")
+		creds := newCredentials([]credential{{URL: "https://charts.example.com/", Env: "KDO_TEST_CHARTS"}}, nil, nil)
+		Expect(authorization(creds, "https://charts.example.com/base.tgz").Get("Authorization")).To(Equal("Bearer from-env"))
+		creds = newCredentials([]credential{{URL: "https://charts.example.com/", Env: "KDO_TEST_UNSET"}}, nil, nil)
+		Expect(authorization(creds, "https://charts.example.com/base.tgz").Get("Authorization")).To(BeEmpty())
+	})
+
+	It("reads credentials from a netrc file", func() {
+		dir := NewTestDir()
+		defer dir.Remove()
+		dir.WriteFile("netrc", []byte("machine charts.example.com\n  login user\n  password pass\ndefault login anonymous password guest\n"), 0600)
+		creds := newCredentials([]credential{{URL: "https://", Netrc: dir.Join("netrc")}}, nil, nil)
+		Expect(authorization(creds, "https://charts.example.com/base.tgz").Get("Authorization")).To(Equal("Basic dXNlcjpwYXNz"))
+		Expect(authorization(creds, "https://other.example.com/base.tgz").Get("Authorization")).To(Equal("Basic YW5vbnltb3VzOmd1ZXN0"))
+	})
+
+	It("runs credential helpers", func() {
+		creds := newCredentials([]credential{{URL: "https://charts.example.com/", Command: []string{"sh", "-c", `read url; echo "{\"Username\":\"$url\",\"Secret\":\"secret\"}"`}}}, nil, nil)
+		Expect(authorization(creds, "https://charts.example.com/a").Get("Authorization")).To(Equal("Basic aHR0cHM6Ly9jaGFydHMuZXhhbXBsZS5jb20vYTpzZWNyZXQ="))
+		creds = newCredentials([]credential{{URL: "https://charts.example.com/", Command: []string{"false"}}}, nil, nil)
+		request, _ := http.NewRequest(http.MethodGet, "https://charts.example.com/a", nil)
+		Expect(authorize(request, creds)).To(MatchError(ContainSubstring("Can't get credentials for https://charts.example.com/a")))
+	})
+
+	It("refreshes credentials of helpers, if they expire or are rejected", func() {
+		dir := NewTestDir()
+		defer dir.Remove()
+		helper := func(expiresAt string) []string {
+			return []string{"sh", "-c", `read url; echo >> ` + dir.Join("calls") + `; echo "{\"bearer\":\"token-$(wc -l < ` + dir.Join("calls") + ` | tr -d ' ')\"` + expiresAt + `}"`}
+		}
+		creds := newCredentials([]credential{{URL: "https://charts.example.com/", Command: helper("")}}, nil, nil)
+		Expect(authorization(creds, "https://charts.example.com/a").Get("Authorization")).To(Equal("Bearer token-1"))
+		Expect(authorization(creds, "https://charts.example.com/a").Get("Authorization")).To(Equal("Bearer token-1"))
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		creds = newCredentials([]credential{{URL: server.URL, Command: helper("")}}, nil, nil)
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		res, err := doAuthorizedRequest(http.DefaultClient, request, creds)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		creds = newCredentials([]credential{{URL: "https://charts.example.com/", Command: helper(`,\"expiresAt\":\"2000-01-01T00:00:00Z\"`)}}, nil, nil)
+		Expect(authorization(creds, "https://charts.example.com/a").Get("Authorization")).To(Equal("Bearer token-3"))
+		Expect(authorization(creds, "https://charts.example.com/a").Get("Authorization")).To(Equal("Bearer token-4"))
+	})
+
+	It("reads credentials from kubernetes secrets", func() {
+		kim := k8s.NewK8sInMemory("default", k8s.Object{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			MetaData:   k8s.MetaData{Name: "charts", Namespace: "kdo-system"},
+			Additional: map[string]json.RawMessage{"data": json.RawMessage(`{"token":"c2VjcmV0"}`)},
+		})
+		creds := newCredentials([]credential{{URL: "https://charts.example.com/", Secret: "kdo-system/charts"}}, nil, kim)
+		Expect(authorization(creds, "https://charts.example.com/a").Get("Authorization")).To(Equal("token secret"))
+		creds = newCredentials([]credential{{URL: "https://charts.example.com/", Secret: "kdo-system/charts"}}, nil, nil)
+		request, _ := http.NewRequest(http.MethodGet, "https://charts.example.com/a", nil)
+		Expect(authorize(request, creds)).To(MatchError(ContainSubstring("can't be read without access to kubernetes")))
+	})
+
+	It("uses custom credential providers", func() {
+		creds := newCredentials(nil, []credentialSource{{url: "https://", provider: &staticProvider{Credentials{Headers: map[string]string{"X-Api-Key": "key"}}}}}, nil)
+		Expect(authorization(creds, "https://charts.example.com/a").Get("X-Api-Key")).To(Equal("key"))
+	})
+
+	It("retries requests failing with 5xx", func() {
+		backoff := retryBackoff
+		retryBackoff = []time.Duration{time.Millisecond, time.Millisecond}
+		defer func() { retryBackoff = backoff }()
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		res, err := doRequest(http.DefaultClient, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(requests).To(Equal(3))
+
+		requests = -10
+		res, err = doRequest(http.DefaultClient, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(requests).To(Equal(-7))
+	})
+})
@@ -2,6 +2,7 @@ package kdo
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
@@ -106,19 +107,29 @@ func (s *dependency) Apply(thread *starlark.Thread, k8s k8s.K8s) error {
 	if len(charts) > 1 {
 		return fmt.Errorf("found more than one chart for genus %s in namespace %s", gv.genus, s.namespace)
 	}
+	var chart ChartValue
 	if len(charts) == 1 {
-		if !s.constraint.Check(charts[0].GetVersion()) {
-			return fmt.Errorf("installed version of genus %s in namespace %s doesn't match constraint %v", gv.genus, s.namespace, s.constraint)
+		if s.constraint.Check(charts[0].GetVersion()) {
+			if err := s.resolve(charts[0]); err != nil {
+				return err
+			}
+			return s.addUsedBy(charts[0], k8s)
 		}
-		_, err := charts[0].AddUsedBy(s.userBy(), k8s)
+		chart, err = s.upgrade(thread, k8s, charts[0])
 		if err != nil {
 			return err
 		}
-		return s.resolve(charts[0])
+	} else {
+		chart = resolvedDependencies(thread).chart(s.namespace, gv.genus)
 	}
-	chart, err := s.repo.Get(thread, s.url, append(gv.AsOptions(), WithNamespace(s.namespace))...)
-	if err != nil {
-		return err
+	if chart == nil {
+		chart, err = s.repo.Get(thread, s.url, append(gv.AsOptions(), WithNamespace(s.namespace))...)
+		if err != nil {
+			return err
+		}
+	}
+	if c, ok := chart.(*chartImpl); ok {
+		c.asDependency = true
 	}
 	err = s.resolve(chart)
 	if err != nil {
@@ -128,11 +139,76 @@ func (s *dependency) Apply(thread *starlark.Thread, k8s k8s.K8s) error {
 	if err != nil {
 		return err
 	}
-	_, err = chart.AddUsedBy(s.userBy(), k8s)
+	return s.addUsedBy(chart, k8s)
+}
+
+// addUsedBy records the constraint of this dependency in the installed chart
+func (s *dependency) addUsedBy(chart ChartValue, k k8s.K8s) error {
+	var err error
+	if c, ok := chart.(*chartImpl); ok {
+		_, err = c.addUsedBy(s.userBy(), s.constraint.String(), k)
+	} else {
+		_, err = chart.AddUsedBy(s.userBy(), k)
+	}
+	return err
+}
+
+// upgrade selects the highest version of a shared chart, which matches the constraints of all charts using it
+func (s *dependency) upgrade(thread *starlark.Thread, k k8s.K8s, installed ChartValue) (ChartValue, error) {
+	mismatch := fmt.Errorf("installed version of genus %s in namespace %s doesn't match constraint %v", installed.GetGenus(), s.namespace, s.constraint)
+	configMap, err := k.Get("configmap", "kdo."+installed.GetGenus(), &k8s.Options{Namespace: s.namespace})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	info := installedChart(configMap)
+	versions, err := s.repo.Versions(thread, s.url, s.namespace)
+	if err != nil {
+		return nil, err
+	}
+	var blockers []string
+	for _, version := range versions {
+		if !s.constraint.Check(version.Version) || !version.Version.GreaterThan(installed.GetVersion()) {
+			continue
+		}
+		b := upgradeBlockers(info, s.userBy(), version.Version)
+		if len(b) == 0 {
+			chart := resolvedDependencies(thread).chart(s.namespace, installed.GetGenus())
+			if chart == nil || !chart.GetVersion().Equal(version.Version) {
+				chart, err = version.Get()
+				if err != nil {
+					return nil, err
+				}
+			}
+			return chart, keepValues(chart, installed)
+		}
+		if blockers == nil {
+			blockers = b
+			mismatch = fmt.Errorf("can't upgrade genus %s in namespace %s from version %v to %v: blocked by %s", installed.GetGenus(), s.namespace, installed.GetVersion(), version.Version, strings.Join(blockers, ", "))
+		}
+	}
+	return nil, mismatch
+}
+
+// keepValues sets the values of the installed chart, which are set by other users of the chart, in the upgraded chart.
+// Values of properties removed by the upgrade are dropped.
+func keepValues(upgraded ChartValue, installed ChartValue) error {
+	values, ok := installed.GetValue().(starlark.IterableMapping)
+	if !ok {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, name := range upgraded.AttrNames() {
+		names[name] = true
+	}
+	kept := starlark.NewDict(0)
+	for _, t := range values.Items() {
+		if name, ok := t.Index(0).(starlark.String); ok && names[name.GoString()] {
+			if err := kept.SetKey(name, t.Index(1)); err != nil {
+				return err
+			}
+		}
+	}
+	return upgraded.SetValue(kept)
 }
 
 func (s *dependency) Delete(thread *starlark.Thread, k8s k8s.K8s, deleteOptions *DeleteOptions) error {
@@ -150,12 +226,13 @@ func (s *dependency) Delete(thread *starlark.Thread, k8s k8s.K8s, deleteOptions
 	}
 	if len(charts) == 1 {
 		references, err := charts[0].RemoveUsedBy(s.userBy(), k8s)
-		if deleteOptions.recursive {
-			if references == 0 {
-				charts[0].Delete(thread, k8s, deleteOptions)
-			}
+		if err != nil {
+			return err
 		}
-		return err
+		if deleteOptions.recursive && references == 0 {
+			return charts[0].Delete(thread, k8s, deleteOptions)
+		}
+		return nil
 	}
 	return nil
 }
@@ -5,19 +5,29 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"time"
 )
 
 type cacheMetaData struct {
 	ETag       string `yaml:"etag,omitempty"`
 	Generation uint32 `yaml:"generation,omitempty"`
 	Name       string `yaml:"name,omitempty"`
+	// Fetched is the time the content has been validated the last time
+	Fetched time.Time `yaml:"fetched,omitempty"`
 }
 
 // DirCache -
 type DirCache struct {
 	baseDir string
+	// ttl is the time cached remote content is used without validation
+	ttl time.Duration
+	// offline serves remote content only from the cache
+	offline bool
 }
 
 // NewDirCache -
@@ -33,6 +43,15 @@ type LoadDir = func(name string, targetDir func() (string, error), etagOld strin
 
 // WrapDir -
 func (d *DirCache) WrapDir(load LoadDir) OpenDirCache {
+	return d.wrap(load, false)
+}
+
+// WrapRemoteDir - like WrapDir, but cached content is used without calling load within the ttl and in offline mode
+func (d *DirCache) WrapRemoteDir(load LoadDir) OpenDirCache {
+	return d.wrap(load, true)
+}
+
+func (d *DirCache) wrap(load LoadDir, remote bool) OpenDirCache {
 	return func(name string) (cachedDir string, err error) {
 		md5Sum := md5.Sum([]byte(name))
 		cacheDir := path.Join(d.baseDir, hex.EncodeToString(md5Sum[:]))
@@ -53,6 +72,12 @@ func (d *DirCache) WrapDir(load LoadDir) OpenDirCache {
 		contentDir := func(generation uint32) string {
 			return path.Join(cacheDir, fmt.Sprintf("%x", generation))
 		}
+		if remote && metaData.Generation != 0 && (d.offline || (d.ttl > 0 && time.Since(metaData.Fetched) < d.ttl)) {
+			return contentDir(metaData.Generation), nil
+		}
+		if remote && d.offline {
+			return "", fmt.Errorf("%s isn't cached. It can't be loaded in offline mode", name)
+		}
 		oldDir := contentDir(metaData.Generation)
 		newDir := ""
 		targetDir := func() (string, error) {
@@ -72,7 +97,13 @@ func (d *DirCache) WrapDir(load LoadDir) OpenDirCache {
 		if err != nil {
 			return "", err
 		}
+		metaData.Fetched = time.Now()
 		if etag == metaData.ETag {
+			if remote {
+				if err := writeYamlFile(metaDataFile, metaData); err != nil {
+					return "", err
+				}
+			}
 			return contentDir(metaData.Generation), nil
 		}
 		if len(newDir) == 0 {
@@ -128,3 +159,133 @@ func (d *DirCache) WrapReader(load LoadReader) OpenReaderCache {
 	}
 
 }
+
+// CacheEntry - cached content of an url
+type CacheEntry struct {
+	Name    string
+	Dir     string
+	Size    int64
+	Fetched time.Time
+	key     string
+	etag    string
+}
+
+// List returns the entries of the cache sorted by name
+func (d *DirCache) List() ([]*CacheEntry, error) {
+	files, err := ioutil.ReadDir(d.baseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := make([]*CacheEntry, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		entry := &CacheEntry{key: file.Name()}
+		var metaData cacheMetaData
+		if err := readYamlFile(path.Join(d.baseDir, file.Name(), "metadata.yml"), &metaData); err == nil && metaData.Generation != 0 {
+			entry.Name = metaData.Name
+			entry.Fetched = metaData.Fetched
+			entry.etag = metaData.ETag
+			entry.Dir = path.Join(d.baseDir, file.Name(), fmt.Sprintf("%x", metaData.Generation))
+		}
+		entry.Size, err = dirSize(path.Join(d.baseDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Prune removes all entries, which haven't been fetched within maxAge, and incomplete entries
+func (d *DirCache) Prune(maxAge time.Duration) ([]*CacheEntry, error) {
+	entries, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	pruned := make([]*CacheEntry, 0)
+	for _, entry := range entries {
+		if entry.Dir != "" && time.Since(entry.Fetched) < maxAge {
+			continue
+		}
+		if err := os.RemoveAll(path.Join(d.baseDir, entry.key)); err != nil {
+			return nil, err
+		}
+		pruned = append(pruned, entry)
+	}
+	return pruned, nil
+}
+
+// seed stores a copy of a directory as cached content of name
+func (d *DirCache) seed(name string, etag string, dir string) error {
+	if etag == "" {
+		etag = "bundle"
+	}
+	_, err := d.WrapDir(func(name string, targetDir func() (string, error), etagOld string) (string, error) {
+		if etagOld == etag {
+			return etag, nil
+		}
+		target, err := targetDir()
+		if err != nil {
+			return "", err
+		}
+		return etag, copyDir(dir, target)
+	})(name)
+	return err
+}
+
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), data, info.Mode().Perm())
+	})
+}
+
+// Clear removes all entries
+func (d *DirCache) Clear() error {
+	return os.RemoveAll(d.baseDir)
+}
+
+// withTTL returns a cache sharing the directory, which uses cached remote content without validation within ttl
+func (d *DirCache) withTTL(ttl time.Duration) *DirCache {
+	return &DirCache{baseDir: d.baseDir, ttl: ttl, offline: d.offline}
+}
+
+// TTL returns the time cached remote content is used without validation
+func (d *DirCache) TTL() time.Duration {
+	return d.ttl
+}
@@ -2,10 +2,12 @@ package kdo
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -108,4 +110,103 @@ var _ = Describe("Dir Cache", func() {
 
 	})
 
+	It("remote dir cache respects ttl and offline mode", func() {
+		dir := NewTestDir()
+		defer dir.Remove()
+		loads := 0
+		load := func(name string, dir func() (string, error), etag string) (string, error) {
+			loads++
+			target, err := dir()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("tag%d", loads), ioutil.WriteFile(path.Join(target, "content"), []byte(name), 0644)
+		}
+
+		By("Validates content on each access without ttl", func() {
+			opener := NewDirCache(dir.Root()).WrapRemoteDir(load)
+			_, err := opener("a")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = opener("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loads).To(Equal(2))
+		})
+
+		By("Uses cached content within ttl", func() {
+			opener := (&DirCache{baseDir: dir.Root(), ttl: time.Hour}).WrapRemoteDir(load)
+			cached, err := opener("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loads).To(Equal(2))
+			Expect(path.Join(cached, "content")).To(BeAnExistingFile())
+		})
+
+		By("Uses cached content in offline mode", func() {
+			opener := (&DirCache{baseDir: dir.Root(), offline: true}).WrapRemoteDir(load)
+			cached, err := opener("a")
+			Expect(err).NotTo(HaveOccurred())
+			content, err := ioutil.ReadFile(path.Join(cached, "content"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("a"))
+			Expect(loads).To(Equal(2))
+		})
+
+		By("Fails in offline mode if content isn't cached", func() {
+			opener := (&DirCache{baseDir: dir.Root(), offline: true}).WrapRemoteDir(load)
+			_, err := opener("b")
+			Expect(err).To(MatchError("b isn't cached. It can't be loaded in offline mode"))
+			Expect(loads).To(Equal(2))
+		})
+
+		By("Ignores offline mode for local content", func() {
+			opener := (&DirCache{baseDir: dir.Root(), offline: true}).WrapDir(load)
+			_, err := opener("c")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loads).To(Equal(3))
+		})
+	})
+
+	It("lists, prunes and clears entries", func() {
+		dir := NewTestDir()
+		defer dir.Remove()
+		cache := NewDirCache(dir.Join("cache"))
+		load := func(name string, dir func() (string, error), etag string) (string, error) {
+			target, err := dir()
+			if err != nil {
+				return "", err
+			}
+			return "tag", ioutil.WriteFile(path.Join(target, "content"), []byte(name), 0644)
+		}
+		opener := cache.WrapRemoteDir(load)
+		for _, name := range []string{"b", "a"} {
+			_, err := opener(name)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		entries, err := cache.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Name).To(Equal("a"))
+		Expect(entries[1].Name).To(Equal("b"))
+		Expect(entries[0].Size).To(BeNumerically(">", 0))
+		Expect(entries[0].Fetched).NotTo(BeZero())
+
+		pruned, err := cache.Prune(time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pruned).To(BeEmpty())
+
+		pruned, err = cache.Prune(0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pruned).To(HaveLen(2))
+		entries, err = cache.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+
+		_, err = opener("a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.Clear()).To(Succeed())
+		entries, err = cache.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
 })
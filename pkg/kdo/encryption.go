@@ -0,0 +1,405 @@
+package kdo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	encryptedKeyID       = "kdo.key-id"
+	encryptedDataKey     = "kdo.data-key"
+	encryptedProperties  = "kdo.properties"
+	currentKeyAnnotation = "kdo.sap.github.com/current-key"
+	dataKeySize          = 32
+)
+
+// KeyProvider - provides the key encryption keys, which protect the data keys of the persisted chart properties
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the current key encryption key
+	WrapKey(k k8s.K8sReader, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key with the key encryption key identified by keyID
+	UnwrapKey(k k8s.K8sReader, keyID string, wrapped []byte) ([]byte, error)
+	// Rotate creates a new key encryption key, which is used by all subsequent calls of WrapKey
+	Rotate(k k8s.K8s) error
+}
+
+// KMSClient - minimal interface of an external key management service
+type KMSClient interface {
+	// Encrypt -
+	Encrypt(keyID string, plaintext []byte) ([]byte, error)
+	// Decrypt -
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+type encryptionConfig struct {
+	Provider  string `yaml:"provider,omitempty"`
+	KeyFile   string `yaml:"keyFile,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Secret    string `yaml:"secret,omitempty"`
+}
+
+func (e *encryptionConfig) newKeyProvider() (KeyProvider, error) {
+	switch e.Provider {
+	case "":
+		return nil, nil
+	case "file":
+		if e.KeyFile == "" {
+			return nil, errors.New("encryption provider file requires a keyFile")
+		}
+		return NewFileKeyProvider(e.KeyFile), nil
+	case "namespace":
+		return NewNamespaceKeyProvider(e.Namespace, e.Secret), nil
+	case "kms":
+		return nil, errors.New("encryption provider kms must be configured programmatically using WithKeyProvider")
+	}
+	return nil, fmt.Errorf("Unknown encryption provider %s", e.Provider)
+}
+
+// keyRing holds a set of key encryption keys and the id of the current one
+type keyRing struct {
+	Current string            `yaml:"current"`
+	Keys    map[string]string `yaml:"keys"`
+}
+
+func (r *keyRing) key(keyID string) ([]byte, error) {
+	encoded, ok := r.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %s not found", keyID)
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+func (r *keyRing) wrapKey(dataKey []byte) (string, []byte, error) {
+	if r.Current == "" {
+		return "", nil, errors.New("no current encryption key")
+	}
+	kek, err := r.key(r.Current)
+	if err != nil {
+		return "", nil, err
+	}
+	wrapped, err := seal(kek, dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return r.Current, wrapped, nil
+}
+
+func (r *keyRing) unwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	kek, err := r.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, wrapped)
+}
+
+func (r *keyRing) rotate() error {
+	kek, err := randomKey()
+	if err != nil {
+		return err
+	}
+	if r.Keys == nil {
+		r.Keys = map[string]string{}
+	}
+	keyID := time.Now().UTC().Format("20060102150405")
+	if _, ok := r.Keys[keyID]; ok {
+		return fmt.Errorf("encryption key %s already exists", keyID)
+	}
+	r.Keys[keyID] = base64.StdEncoding.EncodeToString(kek)
+	r.Current = keyID
+	return nil
+}
+
+type fileKeyProvider struct {
+	filename string
+}
+
+// NewFileKeyProvider creates a key provider, which reads the key encryption keys from a local yaml file
+func NewFileKeyProvider(filename string) KeyProvider {
+	return &fileKeyProvider{filename: filename}
+}
+
+func (f *fileKeyProvider) read() (*keyRing, error) {
+	ring := &keyRing{}
+	if err := readYamlFile(f.filename, ring); err != nil {
+		return nil, errors.Wrapf(err, "can't read key file %s", f.filename)
+	}
+	return ring, nil
+}
+
+func (f *fileKeyProvider) WrapKey(k k8s.K8sReader, dataKey []byte) (string, []byte, error) {
+	ring, err := f.read()
+	if err != nil {
+		return "", nil, err
+	}
+	return ring.wrapKey(dataKey)
+}
+
+func (f *fileKeyProvider) UnwrapKey(k k8s.K8sReader, keyID string, wrapped []byte) ([]byte, error) {
+	ring, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return ring.unwrapKey(keyID, wrapped)
+}
+
+func (f *fileKeyProvider) Rotate(k k8s.K8s) error {
+	ring, err := f.read()
+	if err != nil {
+		if !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+		ring = &keyRing{}
+	}
+	if err := ring.rotate(); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(ring)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.filename, data, 0600)
+}
+
+type namespaceKeyProvider struct {
+	namespace string
+	name      string
+}
+
+// NewNamespaceKeyProvider creates a key provider, which reads the key encryption keys from a kubernetes secret in a dedicated namespace
+func NewNamespaceKeyProvider(namespace string, name string) KeyProvider {
+	if namespace == "" {
+		namespace = "kdo-system"
+	}
+	if name == "" {
+		name = "kdo-encryption-keys"
+	}
+	return &namespaceKeyProvider{namespace: namespace, name: name}
+}
+
+func (n *namespaceKeyProvider) options() *k8s.Options {
+	return &k8s.Options{Namespace: n.namespace, Quiet: true}
+}
+
+func (n *namespaceKeyProvider) read(k k8s.K8sReader) (*keyRing, error) {
+	obj, err := k.Get("secret", n.name, n.options())
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read encryption keys from secret %s/%s", n.namespace, n.name)
+	}
+	return keyRingFromSecret(obj)
+}
+
+func keyRingFromSecret(obj *k8s.Object) (*keyRing, error) {
+	data, err := secretData(obj)
+	if err != nil {
+		return nil, err
+	}
+	ring := &keyRing{Keys: map[string]string{}, Current: obj.MetaData.Annotations[currentKeyAnnotation]}
+	for id, key := range data {
+		ring.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	}
+	return ring, nil
+}
+
+func (n *namespaceKeyProvider) WrapKey(k k8s.K8sReader, dataKey []byte) (string, []byte, error) {
+	ring, err := n.read(k)
+	if err != nil {
+		return "", nil, err
+	}
+	return ring.wrapKey(dataKey)
+}
+
+func (n *namespaceKeyProvider) UnwrapKey(k k8s.K8sReader, keyID string, wrapped []byte) ([]byte, error) {
+	ring, err := n.read(k)
+	if err != nil {
+		return nil, err
+	}
+	return ring.unwrapKey(keyID, wrapped)
+}
+
+func (n *namespaceKeyProvider) Rotate(k k8s.K8s) error {
+	secret := &k8s.Object{
+		APIVersion: corev1.SchemeGroupVersion.String(),
+		Kind:       "Secret",
+		MetaData:   k8s.MetaData{Name: n.name, Namespace: n.namespace},
+	}
+	_, err := k.CreateOrUpdate(secret, func(obj *k8s.Object) error {
+		ring, err := keyRingFromSecret(obj)
+		if err != nil {
+			return err
+		}
+		if err := ring.rotate(); err != nil {
+			return err
+		}
+		data := map[string][]byte{}
+		for id := range ring.Keys {
+			if data[id], err = ring.key(id); err != nil {
+				return err
+			}
+		}
+		if obj.MetaData.Annotations == nil {
+			obj.MetaData.Annotations = map[string]string{}
+		}
+		obj.MetaData.Annotations[currentKeyAnnotation] = ring.Current
+		return setSecretData(obj, data)
+	}, n.options())
+	return err
+}
+
+type kmsKeyProvider struct {
+	client KMSClient
+	keyID  string
+}
+
+// NewKMSKeyProvider creates a key provider, which delegates wrapping of data keys to an external key management service
+func NewKMSKeyProvider(client KMSClient, keyID string) KeyProvider {
+	return &kmsKeyProvider{client: client, keyID: keyID}
+}
+
+func (m *kmsKeyProvider) WrapKey(k k8s.K8sReader, dataKey []byte) (string, []byte, error) {
+	wrapped, err := m.client.Encrypt(m.keyID, dataKey)
+	return m.keyID, wrapped, err
+}
+
+func (m *kmsKeyProvider) UnwrapKey(k k8s.K8sReader, keyID string, wrapped []byte) ([]byte, error) {
+	return m.client.Decrypt(keyID, wrapped)
+}
+
+func (m *kmsKeyProvider) Rotate(k k8s.K8s) error {
+	return errors.New("keys of a kms are rotated by the kms itself")
+}
+
+func secretData(obj *k8s.Object) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	if obj == nil {
+		return data, nil
+	}
+	if loadedData, ok := obj.Additional["data"]; ok {
+		if err := json.Unmarshal(loadedData, &data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func setSecretData(obj *k8s.Object, data map[string][]byte) error {
+	j, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if obj.Additional == nil {
+		obj.Additional = map[string]json.RawMessage{}
+	}
+	obj.Additional["data"] = j
+	return nil
+}
+
+// isEncrypted returns true, if data holds encrypted properties
+func isEncrypted(data map[string][]byte) bool {
+	_, ok := data[encryptedProperties]
+	return ok
+}
+
+// encryptProperties encrypts properties with a new data key, which itself is encrypted by the key provider
+func encryptProperties(k k8s.K8sReader, provider KeyProvider, properties map[string][]byte) (map[string][]byte, error) {
+	if provider == nil {
+		return properties, nil
+	}
+	plaintext, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := provider.WrapKey(k, dataKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't encrypt properties")
+	}
+	return map[string][]byte{
+		encryptedKeyID:      []byte(keyID),
+		encryptedDataKey:    wrapped,
+		encryptedProperties: ciphertext,
+	}, nil
+}
+
+// decryptProperties returns the plain properties. Unencrypted data is returned unchanged.
+func decryptProperties(k k8s.K8sReader, provider KeyProvider, data map[string][]byte) (map[string][]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	if provider == nil {
+		return nil, errors.New("properties are encrypted, but no encryption provider is configured")
+	}
+	dataKey, err := provider.UnwrapKey(k, string(data[encryptedKeyID]), data[encryptedDataKey])
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decrypt properties")
+	}
+	plaintext, err := open(dataKey, data[encryptedProperties])
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decrypt properties")
+	}
+	properties := map[string][]byte{}
+	if err := json.Unmarshal(plaintext, &properties); err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext using AES-GCM. The nonce is prepended to the result.
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
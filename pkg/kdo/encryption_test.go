@@ -0,0 +1,167 @@
+package kdo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Encryption", func() {
+	var dir TestDir
+	var kim *k8s.K8sInMemory
+	thread := &starlark.Thread{Name: "main"}
+	properties := map[string][]byte{"password": []byte(`"secret"`)}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		kim = k8s.NewK8sInMemoryEmpty()
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	for name, newProvider := range map[string]func(dir TestDir) KeyProvider{
+		"file":      func(dir TestDir) KeyProvider { return NewFileKeyProvider(dir.Join("keys.yaml")) },
+		"namespace": func(dir TestDir) KeyProvider { return NewNamespaceKeyProvider("", "") },
+	} {
+		newProvider := newProvider
+		It("encrypts with "+name+" provider", func() {
+			provider := newProvider(dir)
+			_, err := encryptProperties(kim, provider, properties)
+			Expect(err).To(HaveOccurred())
+			Expect(provider.Rotate(kim)).To(Succeed())
+			data, err := encryptProperties(kim, provider, properties)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).NotTo(HaveKey("password"))
+			Expect(string(data[encryptedProperties])).NotTo(ContainSubstring("secret"))
+			decrypted, err := decryptProperties(kim, provider, data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decrypted).To(Equal(properties))
+		})
+	}
+
+	It("decrypts unencrypted properties", func() {
+		decrypted, err := decryptProperties(kim, nil, properties)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decrypted).To(Equal(properties))
+	})
+
+	Context("repo", func() {
+		var provider KeyProvider
+		var repo Repo
+
+		BeforeEach(func() {
+			dir.MkdirAll("chart/templates", 0755)
+			dir.WriteFile("chart/Chart.star", []byte(`
+def init(self):
+	self.password = property()
+`), 0644)
+			provider = NewFileKeyProvider(dir.Join("keys.yaml"))
+			Expect(provider.Rotate(kim)).To(Succeed())
+			var err error
+			repo, err = NewRepo(WithKeyProvider(provider))
+			Expect(err).NotTo(HaveOccurred())
+			values := starlark.NewDict(1)
+			values.SetKey(starlark.String("password"), starlark.String("secret"))
+			c, err := repo.Get(thread, dir.Join("chart"), WithProperties(values))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Apply(thread, kim)).To(Succeed())
+		})
+
+		It("persists encrypted properties", func() {
+			obj, err := kim.GetObject("secret", "kdo.chart", &k8s.Options{})
+			Expect(err).NotTo(HaveOccurred())
+			data, err := secretData(obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isEncrypted(data)).To(BeTrue())
+			Expect(data).NotTo(HaveKey("password"))
+		})
+
+		It("decrypts properties during list", func() {
+			charts, err := repo.List(thread, kim, &RepoListOptions{namespace: "default"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(charts).To(HaveLen(1))
+			Expect(charts[0].Attr("password")).To(Equal(starlark.String("secret")))
+		})
+
+		It("rekeys properties", func() {
+			obj, _ := kim.GetObject("secret", "kdo.chart", &k8s.Options{})
+			before, _ := secretData(obj)
+			Expect(repo.Rekey(kim, &RepoListOptions{namespace: "default"}, false)).To(Succeed())
+			obj, _ = kim.GetObject("secret", "kdo.chart", &k8s.Options{})
+			after, _ := secretData(obj)
+			Expect(after[encryptedKeyID]).To(Equal(before[encryptedKeyID]))
+			Expect(after[encryptedDataKey]).NotTo(Equal(before[encryptedDataKey]))
+			charts, err := repo.List(thread, kim, &RepoListOptions{namespace: "default"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(charts[0].Attr("password")).To(Equal(starlark.String("secret")))
+		})
+
+		It("encrypts properties of charts loaded with command line options", func() {
+			values := starlark.NewDict(1)
+			values.SetKey(starlark.String("password"), starlark.String("secret"))
+			c, err := repo.Get(thread, dir.Join("chart"), (&ChartOptions{}).Merge(), WithProperties(values))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Apply(thread, kim)).To(Succeed())
+			obj, err := kim.GetObject("secret", "kdo.chart", &k8s.Options{})
+			Expect(err).NotTo(HaveOccurred())
+			data, err := secretData(obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isEncrypted(data)).To(BeTrue())
+		})
+
+		It("fails, if properties can't be encrypted", func() {
+			Expect(os.Remove(dir.Join("keys.yaml"))).To(Succeed())
+			c, err := repo.Get(thread, dir.Join("chart"))
+			Expect(err).NotTo(HaveOccurred())
+			err = c.Apply(thread, k8s.NewK8sInMemory("other"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unable to persist properties of chart"))
+		})
+
+		It("fails, if the config map can't be written", func() {
+			c, err := repo.Get(thread, dir.Join("chart"))
+			Expect(err).NotTo(HaveOccurred())
+			err = c.Apply(thread, &failingConfigMaps{K8sInMemory: kim})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unable to persist properties of chart: forbidden"))
+		})
+
+		It("fails to list without key provider", func() {
+			plainRepo, err := NewRepo()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = plainRepo.List(thread, kim, &RepoListOptions{namespace: "default"})
+			Expect(err).To(HaveOccurred())
+			obj, _ := kim.GetObject("secret", "kdo.chart", &k8s.Options{})
+			Expect(json.Marshal(obj)).NotTo(ContainSubstring("secret\""))
+		})
+	})
+})
+
+// failingConfigMaps fails to write config maps
+type failingConfigMaps struct {
+	*k8s.K8sInMemory
+}
+
+func (k *failingConfigMaps) CreateOrUpdate(obj *k8s.Object, mutate func(obj *k8s.Object) error, options *k8s.Options) (*k8s.Object, error) {
+	if obj.Kind == "ConfigMap" {
+		return nil, fmt.Errorf("forbidden")
+	}
+	return k.K8sInMemory.CreateOrUpdate(obj, mutate, options)
+}
+
+func (k *failingConfigMaps) ForSubChart(namespace string, app string, version *semver.Version, children int) k8s.K8s {
+	return &failingConfigMaps{K8sInMemory: k.K8sInMemory.ForSubChart(namespace, app, version, children).(*k8s.K8sInMemory)}
+}
+
+func (k *failingConfigMaps) WithContext(ctx context.Context) k8s.K8s {
+	return &failingConfigMaps{K8sInMemory: k.K8sInMemory.WithContext(ctx).(*k8s.K8sInMemory)}
+}
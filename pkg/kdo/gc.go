@@ -0,0 +1,126 @@
+package kdo
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// StaleReference - a used-by annotation of a chart referencing a chart, which doesn't exist anymore
+type StaleReference struct {
+	Chart     *InstalledChart
+	Reference string
+}
+
+// GarbageCollection - stale references and orphaned dependencies of the installed charts
+type GarbageCollection struct {
+	StaleReferences []StaleReference
+	// Orphans are the dependencies without references in the order of deletion
+	Orphans []*InstalledChart
+}
+
+// PlanGarbageCollection finds stale references and dependencies, which aren't used anymore, in all namespaces.
+// Dependencies declared by an installed chart are kept, even if their used-by annotation is missing.
+func PlanGarbageCollection(thread *starlark.Thread, repo Repo, k k8s.K8s) (*GarbageCollection, error) {
+	listOptions := &RepoListOptions{allNamespaces: true}
+	installed, err := ListInstalled(k, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	charts, err := repo.List(thread, k, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	declared := map[string][]string{}
+	for _, chart := range charts {
+		if c, ok := chart.(*chartImpl); ok {
+			for _, dep := range declaredDependencies(c) {
+				key := dependencyKey(dep.namespace, NewGenusAndVersion(dep.url).genus)
+				declared[key] = append(declared[key], k8s.FixLabelValue(dep.userBy()))
+			}
+		}
+	}
+	gc := &GarbageCollection{}
+	existing := map[string]bool{}
+	for _, chart := range installed {
+		for _, reference := range chart.references() {
+			existing[reference] = true
+		}
+	}
+	// references of the remaining charts, used to simulate the deletion
+	references := map[*InstalledChart]map[string]bool{}
+	for _, chart := range installed {
+		references[chart] = map[string]bool{}
+		for _, reference := range sortedStrings(chart.UsedBy) {
+			if existing[reference] {
+				references[chart][reference] = true
+			} else {
+				gc.StaleReferences = append(gc.StaleReferences, StaleReference{Chart: chart, Reference: reference})
+			}
+		}
+		for _, reference := range declared[dependencyKey(chart.Namespace, chart.Genus)] {
+			references[chart][reference] = true
+		}
+	}
+	for {
+		var orphans []*InstalledChart
+		for _, chart := range installed {
+			// charts applied directly are never collected
+			if chart.Dependency && references[chart] != nil && len(references[chart]) == 0 {
+				orphans = append(orphans, chart)
+			}
+		}
+		if len(orphans) == 0 {
+			return gc, nil
+		}
+		for _, orphan := range orphans {
+			gc.Orphans = append(gc.Orphans, orphan)
+			delete(references, orphan)
+			for _, refs := range references {
+				for _, reference := range orphan.references() {
+					delete(refs, reference)
+				}
+			}
+		}
+	}
+}
+
+// Write -
+func (gc *GarbageCollection) Write(writer io.Writer) error {
+	for _, stale := range gc.StaleReferences {
+		if _, err := fmt.Fprintf(writer, "remove stale reference %s from %s/%s\n", stale.Reference, stale.Chart.Namespace, stale.Chart.Genus); err != nil {
+			return err
+		}
+	}
+	for _, orphan := range gc.Orphans {
+		if _, err := fmt.Fprintf(writer, "delete orphaned chart %s/%s %s\n", orphan.Namespace, orphan.Genus, orphan.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run removes the stale references and deletes the orphaned charts
+func (gc *GarbageCollection) Run(thread *starlark.Thread, repo Repo, k k8s.K8s) error {
+	for _, stale := range gc.StaleReferences {
+		patch := fmt.Sprintf(`[{"op": "remove", "path": "/metadata/annotations/%s%s"}]`, usedByPrefix, stale.Reference)
+		if _, err := k.Patch("configmap", "kdo."+stale.Chart.Genus, types.JSONPatchType, patch, &k8s.Options{Namespace: stale.Chart.Namespace, IgnoreNotFound: true}); err != nil {
+			return fmt.Errorf("can't remove stale reference %s from %s/%s: %v", stale.Reference, stale.Chart.Namespace, stale.Chart.Genus, err)
+		}
+	}
+	for _, orphan := range gc.Orphans {
+		charts, err := repo.List(thread, k, &RepoListOptions{namespace: orphan.Namespace, genus: orphan.Genus})
+		if err != nil {
+			return err
+		}
+		for _, chart := range charts {
+			if err := chart.Delete(thread, k, &DeleteOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package kdo
+
+import (
+	"bytes"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Garbage collection", func() {
+	var dir TestDir
+	var repo Repo
+	var k *k8s.K8sInMemory
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		repo, _ = NewRepo()
+		dependencies := map[string]string{"a": "base", "base": "core", "b": "other"}
+		for _, name := range []string{"a", "b", "base", "core", "other"} {
+			dir.MkdirAll(name, 0755)
+			dir.WriteFile(name+"/Chart.yaml", []byte("name: "+name+"\nversion: 1.0.0\n"), 0644)
+			if dep, ok := dependencies[name]; ok {
+				dir.WriteFile(name+"/Chart.star", []byte("def init(self):\n  self.dep = depends_on(\""+dir.Join(dep)+"\", \">= 1.0\")\n"), 0644)
+			}
+		}
+		k = k8s.NewK8sInMemory("default")
+		for _, name := range []string{"a", "b"} {
+			c, err := repo.Get(thread, dir.Join(name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Apply(thread, k)).To(Succeed())
+		}
+		// chart a was deleted without removing its reference
+		Expect(k.DeleteByName("configmap", "kdo.a", &k8s.Options{})).To(Succeed())
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("plans the repair of stale references and the deletion of orphans in dependency order", func() {
+		gc, err := PlanGarbageCollection(thread, repo, k)
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(gc.Write(buf)).To(Succeed())
+		Expect(buf.String()).To(Equal("remove stale reference default_a from default/base\ndelete orphaned chart default/base 1.0.0\ndelete orphaned chart default/core 1.0.0\n"))
+	})
+
+	It("deletes orphaned dependencies", func() {
+		gc, err := PlanGarbageCollection(thread, repo, k)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gc.Run(thread, repo, k)).To(Succeed())
+		charts, err := ListInstalled(k, &RepoListOptions{allNamespaces: true})
+		Expect(err).NotTo(HaveOccurred())
+		genera := []string{}
+		for _, c := range charts {
+			genera = append(genera, c.Genus)
+		}
+		Expect(genera).To(Equal([]string{"b", "other"}))
+
+		gc, err = PlanGarbageCollection(thread, repo, k)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gc.StaleReferences).To(BeEmpty())
+		Expect(gc.Orphans).To(BeEmpty())
+	})
+
+	It("keeps dependencies, which have been applied directly", func() {
+		configMap, err := k.Get("configmap", "kdo.base", &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMap.MetaData.Annotations).To(HaveKey("kdo-dependency"))
+		c, err := repo.Get(thread, dir.Join("base"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Apply(thread, k)).To(Succeed())
+		configMap, err = k.Get("configmap", "kdo.base", &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMap.MetaData.Annotations).NotTo(HaveKey("kdo-dependency"))
+		gc, err := PlanGarbageCollection(thread, repo, k)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gc.Orphans).To(BeEmpty())
+	})
+
+	It("handles references written by older versions", func() {
+		_, err := k.Patch("configmap", "kdo.other", types.JSONPatchType, `[{"op": "remove", "path": "/metadata/annotations/kdo-usedby-default_b"}, {"op": "add", "path": "/metadata/annotations/kdo-usedby-default-b", "value": ">= 1.0"}]`, &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		gc, err := PlanGarbageCollection(thread, repo, k)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gc.StaleReferences).To(HaveLen(1))
+		Expect(gc.StaleReferences[0].Reference).To(Equal("default_a"))
+		charts, err := repo.List(thread, k, &RepoListOptions{namespace: "default", genus: "other"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(charts[0].RemoveUsedBy("default_b", k)).To(Equal(0))
+	})
+
+	It("keeps declared dependencies without reference", func() {
+		_, err := k.Patch("configmap", "kdo.other", types.JSONPatchType, `[{"op": "remove", "path": "/metadata/annotations/kdo-usedby-default_b"}]`, &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		gc, err := PlanGarbageCollection(thread, repo, k)
+		Expect(err).NotTo(HaveOccurred())
+		for _, orphan := range gc.Orphans {
+			Expect(orphan.Genus).NotTo(Equal("other"))
+		}
+	})
+})
@@ -0,0 +1,234 @@
+package kdo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// gitURL matches git+https://host/org/repo.git//subdir?ref=v1.2.3, git+ssh://... and git+file://...
+var gitURL = regexp.MustCompile(`^git\+((?:https|http|ssh|file)://[^?#]*?)(?://([^?#]*))?(?:\?ref=([^&#]+))?$`)
+
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// gitReference - a directory in a git repository at a given ref
+type gitReference struct {
+	repository string
+	subdir     string
+	ref        string
+}
+
+func parseGitReference(uri string) (*gitReference, error) {
+	match := gitURL.FindStringSubmatch(uri)
+	if match == nil {
+		return nil, fmt.Errorf("Invalid git url %s", uri)
+	}
+	return &gitReference{repository: match[1], subdir: match[2], ref: match[3]}, nil
+}
+
+// name returns the name of the repository and the sub directory without the .git suffix
+func (g *gitReference) name() string {
+	name := g.repository[strings.Index(g.repository, "://")+3:]
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[i+1:]
+	}
+	return path.Join(strings.TrimSuffix(name, ".git"), g.subdir)
+}
+
+type gitClient struct {
+	credentials *credentials
+}
+
+// env returns the environment of git commands including the credentials configured for the repository
+func (c *gitClient) env(repository string) ([]string, error) {
+	config := [][2]string{}
+	if strings.HasPrefix(repository, "file://") {
+		config = append(config, [2]string{"protocol.file.allow", "always"})
+	}
+	creds, err := c.credentials.lookup(repository)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		if creds.Bearer != "" {
+			config = append(config, [2]string{"http.extraHeader", "Authorization: Bearer " + creds.Bearer})
+		} else if creds.Token != "" || creds.Username != "" {
+			username, password := creds.Username, creds.Password
+			if creds.Token != "" {
+				password = creds.Token
+				if username == "" {
+					username = "x-access-token"
+				}
+			}
+			config = append(config, [2]string{"http.extraHeader", "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))})
+		}
+		for _, key := range sortedStrings(creds.Headers) {
+			config = append(config, [2]string{"http.extraHeader", key + ": " + creds.Headers[key]})
+		}
+	}
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0", fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(config)))
+	for i, entry := range config {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, entry[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, entry[1]))
+	}
+	return env, nil
+}
+
+func (c *gitClient) git(repository string, dir string, args ...string) (string, error) {
+	env, err := c.env(repository)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed for %s: %v %s", args[0], repository, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// resolve returns the commit SHA of a branch or tag
+func (c *gitClient) resolve(g *gitReference) (string, error) {
+	if commitSHA.MatchString(g.ref) {
+		return g.ref, nil
+	}
+	ref := g.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	out, err := c.git(g.repository, "", "ls-remote", g.repository, ref, "refs/tags/"+ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	for _, name := range []string{"refs/tags/" + ref + "^{}", "refs/tags/" + ref, "refs/heads/" + ref, ref} {
+		if sha, ok := refs[name]; ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("Ref %s not found in git repository %s", ref, g.repository)
+}
+
+// load clones a repository at a commit into the target directory. The commit SHA is used as etag.
+func (c *gitClient) load(name string, targetDir func() (string, error), etagOld string) (string, error) {
+	i := strings.LastIndex(name, "@")
+	repository, sha := name[:i], name[i+1:]
+	if etagOld == sha {
+		return sha, nil
+	}
+	dir, err := targetDir()
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.git(repository, "", "clone", "--no-checkout", repository, dir); err != nil {
+		return "", err
+	}
+	if _, err := c.git(repository, dir, "checkout", "--detach", sha); err != nil {
+		return "", err
+	}
+	if _, err := c.git(repository, dir, "submodule", "update", "--init", "--recursive"); err != nil {
+		return "", err
+	}
+	return sha, removeGitDirs(dir)
+}
+
+// removeGitDirs removes the git meta data, which must not become part of the chart
+func removeGitDirs(dir string) error {
+	gitDirs := make([]string, 0)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == ".git" {
+			gitDirs = append(gitDirs, p)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range gitDirs {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRef writes the commit SHA of a branch or tag into the target directory. The commit SHA is used as etag.
+func (c *gitClient) loadRef(name string, targetDir func() (string, error), etagOld string) (string, error) {
+	g, err := parseGitReference(name)
+	if err != nil {
+		return "", err
+	}
+	sha, err := c.resolve(g)
+	if err != nil || sha == etagOld {
+		return sha, err
+	}
+	dir, err := targetDir()
+	if err != nil {
+		return "", err
+	}
+	return sha, ioutil.WriteFile(path.Join(dir, "commit"), []byte(sha), 0644)
+}
+
+// openGit clones git repositories into the dir cache. Branches and tags are resolved to commit SHAs,
+// so the cache is keyed by the exact commit.
+func openGit(dirCache *DirCache, client *gitClient, verifier *verifier, cache OpenDirCache) OpenDirCache {
+	gitCache := dirCache.WrapRemoteDir(client.load)
+	refCache := dirCache.WrapRemoteDir(client.loadRef)
+	return func(uri string) (string, error) {
+		if !strings.HasPrefix(uri, "git+") {
+			return cache(uri)
+		}
+		if err := verifier.checkUnsigned(uri); err != nil {
+			return "", err
+		}
+		g, err := parseGitReference(uri)
+		if err != nil {
+			return "", err
+		}
+		sha := g.ref
+		if !commitSHA.MatchString(sha) {
+			name := "git+" + g.repository
+			if g.ref != "" {
+				name += "?ref=" + g.ref
+			}
+			dir, err := refCache(name)
+			if err != nil {
+				return "", err
+			}
+			commit, err := ioutil.ReadFile(path.Join(dir, "commit"))
+			if err != nil {
+				return "", err
+			}
+			sha = string(commit)
+		}
+		dir, err := gitCache(g.repository + "@" + sha)
+		if err != nil {
+			return "", errors.Wrapf(err, "Can't clone %s", uri)
+		}
+		return path.Join(dir, g.subdir), nil
+	}
+}
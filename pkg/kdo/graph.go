@@ -0,0 +1,261 @@
+package kdo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+)
+
+// GraphNode - an installed chart
+type GraphNode struct {
+	ID        string `json:"id"`
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Genus     string `json:"genus"`
+	Version   string `json:"version"`
+	// Orphaned is true for dependencies without references, which no installed chart declares
+	Orphaned bool `json:"orphaned,omitempty"`
+	// MissingReference is true for dependencies declared by an installed chart without used-by annotation
+	MissingReference bool `json:"missingReference,omitempty"`
+}
+
+// GraphEdge - a dependency between two installed charts
+type GraphEdge struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Constraint string `json:"constraint,omitempty"`
+	// Declared is true, if the installed version of the chart declares the dependency
+	Declared bool `json:"declared"`
+	// Recorded is true, if the dependency has a used-by annotation
+	Recorded bool `json:"recorded"`
+}
+
+// DanglingReference - a reference to a chart, which doesn't exist
+type DanglingReference struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Message string `json:"message"`
+}
+
+// Graph - the installed charts and their dependencies
+type Graph struct {
+	Nodes    []*GraphNode         `json:"nodes"`
+	Edges    []*GraphEdge         `json:"edges"`
+	Dangling []*DanglingReference `json:"dangling"`
+}
+
+// NewGraph -
+func NewGraph() *Graph {
+	return &Graph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}, Dangling: []*DanglingReference{}}
+}
+
+func graphID(cluster string, namespace string, genus string) string {
+	if cluster != "" {
+		return cluster + "/" + namespace + "/" + genus
+	}
+	return namespace + "/" + genus
+}
+
+// AddCluster adds the charts installed in all namespaces of a cluster
+func (g *Graph) AddCluster(thread *starlark.Thread, repo Repo, k k8s.K8s, cluster string) error {
+	listOptions := &RepoListOptions{allNamespaces: true}
+	installed, err := ListInstalled(k, listOptions)
+	if err != nil {
+		return err
+	}
+	charts, err := repo.List(thread, k, listOptions)
+	if err != nil {
+		return err
+	}
+	nodes := map[string]*GraphNode{}
+	references := map[string]*GraphNode{}
+	for _, chart := range installed {
+		node := &GraphNode{
+			ID:        graphID(cluster, chart.Namespace, chart.Genus),
+			Cluster:   cluster,
+			Namespace: chart.Namespace,
+			Genus:     chart.Genus,
+			Version:   chart.Version,
+			Orphaned:  chart.Dependency && len(chart.UsedBy) == 0,
+		}
+		g.Nodes = append(g.Nodes, node)
+		nodes[node.ID] = node
+		for _, reference := range chart.references() {
+			references[reference] = node
+		}
+	}
+	edges := map[string]*GraphEdge{}
+	edge := func(from string, to string) *GraphEdge {
+		e, ok := edges[from+" "+to]
+		if !ok {
+			e = &GraphEdge{From: from, To: to}
+			edges[from+" "+to] = e
+			g.Edges = append(g.Edges, e)
+		}
+		return e
+	}
+	for _, chart := range installed {
+		to := graphID(cluster, chart.Namespace, chart.Genus)
+		for _, reference := range sortedStrings(chart.UsedBy) {
+			from, ok := references[reference]
+			if !ok {
+				g.Dangling = append(g.Dangling, &DanglingReference{From: reference, To: to, Message: "used by a chart, which doesn't exist"})
+				continue
+			}
+			e := edge(from.ID, to)
+			e.Recorded = true
+			if constraint := chart.UsedBy[reference]; constraint != unknownConstraint {
+				e.Constraint = constraint
+			}
+		}
+	}
+	for _, chart := range charts {
+		c, ok := chart.(*chartImpl)
+		if !ok {
+			continue
+		}
+		from := graphID(cluster, c.GetNamespace(), c.GetGenus())
+		for _, dep := range declaredDependencies(c) {
+			to := graphID(cluster, dep.namespace, NewGenusAndVersion(dep.url).genus)
+			node, ok := nodes[to]
+			if !ok {
+				g.Dangling = append(g.Dangling, &DanglingReference{From: from, To: to, Message: fmt.Sprintf("depends on %s, which isn't installed", dep.url)})
+				continue
+			}
+			e := edge(from, to)
+			e.Declared = true
+			if e.Constraint == "" {
+				e.Constraint = dep.constraint.String()
+			}
+			if !e.Recorded {
+				node.MissingReference = true
+				node.Orphaned = false
+			}
+		}
+	}
+	return nil
+}
+
+// declaredDependencies returns the dependencies of a chart and its sub charts
+func declaredDependencies(c *chartImpl) []*dependency {
+	result := make([]*dependency, 0)
+	for _, name := range sortedStrings(c.values) {
+		switch v := c.values[name].(type) {
+		case *dependency:
+			result = append(result, v)
+		case *chartImpl:
+			result = append(result, declaredDependencies(v)...)
+		}
+	}
+	return result
+}
+
+func sortedStrings(m interface{}) []string {
+	keys := make([]string, 0)
+	switch v := m.(type) {
+	case map[string]string:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]bool:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case starlark.StringDict:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Write writes the graph in dot, mermaid or json format
+func (g *Graph) Write(writer io.Writer, format string) error {
+	switch format {
+	case "dot", "":
+		return g.writeDot(writer)
+	case "mermaid":
+		return g.writeMermaid(writer)
+	case "json":
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(g)
+	}
+	return fmt.Errorf("Unknown format %s. Supported formats are dot, mermaid and json", format)
+}
+
+func (g *Graph) writeDot(writer io.Writer) error {
+	lines := []string{"digraph kdo {"}
+	for _, n := range g.Nodes {
+		attributes := ""
+		if n.Orphaned {
+			attributes = ", color=orange, style=dashed"
+		} else if n.MissingReference {
+			attributes = ", color=purple"
+		}
+		lines = append(lines, fmt.Sprintf("  %q [label=%q%s];", n.ID, n.ID+"\n"+n.Version, attributes))
+	}
+	for _, e := range g.Edges {
+		attributes := []string{}
+		if e.Constraint != "" {
+			attributes = append(attributes, fmt.Sprintf("label=%q", e.Constraint))
+		}
+		if !e.Recorded {
+			attributes = append(attributes, "style=dashed")
+		}
+		lines = append(lines, fmt.Sprintf("  %q -> %q [%s];", e.From, e.To, strings.Join(attributes, ", ")))
+	}
+	for _, d := range g.Dangling {
+		lines = append(lines, fmt.Sprintf("  %q -> %q [color=red, style=dotted, label=%q];", d.From, d.To, d.Message))
+	}
+	lines = append(lines, "}")
+	_, err := fmt.Fprintln(writer, strings.Join(lines, "\n"))
+	return err
+}
+
+func (g *Graph) writeMermaid(writer io.Writer) error {
+	ids := map[string]string{}
+	id := func(name string) string {
+		if _, ok := ids[name]; !ok {
+			ids[name] = fmt.Sprintf("n%d", len(ids))
+		}
+		return ids[name]
+	}
+	lines := []string{"graph LR"}
+	for _, n := range g.Nodes {
+		line := fmt.Sprintf("  %s[\"%s %s\"]", id(n.ID), n.ID, n.Version)
+		if n.Orphaned {
+			line += ":::orphaned"
+		} else if n.MissingReference {
+			line += ":::missingReference"
+		}
+		lines = append(lines, line)
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if !e.Recorded {
+			arrow = "-.->"
+		}
+		if e.Constraint != "" {
+			arrow += fmt.Sprintf("|\"%s\"|", e.Constraint)
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s %s", id(e.From), arrow, id(e.To)))
+	}
+	for _, d := range g.Dangling {
+		for _, name := range []string{d.From, d.To} {
+			if _, ok := ids[name]; !ok {
+				lines = append(lines, fmt.Sprintf("  %s[\"%s\"]:::dangling", id(name), name))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("  %s -.->|\"%s\"| %s", id(d.From), d.Message, id(d.To)))
+	}
+	lines = append(lines, "  classDef orphaned stroke:orange,stroke-dasharray:5", "  classDef missingReference stroke:purple", "  classDef dangling stroke:red,stroke-dasharray:2")
+	_, err := fmt.Fprintln(writer, strings.Join(lines, "\n"))
+	return err
+}
@@ -0,0 +1,96 @@
+package kdo
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Graph", func() {
+	var dir TestDir
+	var g *Graph
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		repo, _ := NewRepo()
+		for _, name := range []string{"a", "b", "c", "base", "other", "lonely"} {
+			dir.MkdirAll(name, 0755)
+			dir.WriteFile(name+"/Chart.yaml", []byte("name: "+name+"\nversion: 1.0.0\n"), 0644)
+		}
+		dir.WriteFile("a/Chart.star", []byte(`
+def init(self):
+  self.base = depends_on("`+dir.Join("base")+`", ">= 1.0")
+`), 0644)
+		dir.WriteFile("b/Chart.star", []byte(`
+def init(self):
+  self.other = depends_on("`+dir.Join("other")+`", "1.x")
+`), 0644)
+		dir.WriteFile("c/Chart.star", []byte(`
+def init(self):
+  self.lonely = depends_on("`+dir.Join("lonely")+`", "1.x")
+`), 0644)
+		k := k8s.NewK8sInMemory("default")
+		for _, name := range []string{"a", "b", "c"} {
+			c, err := repo.Get(thread, dir.Join(name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Apply(thread, k)).To(Succeed())
+		}
+		// chart a was deleted without removing its reference
+		Expect(k.DeleteByName("configmap", "kdo.a", &k8s.Options{})).To(Succeed())
+		// chart b lost its reference to other
+		_, err := k.Patch("configmap", "kdo.other", types.JSONPatchType, `[{"op": "remove", "path": "/metadata/annotations/kdo-usedby-default_b"}]`, &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		// chart c was deleted properly, but lonely was kept
+		Expect(k.DeleteByName("configmap", "kdo.c", &k8s.Options{})).To(Succeed())
+		_, err = k.Patch("configmap", "kdo.lonely", types.JSONPatchType, `[{"op": "remove", "path": "/metadata/annotations/kdo-usedby-default_c"}]`, &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+
+		g = NewGraph()
+		Expect(g.AddCluster(thread, repo, k, "")).To(Succeed())
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("flags orphaned dependencies, missing and dangling references", func() {
+		buf := &bytes.Buffer{}
+		Expect(g.Write(buf, "json")).To(Succeed())
+		var result Graph
+		Expect(json.Unmarshal(buf.Bytes(), &result)).To(Succeed())
+		Expect(result.Nodes).To(ConsistOf(
+			&GraphNode{ID: "default/b", Namespace: "default", Genus: "b", Version: "1.0.0"},
+			&GraphNode{ID: "default/base", Namespace: "default", Genus: "base", Version: "1.0.0"},
+			&GraphNode{ID: "default/lonely", Namespace: "default", Genus: "lonely", Version: "1.0.0", Orphaned: true},
+			&GraphNode{ID: "default/other", Namespace: "default", Genus: "other", Version: "1.0.0", MissingReference: true},
+		))
+		Expect(result.Edges).To(ConsistOf(&GraphEdge{From: "default/b", To: "default/other", Constraint: "1.x", Declared: true}))
+		Expect(result.Dangling).To(ConsistOf(&DanglingReference{From: "default_a", To: "default/base", Message: "used by a chart, which doesn't exist"}))
+	})
+
+	It("renders dot and mermaid", func() {
+		buf := &bytes.Buffer{}
+		Expect(g.Write(buf, "dot")).To(Succeed())
+		Expect(buf.String()).To(HavePrefix("digraph kdo {\n"))
+		Expect(buf.String()).To(ContainSubstring(`"default/lonely" [label="default/lonely\n1.0.0", color=orange, style=dashed];`))
+		Expect(buf.String()).To(ContainSubstring(`"default/other" [label="default/other\n1.0.0", color=purple];`))
+		Expect(buf.String()).To(ContainSubstring(`"default/b" -> "default/other" [label="1.x", style=dashed];`))
+		Expect(buf.String()).To(ContainSubstring(`"default_a" -> "default/base" [color=red, style=dotted`))
+
+		buf.Reset()
+		Expect(g.Write(buf, "mermaid")).To(Succeed())
+		Expect(buf.String()).To(HavePrefix("graph LR\n"))
+		Expect(buf.String()).To(ContainSubstring(`n2["default/lonely 1.0.0"]:::orphaned`))
+		Expect(buf.String()).To(ContainSubstring(`n3["default/other 1.0.0"]:::missingReference`))
+		Expect(buf.String()).To(ContainSubstring(`n0 -.->|"1.x"| n3`))
+		Expect(buf.String()).To(ContainSubstring(`n4["default_a"]:::dangling`))
+
+		Expect(g.Write(buf, "svg")).To(MatchError(ContainSubstring("Unknown format svg")))
+	})
+})
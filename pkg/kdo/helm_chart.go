@@ -1,30 +1,24 @@
 package kdo
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
-	"strings"
 
 	"github.com/k14s/starlark-go/starlark"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
-	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
 )
 
-// NewHelmChartFunction -
+// NewHelmChartFunction - loads a chart, which is installed as helm release. Templates are rendered in-process,
+// hooks are run and the releases are stored in the format of helm v3, so that helm can be used to inspect them.
 func NewHelmChartFunction(repo Repo, dir string, options ...ChartOption) func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
 	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
 		var url string
 		co := chartOptions(options)
-		if err := starlark.UnpackArgs("chart", args, kwargs, "url", &url, "namespace?", &co.namespace, "suffix?", &co.suffix); err != nil {
+		co.helmDependencies = false
+		if err := starlark.UnpackArgs("chart", args, kwargs, "url", &url, "namespace?", &co.namespace, "suffix?", &co.suffix, "helm_dependencies?", &co.helmDependencies); err != nil {
 			return starlark.None, err
 		}
-		if !(filepath.IsAbs(url) || strings.HasPrefix(url, "http")) {
+		if !(filepath.IsAbs(url) || urlPattern.MatchString(url)) {
 			url = path.Join(dir, url)
 		}
 		c, err := repo.Get(thread, url, co.Merge())
@@ -33,9 +27,10 @@ func NewHelmChartFunction(repo Repo, dir string, options ...ChartOption) func(th
 		}
 
 		chart := c.(*chartImpl)
+		chart.methods["template"] = helmTemplateFunction(chart, chart.methods["template"])
 		chart.methods["apply"] = chart.wrapNamespace(helmApplyFunction(chart))
-		chart.methods["template"] = helmTemplateFunction(chart)
 		chart.methods["delete"] = chart.wrapNamespace(helmDeleteFunction(chart))
+		chart.methods["rollback"] = chart.wrapNamespace(helmRollbackFunction(chart))
 		return chart, nil
 	}
 }
@@ -46,63 +41,56 @@ func helmApplyFunction(c *chartImpl) starlark.Callable {
 		if err := starlark.UnpackArgs("apply", args, kwargs, "k8s", &k); err != nil {
 			return nil, err
 		}
-		filename, err := values(c)
-		if err != nil {
-			return starlark.None, err
-		}
-		return starlark.None, helm(c, k, &k8s.Options{}, "upgrade", "-i", c.GetName(), c.dir, "-f", filename)
+		return starlark.None, newHelmEngine(c, k).install(thread)
 	})
 }
 
-func helmTemplateFunction(c *chartImpl) starlark.Callable {
+// helmTemplateFunction renders the templates like helm template does for a new release, unless a release is installed
+func helmTemplateFunction(c *chartImpl, template starlark.Callable) starlark.Callable {
 	return c.builtin("template", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
-		var k k8s.K8sValue
-		if err := starlark.UnpackArgs("template", args, kwargs, "k8s", &k); err != nil {
-			return starlark.None, err
+		if thread.Local(helmReleaseKey) == nil {
+			thread.SetLocal(helmReleaseKey, &release{Name: c.GetName(), Namespace: c.namespace, Service: "Helm", Revision: 1, IsInstall: true})
+			defer thread.SetLocal(helmReleaseKey, nil)
 		}
-		filename, err := values(c)
-		if err != nil {
-			return starlark.None, err
-		}
-		return starlark.String(""), helm(c, k, &k8s.Options{}, "template", c.dir, "-f", filename)
+		return starlark.Call(thread, template, args, kwargs)
 	})
 }
 
 func helmDeleteFunction(c *chartImpl) starlark.Callable {
-	return c.builtin("apply", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
+	return c.builtin("delete", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
 		var k k8s.K8sValue
-		if err := starlark.UnpackArgs("apply", args, kwargs, "k8s", &k); err != nil {
+		if err := starlark.UnpackArgs("delete", args, kwargs, "k8s", &k); err != nil {
 			return nil, err
 		}
-		_ = helm(c, k, &k8s.Options{}, "uninstall", c.GetName())
-		return starlark.None, nil
+		return starlark.None, newHelmEngine(c, k).uninstall()
 	})
 }
 
-func helm(chart *chartImpl, k k8s.K8s, options *k8s.Options, flags ...string) error {
-	namespace := k.Namespace(options)
-	if namespace != nil {
-		flags = append(flags, "-n", *namespace)
-	}
-	if options.Timeout > 0 {
-		flags = append(flags, "--timeout", fmt.Sprintf("%.0fs", options.Timeout.Seconds()))
-	}
-	cmd := exec.CommandContext(context.TODO(), "helm", flags...)
-	if !options.Quiet {
-		fmt.Println(cmd.String())
-	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func helmRollbackFunction(c *chartImpl) starlark.Callable {
+	return c.builtin("rollback", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
+		var k k8s.K8sValue
+		revision := 0
+		if err := starlark.UnpackArgs("rollback", args, kwargs, "k8s", &k, "revision?", &revision); err != nil {
+			return nil, err
+		}
+		return starlark.None, newHelmEngine(c, k).rollback(revision)
+	})
 }
 
-func values(c *chartImpl) (string, error) {
-	filename := "/tmp/values"
-	data, err := json.Marshal(starutils.ToGo(c.GetValue()))
-	if err != nil {
-		return filename, err
+// helmRelease returns the release set by helm_chart or the default release of kdo charts
+func (c *chartImpl) helmRelease(thread *starlark.Thread) release {
+	if r, ok := thread.Local(helmReleaseKey).(*release); ok {
+		return *r
+	}
+	return release{
+		Name:      c.GetName(),
+		Namespace: c.namespace,
+		Service:   c.GetName(),
+		Revision:  1,
+		IsInstall: false,
+		IsUpgrade: true,
 	}
-	err = ioutil.WriteFile(filename, data, os.FileMode(0755))
-	return filename, err
-
 }
+
+// helmReleaseKey - thread local of the release rendered by helm_chart
+const helmReleaseKey = "helm-release"
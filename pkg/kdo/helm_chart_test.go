@@ -0,0 +1,145 @@
+package kdo
+
+import (
+	"bytes"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Helm chart", func() {
+	var dir TestDir
+	var chart *chartImpl
+	var kim *k8s.K8sInMemory
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("redis/templates", 0755)
+		dir.WriteFile("redis/Chart.yaml", []byte("name: redis\nversion: 1.0.0\nappVersion: 6.0.9\n"), 0644)
+		dir.WriteFile("redis/values.yaml", []byte("replicas: 1\n"), 0644)
+		dir.WriteFile("redis/templates/config.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  revision: "{{ .Release.Revision }}"
+  install: "{{ .Release.IsInstall }}"
+  replicas: "{{ .Values.replicas }}"
+{{- if eq .Release.Revision 1 }}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+{{- end }}
+`), 0644)
+		dir.WriteFile("redis/templates/hooks.yaml", []byte(`
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-delete-policy: hook-succeeded
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: upgraded
+  annotations:
+    helm.sh/hook: post-upgrade
+    helm.sh/hook-weight: "5"
+`), 0644)
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		value, err := NewHelmChartFunction(repo, dir.Root(), WithNamespace("ns"))(thread, nil, starlark.Tuple{starlark.String("redis")}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		chart = value.(*chartImpl)
+		kim = k8s.NewK8sInMemory("ns")
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	config := func() string {
+		obj, err := kim.Get("configmap", "config", &k8s.Options{Namespace: "ns"})
+		Expect(err).NotTo(HaveOccurred())
+		return string(obj.Additional["data"])
+	}
+	history := func() []*helmReleaseRecord {
+		history, err := newHelmEngine(chart, kim).history()
+		Expect(err).NotTo(HaveOccurred())
+		return history
+	}
+
+	It("templates a new release", func() {
+		buf := &bytes.Buffer{}
+		Expect(chart.Template(thread, kim)(buf)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring(`revision: "1"`))
+		Expect(buf.String()).To(ContainSubstring(`install: "true"`))
+		Expect(buf.String()).To(ContainSubstring(`name: migrate`))
+	})
+
+	It("installs and upgrades releases", func() {
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		Expect(config()).To(ContainSubstring(`"revision":"1"`))
+		Expect(config()).To(ContainSubstring(`"install":"true"`))
+		_, err := kim.Get("job", "migrate", &k8s.Options{Namespace: "ns"})
+		Expect(kim.IsNotExist(err)).To(BeTrue())
+		_, err = kim.Get("configmap", "upgraded", &k8s.Options{Namespace: "ns"})
+		Expect(kim.IsNotExist(err)).To(BeTrue())
+		releases := history()
+		Expect(releases).To(HaveLen(1))
+		Expect(releases[0].Info.Status).To(Equal(helmStatusDeployed))
+		Expect(releases[0].Chart.Metadata.Version).To(Equal("1.0.0"))
+		Expect(releases[0].Chart.Metadata.AppVersion).To(Equal("6.0.9"))
+		Expect(releases[0].Hooks).To(HaveLen(2))
+		Expect(releases[0].Manifest).NotTo(ContainSubstring("migrate"))
+
+		Expect(chart.SetField("replicas", starlark.MakeInt(3))).To(Succeed())
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		Expect(config()).To(ContainSubstring(`"revision":"2"`))
+		Expect(config()).To(ContainSubstring(`"replicas":"3"`))
+		_, err = kim.Get("configmap", "upgraded", &k8s.Options{Namespace: "ns"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = kim.Get("configmap", "first", &k8s.Options{Namespace: "ns"})
+		Expect(kim.IsNotExist(err)).To(BeTrue())
+		releases = history()
+		Expect(releases).To(HaveLen(2))
+		Expect(releases[0].Info.Status).To(Equal(helmStatusSuperseded))
+		Expect(releases[1].Info.Status).To(Equal(helmStatusDeployed))
+		secret, err := kim.Get("secret", "sh.helm.release.v1.redis.v2", &k8s.Options{Namespace: "ns"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.MetaData.Labels).To(HaveKeyWithValue("owner", "helm"))
+		Expect(secret.MetaData.Labels).To(HaveKeyWithValue("status", "deployed"))
+	})
+
+	It("rolls back releases", func() {
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		_, err := starlark.Call(thread, chart.methods["rollback"], starlark.Tuple{k8s.NewK8sValue(kim)}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config()).To(ContainSubstring(`"revision":"1"`))
+		_, err = kim.Get("configmap", "first", &k8s.Options{Namespace: "ns"})
+		Expect(err).NotTo(HaveOccurred())
+		releases := history()
+		Expect(releases).To(HaveLen(3))
+		Expect(releases[2].Info.Description).To(Equal("Rollback to 1"))
+
+		_, err = starlark.Call(thread, chart.methods["rollback"], starlark.Tuple{k8s.NewK8sValue(kim)}, []starlark.Tuple{{starlark.String("revision"), starlark.MakeInt(7)}})
+		Expect(err).To(MatchError(ContainSubstring("Release redis has no revision 7")))
+	})
+
+	It("uninstalls releases", func() {
+		Expect(chart.Apply(thread, kim)).To(Succeed())
+		Expect(chart.Delete(thread, kim, &DeleteOptions{})).To(Succeed())
+		_, err := kim.Get("configmap", "config", &k8s.Options{Namespace: "ns"})
+		Expect(kim.IsNotExist(err)).To(BeTrue())
+		Expect(history()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,425 @@
+package kdo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	gotemplate "text/template"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/pkg/errors"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/renderer"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
+	"sigs.k8s.io/yaml"
+)
+
+// helmDependency - an entry of the dependencies of Chart.yaml or requirements.yaml
+type helmDependency struct {
+	Name         string        `json:"name"`
+	Version      string        `json:"version,omitempty"`
+	Repository   string        `json:"repository,omitempty"`
+	Condition    string        `json:"condition,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+	ImportValues []interface{} `json:"import-values,omitempty"`
+	Alias        string        `json:"alias,omitempty"`
+}
+
+type helmChartYaml struct {
+	Name         string           `json:"name"`
+	Version      string           `json:"version,omitempty"`
+	AppVersion   string           `json:"appVersion,omitempty"`
+	APIVersion   string           `json:"apiVersion,omitempty"`
+	Dependencies []helmDependency `json:"dependencies,omitempty"`
+}
+
+// helmSubChart - a helm chart with its dependencies. The values of the chart are its defaults until resolve is called.
+type helmSubChart struct {
+	name         string
+	dir          string
+	source       string
+	chart        chart
+	values       map[string]interface{}
+	dependencies []helmDependency
+	dependency   *helmDependency
+	subCharts    []*helmSubChart
+}
+
+func (c *chartImpl) helmDependencyTemplate(context helmContext, glob string, funcs gotemplate.FuncMap, sources bool) k8s.Stream {
+	source := func(dir string) string {
+		if !sources {
+			return ""
+		}
+		return path.Join(dir, "templates")
+	}
+	return func(writer io.Writer) error {
+		tmp, err := ioutil.TempDir("", "kdo-helm-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		root, err := loadHelmSubChart(c.dir, tmp)
+		if err != nil {
+			return err
+		}
+		if err := root.resolve(context.Values, asValues(context.Values["tags"])); err != nil {
+			return err
+		}
+		descendants := root.descendants()
+		dirs := make([]string, 0, len(descendants)+1)
+		for _, s := range descendants {
+			dirs = append(dirs, s.dir)
+		}
+		fileRenderer := renderer.HelmTreeRenderer(append(dirs, c.dir), funcs)
+		streams := []k8s.Stream{}
+		for _, s := range descendants {
+			subContext := context
+			subContext.Values = s.values
+			subContext.Chart = s.chart
+			subContext.Methods = map[string]interface{}{}
+			subContext.Files = renderer.Files{Dir: s.dir}
+			streams = append(streams, renderer.DirRender(glob, renderer.DirSpec{
+				Dir:          path.Join(s.dir, "templates"),
+				FileRenderer: fileRenderer(subContext),
+				Source:       source(s.source),
+			}))
+		}
+		streams = append(streams, renderer.DirRender(glob, renderer.DirSpec{
+			Dir:          path.Join(c.dir, "templates"),
+			FileRenderer: fileRenderer(context),
+			Source:       source(""),
+		}))
+		return k8s.YamlConcat(streams...)(writer)
+	}
+}
+
+// helmValues converts the values of a chart to go. Unlike GetValue, false is kept in struct properties, because helm
+// charts use it to disable dependencies in conditions and tags.
+func helmValues(values starlark.StringDict) map[string]interface{} {
+	result := map[string]interface{}{}
+	for name, value := range values {
+		if v := helmValue(value); v != nil {
+			result[name] = v
+		}
+	}
+	return result
+}
+
+func helmValue(value starlark.Value) interface{} {
+	s, ok := value.(*structProperty)
+	if !ok {
+		return starutils.ToGo(value)
+	}
+	result := map[string]interface{}{}
+	for name, property := range s.properties {
+		if child, ok := property.(*structProperty); ok {
+			if m := helmValue(child).(map[string]interface{}); len(m) != 0 {
+				result[name] = m
+			}
+			continue
+		}
+		if v := property.GetValueOrDefault(); v.Truth() || v == starlark.False {
+			result[name] = starutils.ToGo(v)
+		}
+	}
+	return result
+}
+
+// loadHelmSubChart loads the chart in dir and its dependencies from the charts folder. Archived charts are extracted to tmp.
+func loadHelmSubChart(dir string, tmp string) (*helmSubChart, error) {
+	var chartYaml helmChartYaml
+	if err := readHelmYaml(path.Join(dir, "Chart.yaml"), &chartYaml); err != nil {
+		return nil, err
+	}
+	if len(chartYaml.Dependencies) == 0 {
+		var requirements helmChartYaml
+		if err := readHelmYaml(path.Join(dir, "requirements.yaml"), &requirements); err != nil {
+			return nil, err
+		}
+		chartYaml.Dependencies = requirements.Dependencies
+	}
+	values := map[string]interface{}{}
+	if err := readHelmYaml(path.Join(dir, "values.yaml"), &values); err != nil {
+		return nil, err
+	}
+	if chartYaml.Name == "" {
+		chartYaml.Name = filepath.Base(dir)
+	}
+	result := &helmSubChart{
+		name: chartYaml.Name,
+		dir:  dir,
+		chart: chart{
+			Name:       chartYaml.Name,
+			Version:    chartYaml.Version,
+			AppVersion: chartYaml.AppVersion,
+			APIVersion: chartYaml.APIVersion,
+		},
+		values:       values,
+		dependencies: chartYaml.Dependencies,
+	}
+	available, err := loadHelmChartsFolder(path.Join(dir, "charts"), tmp)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for i := range chartYaml.Dependencies {
+		dependency := &chartYaml.Dependencies[i]
+		var subChart *helmSubChart
+		for _, a := range available {
+			if a.name == dependency.Name {
+				subChart = a
+				break
+			}
+		}
+		if subChart == nil && strings.HasPrefix(dependency.Repository, "file://") {
+			subChart, err = loadHelmSubChart(path.Join(dir, strings.TrimPrefix(dependency.Repository, "file://")), tmp)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if subChart == nil {
+			return nil, fmt.Errorf("Dependency %s of helm chart %s not found in %s. Run 'helm dependency update' to download it", dependency.Name, chartYaml.Name, path.Join(dir, "charts"))
+		}
+		used[dependency.Name] = true
+		copied := *subChart
+		copied.dependency = dependency
+		if dependency.Alias != "" {
+			copied.name = dependency.Alias
+		}
+		result.subCharts = append(result.subCharts, &copied)
+	}
+	for _, a := range available {
+		if !used[a.name] {
+			result.subCharts = append(result.subCharts, a)
+		}
+	}
+	return result, nil
+}
+
+func loadHelmChartsFolder(dir string, tmp string) ([]*helmSubChart, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := make([]*helmSubChart, 0)
+	for _, entry := range entries {
+		chartDir := path.Join(dir, entry.Name())
+		switch {
+		case entry.IsDir():
+			if _, err := os.Stat(path.Join(chartDir, "Chart.yaml")); err != nil {
+				continue
+			}
+		case strings.HasSuffix(entry.Name(), ".tgz") || strings.HasSuffix(entry.Name(), ".tar.gz"):
+			chartDir, err = extractHelmArchive(chartDir, tmp)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		subChart, err := loadHelmSubChart(chartDir, tmp)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, subChart)
+	}
+	return result, nil
+}
+
+func extractHelmArchive(file string, tmp string) (string, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	dir, err := ioutil.TempDir(tmp, "chart-")
+	if err != nil {
+		return "", err
+	}
+	if err := extractArchive(in, dir); err != nil {
+		return "", errors.Wrapf(err, "Unable to extract helm chart %s", file)
+	}
+	return dir, nil
+}
+
+func readHelmYaml(filename string, value interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := yaml.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("Error during parsing file %s: %s", filename, err.Error())
+	}
+	return nil
+}
+
+// resolve computes the values of the chart and its sub charts like helm. values are the values of the chart, which take
+// precedence over the defaults of the sub charts. tags are the tags of the root chart. Disabled sub charts are removed.
+func (h *helmSubChart) resolve(values map[string]interface{}, tags map[string]interface{}) error {
+	h.values = values
+	enabled := make([]*helmSubChart, 0)
+	for _, s := range h.subCharts {
+		if !s.enabled(values, tags) {
+			continue
+		}
+		subChart := *s
+		subChart.source = path.Join(h.source, "charts", s.chart.Name)
+		subValues := coalesceValues(copyValues(asValues(values[s.name])), copyValues(s.values))
+		subValues["global"] = coalesceValues(copyValues(asValues(values["global"])), asValues(subValues["global"]))
+		if err := subChart.resolve(subValues, tags); err != nil {
+			return err
+		}
+		values[s.name] = subValues
+		enabled = append(enabled, &subChart)
+	}
+	h.subCharts = enabled
+	for _, s := range h.subCharts {
+		if s.dependency == nil {
+			continue
+		}
+		for _, i := range s.dependency.ImportValues {
+			if err := h.importValues(s, i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enabled evaluates the condition of the dependency with the values of the parent chart and its tags with the tags of
+// the root chart
+func (h *helmSubChart) enabled(values map[string]interface{}, tags map[string]interface{}) bool {
+	if h.dependency == nil {
+		return true
+	}
+	if h.dependency.Condition != "" {
+		for _, condition := range strings.Split(h.dependency.Condition, ",") {
+			if value, ok := valueAt(values, strings.TrimSpace(condition)).(bool); ok {
+				return value
+			}
+		}
+	}
+	if len(h.dependency.Tags) == 0 {
+		return true
+	}
+	found := false
+	for _, tag := range h.dependency.Tags {
+		if value, ok := tags[tag].(bool); ok {
+			if value {
+				return true
+			}
+			found = true
+		}
+	}
+	return !found
+}
+
+// importValues copies the values of a sub chart to the chart. Either the child values exports.<name> are merged into
+// the root or the child value is copied to the parent path. Values of the chart take precedence.
+func (h *helmSubChart) importValues(s *helmSubChart, spec interface{}) error {
+	var child, parent string
+	switch spec := spec.(type) {
+	case string:
+		child, parent = "exports."+spec, ""
+	case map[string]interface{}:
+		child, _ = spec["child"].(string)
+		parent, _ = spec["parent"].(string)
+		if child == "" || parent == "" {
+			return fmt.Errorf("Invalid import-values of dependency %s: child and parent are required", s.name)
+		}
+	default:
+		return fmt.Errorf("Invalid import-values of dependency %s: %v", s.name, spec)
+	}
+	value := valueAt(s.values, child)
+	if value == nil {
+		return nil
+	}
+	if parent == "" || parent == "." {
+		coalesceValues(h.values, copyValues(asValues(value)))
+		return nil
+	}
+	keys := strings.Split(parent, ".")
+	target := h.values
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := target[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			target[key] = next
+		}
+		target = next
+	}
+	last := keys[len(keys)-1]
+	if existing, ok := target[last].(map[string]interface{}); ok {
+		coalesceValues(existing, copyValues(asValues(value)))
+	} else if _, ok := target[last]; !ok {
+		target[last] = value
+	}
+	return nil
+}
+
+// descendants returns the sub charts depth first, dependencies before the charts using them
+func (h *helmSubChart) descendants() []*helmSubChart {
+	result := make([]*helmSubChart, 0)
+	for _, s := range h.subCharts {
+		result = append(result, s.descendants()...)
+		result = append(result, s)
+	}
+	return result
+}
+
+// coalesceValues merges src into dst recursively. Values of dst take precedence.
+func coalesceValues(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	for key, value := range src {
+		existing, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		existingMap, ok := existing.(map[string]interface{})
+		valueMap, ok2 := value.(map[string]interface{})
+		if ok && ok2 {
+			coalesceValues(existingMap, valueMap)
+		}
+	}
+	return dst
+}
+
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if m, ok := value.(map[string]interface{}); ok {
+			value = copyValues(m)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+func asValues(value interface{}) map[string]interface{} {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+func valueAt(values map[string]interface{}, path string) interface{} {
+	var current interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
@@ -0,0 +1,168 @@
+package kdo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Helm dependencies", func() {
+	var dir TestDir
+	thread := &starlark.Thread{Name: "main"}
+
+	configMap := func(name string, data string) []byte {
+		return []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\ndata:\n" + data)
+	}
+	archive := func(files map[string]string) []byte {
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gz)
+		for name, content := range files {
+			Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+			_, err := tw.Write([]byte(content))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+		return buf.Bytes()
+	}
+	template := func(kwargs ...starlark.Tuple) (string, error) {
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		value, err := NewChartFunction(repo, dir.Root(), WithNamespace("ns"))(thread, nil, starlark.Tuple{starlark.String("umbrella")}, kwargs)
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		err = value.(*chartImpl).Template(thread, k8s.NewK8sInMemoryEmpty())(buf)
+		return buf.String(), err
+	}
+	enabled := starlark.Tuple{starlark.String("helm_dependencies"), starlark.True}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("umbrella/templates", 0755)
+		dir.MkdirAll("umbrella/charts/db/templates", 0755)
+		dir.MkdirAll("umbrella/charts/extra/templates", 0755)
+		dir.MkdirAll("lib/templates", 0755)
+		dir.WriteFile("umbrella/Chart.yaml", []byte(`
+apiVersion: v2
+name: umbrella
+version: 1.0.0
+dependencies:
+- name: db
+  version: 2.x
+  repository: https://charts.example.com
+  alias: database
+  condition: database.enabled
+  import-values:
+  - data
+  - child: port
+    parent: imported.port
+- name: cache
+  version: 1.0.0
+  tags: [cache]
+- name: lib
+  version: 1.0.0
+  repository: file://../lib
+`), 0644)
+		dir.WriteFile("umbrella/values.yaml", []byte(`
+global:
+  region: eu
+database:
+  enabled: true
+  host: db.example.com
+tags:
+  cache: false
+`), 0644)
+		dir.WriteFile("umbrella/templates/config.yaml", configMap("umbrella", `  host: "{{ .Values.database.host }}"
+  port: "{{ .Values.imported.port }}"
+  exported: "{{ .Values.exported }}"
+`), 0644)
+		dir.WriteFile("umbrella/charts/db/Chart.yaml", []byte("name: db\nversion: 2.1.0\n"), 0644)
+		dir.WriteFile("umbrella/charts/db/values.yaml", []byte("host: localhost\nport: 5432\nexports:\n  data:\n    exported: from-db\n"), 0644)
+		dir.WriteFile("umbrella/charts/db/templates/config.yaml", configMap("{{ .Chart.Name }}", `  host: "{{ .Values.host }}"
+  region: "{{ .Values.global.region }}"
+`), 0644)
+		dir.WriteFile("umbrella/charts/extra/Chart.yaml", []byte("name: extra\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("umbrella/charts/extra/templates/config.yaml", configMap("extra", "  region: \"{{ .Values.global.region }}\"\n"), 0644)
+		dir.WriteFile("umbrella/charts/cache-1.0.0.tgz", archive(map[string]string{
+			"cache/Chart.yaml":            "name: cache\nversion: 1.0.0\n",
+			"cache/templates/config.yaml": string(configMap("cache", "  chart: \"{{ .Chart.Name }}\"\n")),
+		}), 0644)
+		dir.WriteFile("lib/Chart.yaml", []byte("name: lib\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("lib/templates/config.yaml", configMap("lib", "  chart: \"{{ .Chart.Name }}\"\n"), 0644)
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("renders only the top level chart by default", func() {
+		out, err := template()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring("name: umbrella"))
+		Expect(out).NotTo(ContainSubstring("name: db"))
+	})
+
+	It("renders the dependencies with their values", func() {
+		out, err := template(enabled)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring(`host: "db.example.com"`))
+		Expect(out).To(ContainSubstring(`region: "eu"`))
+		Expect(out).To(ContainSubstring(`port: "5432"`))
+		Expect(out).To(ContainSubstring(`exported: "from-db"`))
+		Expect(out).To(ContainSubstring("name: db"))
+		Expect(out).To(ContainSubstring("name: extra"))
+		Expect(out).To(ContainSubstring("name: lib"))
+		Expect(out).NotTo(ContainSubstring("name: cache"))
+		Expect(out).NotTo(ContainSubstring(`host: "localhost"`))
+	})
+
+	It("honors conditions and tags", func() {
+		dir.WriteFile("umbrella/values.yaml", []byte("database:\n  enabled: false\ntags:\n  cache: true\n"), 0644)
+		out, err := template(enabled)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).NotTo(ContainSubstring("name: db"))
+		Expect(out).To(ContainSubstring(`chart: "cache"`))
+	})
+
+	It("shares the helpers of all charts", func() {
+		dir.WriteFile("lib/templates/_helpers.tpl", []byte(`{{- define "lib.name" -}}lib-{{ .Chart.Name }}{{- end -}}`), 0644)
+		dir.WriteFile("umbrella/templates/_helpers.tpl", []byte(`{{- define "umbrella.region" -}}{{ .Values.global.region }}-region{{- end -}}`), 0644)
+		dir.WriteFile("umbrella/templates/names.yaml", configMap("names", "  lib: \"{{ include \"lib.name\" . }}\"\n"), 0644)
+		dir.WriteFile("umbrella/charts/extra/templates/config.yaml", configMap("extra", "  region: \"{{ include \"umbrella.region\" . }}\"\n"), 0644)
+		out, err := template(enabled)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring(`lib: "lib-umbrella"`))
+		Expect(out).To(ContainSubstring(`region: "eu-region"`))
+	})
+
+	It("evaluates the tags of the root chart only", func() {
+		dir.MkdirAll("umbrella/charts/extra/charts/nested/templates", 0755)
+		dir.WriteFile("umbrella/charts/extra/Chart.yaml", []byte("name: extra\nversion: 1.0.0\ndependencies:\n- name: nested\n  tags: [nested]\n"), 0644)
+		dir.WriteFile("umbrella/charts/extra/values.yaml", []byte("tags:\n  nested: false\n"), 0644)
+		dir.WriteFile("umbrella/charts/extra/charts/nested/Chart.yaml", []byte("name: nested\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("umbrella/charts/extra/charts/nested/templates/config.yaml", configMap("nested", "  chart: \"{{ .Chart.Name }}\"\n"), 0644)
+		dir.WriteFile("umbrella/values.yaml", []byte("tags:\n  nested: true\n"), 0644)
+		out, err := template(enabled)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring(`chart: "nested"`))
+
+		dir.WriteFile("umbrella/values.yaml", []byte("tags:\n  nested: false\n"), 0644)
+		dir.WriteFile("umbrella/charts/extra/values.yaml", []byte("tags:\n  nested: true\n"), 0644)
+		out, err = template(enabled)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).NotTo(ContainSubstring(`chart: "nested"`))
+	})
+
+	It("fails for missing dependencies", func() {
+		Expect(os.RemoveAll(dir.Join("umbrella/charts/db"))).To(Succeed())
+		_, err := template(enabled)
+		Expect(err).To(MatchError(ContainSubstring("Dependency db of helm chart umbrella not found")))
+	})
+})
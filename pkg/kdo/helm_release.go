@@ -0,0 +1,463 @@
+package kdo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// helmReleaseInfo, helmReleaseChart, helmHook and helmReleaseRecord are the parts of helm v3 releases used by kdo
+type helmReleaseInfo struct {
+	FirstDeployed time.Time `json:"first_deployed,omitempty"`
+	LastDeployed  time.Time `json:"last_deployed,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	Status        string    `json:"status,omitempty"`
+}
+
+type helmReleaseChart struct {
+	Metadata struct {
+		Name       string `json:"name"`
+		Version    string `json:"version"`
+		AppVersion string `json:"appVersion,omitempty"`
+	} `json:"metadata"`
+}
+
+type helmHook struct {
+	Name           string   `json:"name"`
+	Kind           string   `json:"kind"`
+	Manifest       string   `json:"manifest"`
+	Events         []string `json:"events"`
+	Weight         int      `json:"weight"`
+	DeletePolicies []string `json:"delete_policies,omitempty"`
+}
+
+type helmReleaseRecord struct {
+	Name      string                 `json:"name"`
+	Info      helmReleaseInfo        `json:"info"`
+	Chart     helmReleaseChart       `json:"chart"`
+	Config    map[string]interface{} `json:"config,omitempty"`
+	Manifest  string                 `json:"manifest"`
+	Hooks     []helmHook             `json:"hooks,omitempty"`
+	Version   int                    `json:"version"`
+	Namespace string                 `json:"namespace"`
+}
+
+const (
+	helmStatusDeployed   = "deployed"
+	helmStatusSuperseded = "superseded"
+	helmStatusFailed     = "failed"
+
+	helmHookAnnotation             = "helm.sh/hook"
+	helmHookWeightAnnotation       = "helm.sh/hook-weight"
+	helmHookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+)
+
+// helmHookTimeout - the time kdo waits for a hook job or pod to complete
+var helmHookTimeout = 5 * time.Minute
+
+// helmEngine installs, upgrades, rolls back and uninstalls helm releases through the K8s interface
+type helmEngine struct {
+	chart     *chartImpl
+	k         k8s.K8s
+	name      string
+	namespace string
+}
+
+func newHelmEngine(c *chartImpl, k k8s.K8s) *helmEngine {
+	return &helmEngine{chart: c, k: k, name: c.GetName(), namespace: c.namespace}
+}
+
+func (e *helmEngine) options() *k8s.Options {
+	return &k8s.Options{Namespace: e.namespace, Quiet: true}
+}
+
+func (e *helmEngine) secretName(version int) string {
+	return fmt.Sprintf("sh.helm.release.v1.%s.v%d", e.name, version)
+}
+
+// history returns all releases sorted by version
+func (e *helmEngine) history() ([]*helmReleaseRecord, error) {
+	selector := labels.SelectorFromSet(labels.Set{"owner": "helm", "name": e.name})
+	list, err := e.k.List("secrets", e.options(), &k8s.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var items []k8s.Object
+	if list != nil && len(list.Additional["items"]) != 0 {
+		if err := json.Unmarshal(list.Additional["items"], &items); err != nil {
+			return nil, err
+		}
+	}
+	result := make([]*helmReleaseRecord, 0, len(items))
+	for i := range items {
+		data, err := secretData(&items[i])
+		if err != nil {
+			return nil, err
+		}
+		record, err := decodeHelmRelease(data["release"])
+		if err != nil {
+			return nil, fmt.Errorf("Can't decode helm release %s: %s", items[i].MetaData.Name, err.Error())
+		}
+		result = append(result, record)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// store writes a release into a secret like helm does
+func (e *helmEngine) store(record *helmReleaseRecord) error {
+	data, err := encodeHelmRelease(record)
+	if err != nil {
+		return err
+	}
+	secret := &k8s.Object{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		MetaData: k8s.MetaData{
+			Name:      e.secretName(record.Version),
+			Namespace: e.namespace,
+		},
+	}
+	_, err = e.k.CreateOrUpdate(secret, func(obj *k8s.Object) error {
+		updateLabels(obj, map[string]string{
+			"name":    record.Name,
+			"owner":   "helm",
+			"status":  record.Info.Status,
+			"version": strconv.Itoa(record.Version),
+		})
+		if err := setSecretData(obj, map[string][]byte{"release": data}); err != nil {
+			return err
+		}
+		obj.Additional["type"] = json.RawMessage(`"helm.sh/release.v1"`)
+		return nil
+	}, e.options())
+	return err
+}
+
+// encodeHelmRelease returns the base64 encoded, gzipped json of a release
+func encodeHelmRelease(record *helmReleaseRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+func decodeHelmRelease(data []byte) (*helmReleaseRecord, error) {
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	record := &helmReleaseRecord{}
+	return record, json.Unmarshal(b, record)
+}
+
+// deployedRelease returns the last release with status deployed
+func deployedRelease(history []*helmReleaseRecord) *helmReleaseRecord {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Info.Status == helmStatusDeployed {
+			return history[i]
+		}
+	}
+	return nil
+}
+
+func nextVersion(history []*helmReleaseRecord) int {
+	if len(history) == 0 {
+		return 1
+	}
+	return history[len(history)-1].Version + 1
+}
+
+// install renders the templates, runs the install or upgrade hooks and applies the manifest as new revision
+func (e *helmEngine) install(thread *starlark.Thread) error {
+	history, err := e.history()
+	if err != nil {
+		return err
+	}
+	previous := deployedRelease(history)
+	r := &release{Name: e.name, Namespace: e.namespace, Service: "Helm", Revision: nextVersion(history), IsInstall: previous == nil, IsUpgrade: previous != nil}
+	thread.SetLocal(helmReleaseKey, r)
+	defer thread.SetLocal(helmReleaseKey, nil)
+	var objects []*k8s.Object
+	err = e.chart.validate(k8s.Decode(e.chart.template(thread, "", e.k)), e.k)(func(obj *k8s.Object) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.chart.checkPolicies(thread, objects, e.chart.warnings); err != nil {
+		return err
+	}
+	manifest, hooks, err := splitHooks(objects)
+	if err != nil {
+		return err
+	}
+	record := &helmReleaseRecord{
+		Name:      e.name,
+		Namespace: e.namespace,
+		Version:   r.Revision,
+		Manifest:  manifest,
+		Hooks:     hooks,
+		Info:      helmReleaseInfo{FirstDeployed: time.Now(), LastDeployed: time.Now(), Status: helmStatusDeployed, Description: "Install complete"},
+	}
+	if config, ok := toPersistedGo(e.chart.GetValue()).(map[string]interface{}); ok {
+		record.Config = config
+	}
+	record.Chart.Metadata.Name = e.chart.clazz.Name
+	record.Chart.Metadata.Version = e.chart.GetVersionString()
+	record.Chart.Metadata.AppVersion = e.chart.clazz.AppVersion
+	event := "install"
+	if previous != nil {
+		event = "upgrade"
+		record.Info.FirstDeployed = previous.Info.FirstDeployed
+		record.Info.Description = "Upgrade complete"
+	}
+	return e.deploy(record, previous, event)
+}
+
+// deploy runs the pre hooks, applies the manifest of a release, deletes objects only contained in the previous
+// release, runs the post hooks and stores the release
+func (e *helmEngine) deploy(record *helmReleaseRecord, previous *helmReleaseRecord, event string) error {
+	err := e.runHooks(record.Hooks, "pre-"+event)
+	if err == nil {
+		err = e.k.Apply(manifestStream(record.Manifest), &k8s.Options{Namespace: e.namespace, ClusterScoped: true})
+	}
+	if err == nil && previous != nil {
+		err = e.k.Delete(removedObjects(previous.Manifest, record.Manifest, e.namespace), &k8s.Options{Namespace: e.namespace, ClusterScoped: true})
+	}
+	if err == nil {
+		err = e.runHooks(record.Hooks, "post-"+event)
+	}
+	if err != nil {
+		record.Info.Status = helmStatusFailed
+		record.Info.Description = fmt.Sprintf("%s failed: %s", event, err.Error())
+		if storeErr := e.store(record); storeErr != nil {
+			return storeErr
+		}
+		return err
+	}
+	if previous != nil {
+		previous.Info.Status = helmStatusSuperseded
+		if err := e.store(previous); err != nil {
+			return err
+		}
+	}
+	return e.store(record)
+}
+
+// rollback deploys the manifest of a revision or the revision before the deployed one as new revision
+func (e *helmEngine) rollback(revision int) error {
+	history, err := e.history()
+	if err != nil {
+		return err
+	}
+	current := deployedRelease(history)
+	if current == nil {
+		return fmt.Errorf("Release %s isn't deployed in namespace %s", e.name, e.namespace)
+	}
+	if revision == 0 {
+		revision = current.Version - 1
+	}
+	var target *helmReleaseRecord
+	for _, record := range history {
+		if record.Version == revision {
+			target = record
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("Release %s has no revision %d", e.name, revision)
+	}
+	record := *target
+	record.Version = nextVersion(history)
+	record.Info = helmReleaseInfo{
+		FirstDeployed: current.Info.FirstDeployed,
+		LastDeployed:  time.Now(),
+		Status:        helmStatusDeployed,
+		Description:   fmt.Sprintf("Rollback to %d", revision),
+	}
+	return e.deploy(&record, current, "rollback")
+}
+
+// uninstall runs the delete hooks, deletes the objects of the deployed release and removes the release history
+func (e *helmEngine) uninstall() error {
+	history, err := e.history()
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return nil
+	}
+	current := deployedRelease(history)
+	if current == nil {
+		current = history[len(history)-1]
+	}
+	if err := e.runHooks(current.Hooks, "pre-delete"); err != nil {
+		return err
+	}
+	if err := e.k.Delete(manifestStream(current.Manifest), &k8s.Options{Namespace: e.namespace, ClusterScoped: true}); err != nil {
+		return err
+	}
+	if err := e.runHooks(current.Hooks, "post-delete"); err != nil {
+		return err
+	}
+	for _, record := range history {
+		if err := e.k.DeleteByName("secret", e.secretName(record.Version), &k8s.Options{Namespace: e.namespace, IgnoreNotFound: true, Quiet: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitHooks separates the objects annotated with helm.sh/hook from the manifest
+func splitHooks(objects []*k8s.Object) (string, []helmHook, error) {
+	manifest := &strings.Builder{}
+	hooks := []helmHook{}
+	for _, obj := range objects {
+		data, err := json.Marshal(obj.WithoutSource())
+		if err != nil {
+			return "", nil, err
+		}
+		events, ok := obj.MetaData.Annotations[helmHookAnnotation]
+		if !ok {
+			manifest.WriteString("---\n")
+			manifest.Write(data)
+			manifest.WriteString("\n")
+			continue
+		}
+		hook := helmHook{Name: obj.MetaData.Name, Kind: obj.Kind, Manifest: string(data), Events: splitList(events)}
+		if weight, ok := obj.MetaData.Annotations[helmHookWeightAnnotation]; ok {
+			if hook.Weight, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil {
+				return "", nil, fmt.Errorf("Invalid hook weight %s of %s %s", weight, obj.Kind, obj.MetaData.Name)
+			}
+		}
+		hook.DeletePolicies = splitList(obj.MetaData.Annotations[helmHookDeletePolicyAnnotation])
+		if len(hook.DeletePolicies) == 0 {
+			hook.DeletePolicies = []string{"before-hook-creation"}
+		}
+		hooks = append(hooks, hook)
+	}
+	return manifest.String(), hooks, nil
+}
+
+func splitList(s string) []string {
+	result := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// runHooks applies the hooks of an event ordered by weight and waits for jobs and pods to complete
+func (e *helmEngine) runHooks(hooks []helmHook, event string) error {
+	selected := []helmHook{}
+	for _, hook := range hooks {
+		if containsString(hook.Events, event) {
+			selected = append(selected, hook)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Weight != selected[j].Weight {
+			return selected[i].Weight < selected[j].Weight
+		}
+		return selected[i].Name < selected[j].Name
+	})
+	for _, hook := range selected {
+		deleteOptions := &k8s.Options{Namespace: e.namespace, IgnoreNotFound: true, Quiet: true}
+		if containsString(hook.DeletePolicies, "before-hook-creation") {
+			if err := e.k.DeleteByName(hook.Kind, hook.Name, deleteOptions); err != nil {
+				return err
+			}
+		}
+		err := e.k.Apply(manifestStream(hook.Manifest), &k8s.Options{Namespace: e.namespace})
+		if err == nil {
+			err = e.waitForHook(hook)
+		}
+		if err != nil {
+			if containsString(hook.DeletePolicies, "hook-failed") {
+				_ = e.k.DeleteByName(hook.Kind, hook.Name, deleteOptions)
+			}
+			return fmt.Errorf("Hook %s of %s %s failed: %s", event, hook.Kind, hook.Name, err.Error())
+		}
+		if containsString(hook.DeletePolicies, "hook-succeeded") {
+			if err := e.k.DeleteByName(hook.Kind, hook.Name, deleteOptions); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *helmEngine) waitForHook(hook helmHook) error {
+	options := &k8s.Options{Namespace: e.namespace, Timeout: helmHookTimeout}
+	switch hook.Kind {
+	case "Job":
+		return e.k.Wait("job", hook.Name, "condition=complete", options)
+	case "Pod":
+		return e.k.Wait("pod", hook.Name, "jsonpath={.status.phase}=Succeeded", options)
+	}
+	return nil
+}
+
+func manifestStream(manifest string) k8s.ObjectStream {
+	return k8s.Decode(func(w io.Writer) error {
+		_, err := io.WriteString(w, manifest)
+		return err
+	})
+}
+
+// removedObjects returns the objects of the previous manifest, which aren't contained in the current one
+func removedObjects(previous string, current string, namespace string) k8s.ObjectStream {
+	key := func(obj *k8s.Object) string {
+		ns := obj.MetaData.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		return strings.Join([]string{obj.Kind, ns, obj.MetaData.Name}, "/")
+	}
+	return func(w k8s.ObjectConsumer) error {
+		keep := map[string]bool{}
+		err := manifestStream(current)(func(obj *k8s.Object) error {
+			keep[key(obj)] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return manifestStream(previous).Filter(func(obj *k8s.Object) bool {
+			return !keep[key(obj)]
+		})(w)
+	}
+}
@@ -0,0 +1,155 @@
+package kdo
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+)
+
+// imageMapping relocates images. A trailing * of From matches any remainder, which replaces the * of To.
+type imageMapping struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+type imageMappings []imageMapping
+
+// Image - an image used by a chart and the image it is relocated to
+type Image struct {
+	Name      string
+	Relocated string
+}
+
+// podSpecPaths are the paths of the pod specs in workload resources
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// normalizeImage adds the implicit docker.io registry and library repository to an image reference
+func normalizeImage(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return "docker.io/library/" + image
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return "docker.io/" + image
+	}
+	return image
+}
+
+// relocate returns the image of the longest matching mapping or the image itself
+func (m imageMappings) relocate(image string) string {
+	normalized := normalizeImage(image)
+	var bestMatch *imageMapping
+	remainder := ""
+	for i, mapping := range m {
+		from := mapping.From
+		if strings.HasSuffix(from, "*") {
+			prefix := strings.TrimSuffix(from, "*")
+			if !strings.HasPrefix(image, prefix) && !strings.HasPrefix(normalized, prefix) {
+				continue
+			}
+			if bestMatch == nil || len(from) > len(bestMatch.From) {
+				bestMatch = &m[i]
+				if strings.HasPrefix(image, prefix) {
+					remainder = strings.TrimPrefix(image, prefix)
+				} else {
+					remainder = strings.TrimPrefix(normalized, prefix)
+				}
+			}
+		} else if from == image || from == normalized {
+			return mapping.To
+		}
+	}
+	if bestMatch == nil {
+		return image
+	}
+	return strings.Replace(bestMatch.To, "*", remainder, 1)
+}
+
+// mapImages calls f for all container images of a workload resource and replaces them with the result
+func mapImages(obj *k8s.Object, f func(image string) string) *k8s.Object {
+	path, ok := podSpecPaths[obj.Kind]
+	if !ok || obj.Additional == nil || obj.Additional[path[0]] == nil {
+		return obj
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(obj.Additional[path[0]], &spec); err != nil {
+		return obj
+	}
+	podSpec := spec
+	for _, p := range path[1:] {
+		if podSpec, ok = podSpec[p].(map[string]interface{}); !ok {
+			return obj
+		}
+	}
+	changed := false
+	for _, key := range containerKeys {
+		containers, _ := podSpec[key].([]interface{})
+		for _, container := range containers {
+			c, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if image, ok := c["image"].(string); ok {
+				if relocated := f(image); relocated != image {
+					c["image"] = relocated
+					changed = true
+				}
+			}
+		}
+	}
+	if !changed {
+		return obj
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return obj
+	}
+	obj.Additional[path[0]] = data
+	return obj
+}
+
+func (c *chartImpl) relocateImages(stream k8s.Stream, thread *starlark.Thread) k8s.Stream {
+	if len(c.images) == 0 || thread.Local("skip-image-relocation") != nil {
+		return stream
+	}
+	return k8s.Decode(stream).Map(func(obj *k8s.Object) *k8s.Object {
+		return mapImages(obj, c.images.relocate)
+	}).Encode()
+}
+
+// Images returns all images used by the templates of a chart sorted by name
+func Images(thread *starlark.Thread, chart Chart, k k8s.K8s) ([]Image, error) {
+	thread.SetLocal("skip-image-relocation", true)
+	defer thread.SetLocal("skip-image-relocation", nil)
+	var mappings imageMappings
+	if c, ok := chart.(*chartImpl); ok {
+		mappings = c.images
+	}
+	images := map[string]string{}
+	err := k8s.Decode(chart.Template(thread, k))(func(obj *k8s.Object) error {
+		mapImages(obj, func(image string) string {
+			images[image] = mappings.relocate(image)
+			return image
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Image, 0, len(images))
+	for _, name := range sortedStrings(images) {
+		result = append(result, Image{Name: name, Relocated: images[name]})
+	}
+	return result, nil
+}
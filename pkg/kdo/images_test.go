@@ -0,0 +1,101 @@
+package kdo
+
+import (
+	"bytes"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Images", func() {
+	var dir TestDir
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("chart/templates", 0755)
+		dir.WriteFile("chart/Chart.yaml", []byte("name: chart\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("chart/templates/workloads.yaml", []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.32
+      containers:
+      - name: web
+        image: nginx:1.19
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: backup
+            image: quay.io/backup/tool:2.0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  image: nginx:1.19
+`), 0644)
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("relocates images", func() {
+		mappings := imageMappings{
+			{From: "docker.io/*", To: "registry.internal/mirror/*"},
+			{From: "docker.io/library/busybox:1.32", To: "registry.internal/busybox:stable"},
+			{From: "gcr.io/project/*", To: "registry.internal/gcr/*"},
+		}
+		Expect(mappings.relocate("nginx:1.19")).To(Equal("registry.internal/mirror/library/nginx:1.19"))
+		Expect(mappings.relocate("bitnami/redis:6")).To(Equal("registry.internal/mirror/bitnami/redis:6"))
+		Expect(mappings.relocate("busybox:1.32")).To(Equal("registry.internal/busybox:stable"))
+		Expect(mappings.relocate("gcr.io/project/app@sha256:abc")).To(Equal("registry.internal/gcr/app@sha256:abc"))
+		Expect(mappings.relocate("quay.io/backup/tool:2.0")).To(Equal("quay.io/backup/tool:2.0"))
+		Expect(mappings.relocate("localhost:5000/app")).To(Equal("localhost:5000/app"))
+	})
+
+	It("rewrites images of templates", func() {
+		repo, err := NewRepo(WithImageMapping("docker.io/*", "registry.internal/mirror/*"))
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(c.Template(thread, k8s.NewK8sInMemoryEmpty())(buf)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring(`"image":"registry.internal/mirror/library/nginx:1.19"`))
+		Expect(buf.String()).To(ContainSubstring(`"image":"registry.internal/mirror/library/busybox:1.32"`))
+		Expect(buf.String()).To(ContainSubstring(`"image":"quay.io/backup/tool:2.0"`))
+		Expect(buf.String()).To(ContainSubstring(`"image":"nginx:1.19"`))
+	})
+
+	It("lists the images of a chart", func() {
+		repo, err := NewRepo(WithImageMapping("docker.io/*", "registry.internal/mirror/*"))
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		images, err := Images(thread, c, k8s.NewK8sInMemoryEmpty())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(images).To(Equal([]Image{
+			{Name: "busybox:1.32", Relocated: "registry.internal/mirror/library/busybox:1.32"},
+			{Name: "nginx:1.19", Relocated: "registry.internal/mirror/library/nginx:1.19"},
+			{Name: "quay.io/backup/tool:2.0", Relocated: "quay.io/backup/tool:2.0"},
+		}))
+	})
+})
@@ -0,0 +1,183 @@
+package kdo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/renderer"
+)
+
+// IndexFile is the name of the index of a kdo repository
+const IndexFile = "index.yaml"
+
+// IndexDir writes the index of all packaged charts in a directory. The urls of the entries are relative to baseURL.
+func IndexDir(thread *starlark.Thread, repo Repo, dir string, baseURL string) (*renderer.Index, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	index := &renderer.Index{APIVersion: "v1", Entries: map[string][]renderer.Entry{}}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".tgz") {
+			continue
+		}
+		entry, err := indexEntry(thread, repo, path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("Can't index %s: %s", file.Name(), err.Error())
+		}
+		entry.URLs = []string{file.Name()}
+		if baseURL != "" {
+			entry.URLs[0] = strings.TrimSuffix(baseURL, "/") + "/" + file.Name()
+		}
+		index.Entries[entry.Name] = append(index.Entries[entry.Name], *entry)
+	}
+	for _, entries := range index.Entries {
+		sortEntries(entries)
+	}
+	return index, writeYamlFile(path.Join(dir, IndexFile), index)
+}
+
+func indexEntry(thread *starlark.Thread, repo Repo, filename string) (*renderer.Entry, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "kdo-index")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	c, err := newChartFromReader(thread, repo, dir, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if c.GetVersion() == nil {
+		return nil, fmt.Errorf("chart %s has no version", c.clazz.Name)
+	}
+	sum := sha256.Sum256(data)
+	return &renderer.Entry{
+		Name:        c.clazz.Name,
+		Genus:       c.GetGenus(),
+		Version:     c.GetVersion().String(),
+		Description: c.clazz.Description,
+		Digest:      hex.EncodeToString(sum[:]),
+		Deprecated:  c.clazz.Deprecated,
+	}, nil
+}
+
+// sortEntries sorts index entries starting with the highest version
+func sortEntries(entries []renderer.Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		vi, erri := semver.NewVersion(entries[i].Version)
+		vj, errj := semver.NewVersion(entries[j].Version)
+		if erri != nil || errj != nil {
+			return errj != nil && erri == nil
+		}
+		return vi.GreaterThan(vj)
+	})
+}
+
+// catalogEntries returns the index entries of a chart in a catalog. The result is empty, if the catalog has no index.
+func catalogEntries(index helmIndex, cache OpenDirCache, catalog string, name string) []renderer.Entry {
+	var idx *renderer.Index
+	base := strings.TrimSuffix(catalog, "/")
+	if (strings.HasPrefix(catalog, "https://") || strings.HasPrefix(catalog, "http://")) && !strings.Contains(catalog, "#") {
+		var err error
+		if idx, err = index(base + "/" + IndexFile); err != nil {
+			return nil
+		}
+	} else {
+		dir, err := cache(catalog)
+		if err != nil {
+			return nil
+		}
+		idx = &renderer.Index{}
+		if err := readYamlFile(path.Join(dir, IndexFile), idx); err != nil {
+			return nil
+		}
+		base = dir
+	}
+	entries := make([]renderer.Entry, 0, len(idx.Entries[name]))
+	for _, entry := range idx.Entries[name] {
+		urls := make([]string, 0, len(entry.URLs))
+		for _, u := range entry.URLs {
+			if parsed, err := url.Parse(u); err == nil && parsed.Scheme == "" && !path.IsAbs(u) {
+				u = base + "/" + u
+			}
+			urls = append(urls, u)
+		}
+		entry.URLs = urls
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// latestEntry returns the entry with the highest version, which isn't deprecated
+func latestEntry(entries []renderer.Entry) *renderer.Entry {
+	for i, entry := range entries {
+		if !entry.Deprecated && len(entry.URLs) > 0 {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// pushHTTP uploads a packaged chart using PUT. Urls ending with a slash are completed with <name>-<version>.tgz.
+// Missing WebDAV collections are created.
+func (r *repoImpl) pushHTTP(chart Chart, uri string) (string, error) {
+	buffer := &bytes.Buffer{}
+	if err := chart.Package(buffer, false); err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(uri, "/") {
+		if chart.GetVersion() == nil {
+			return "", fmt.Errorf("Chart %s has no version", chart.GetName())
+		}
+		uri += path.Base(chart.GetName()) + "-" + chart.GetVersion().String() + ".tgz"
+	}
+	res, err := r.sendHTTP(http.MethodPut, uri, buffer.Bytes())
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode == http.StatusConflict {
+		collection := uri[:strings.LastIndex(uri, "/")+1]
+		if res, err = r.sendHTTP("MKCOL", collection, nil); err != nil {
+			return "", err
+		}
+		if res.StatusCode != http.StatusCreated {
+			return "", fmt.Errorf("Error creating collection %s: status=%d", collection, res.StatusCode)
+		}
+		if res, err = r.sendHTTP(http.MethodPut, uri, buffer.Bytes()); err != nil {
+			return "", err
+		}
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusNoContent {
+		return "", fmt.Errorf("Error uploading %s: status=%d", uri, res.StatusCode)
+	}
+	return uri, nil
+}
+
+func (r *repoImpl) sendHTTP(method string, uri string, body []byte) (*http.Response, error) {
+	request, err := http.NewRequest(method, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	res, err := doAuthorizedRequest(r.client, request, r.credentials)
+	if err != nil {
+		return nil, fmt.Errorf("Error uploading %s: %v", uri, err)
+	}
+	res.Body.Close()
+	return res, nil
+}
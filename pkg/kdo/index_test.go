@@ -0,0 +1,138 @@
+package kdo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Index", func() {
+	var dir TestDir
+	var repo Repo
+	thread := &starlark.Thread{Name: "main"}
+
+	packageChart := func(version string, chartYaml string) {
+		dir.MkdirAll("src/"+version, 0755)
+		dir.WriteFile("src/"+version+"/Chart.yaml", []byte("name: base\nversion: "+version+"\n"+chartYaml), 0644)
+		c, err := repo.Get(thread, dir.Join("src", version))
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(c.Package(buf, false)).To(Succeed())
+		dir.WriteFile("catalog/base-"+version+".tgz", buf.Bytes(), 0644)
+	}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		repo, _ = NewRepo()
+		dir.MkdirAll("catalog", 0755)
+		packageChart("1.0.0", "")
+		packageChart("2.0.0", "description: the base chart\n")
+		packageChart("2.1.0", "deprecated: true\n")
+		_, err := IndexDir(thread, repo, dir.Join("catalog"), "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("indexes packaged charts", func() {
+		index, err := IndexDir(thread, repo, dir.Join("catalog"), "https://charts.example.com/")
+		Expect(err).NotTo(HaveOccurred())
+		entries := index.Entries["base"]
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Version).To(Equal("2.1.0"))
+		Expect(entries[0].Deprecated).To(BeTrue())
+		Expect(entries[1].Description).To(Equal("the base chart"))
+		Expect(entries[1].Genus).To(Equal("base"))
+		Expect(entries[1].URLs).To(ConsistOf("https://charts.example.com/base-2.0.0.tgz"))
+		Expect(entries[1].Digest).To(HaveLen(64))
+		data, err := ioutil.ReadFile(dir.Join("catalog", IndexFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("apiVersion: v1"))
+	})
+
+	It("selects versions from the index of a catalog", func() {
+		repo, err := NewRepo(WithCatalog(dir.Join("catalog")))
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, "catalog:base")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.GetVersion().String()).To(Equal("2.0.0"))
+
+		dir.MkdirAll("top", 0755)
+		dir.WriteFile("top/Chart.yaml", []byte("name: top\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("top/Chart.star", []byte("def init(self):\n  self.base = depends_on(\"catalog:base\", \"< 2.0\")\n"), 0644)
+		top, err := repo.Get(thread, dir.Join("top"))
+		Expect(err).NotTo(HaveOccurred())
+		lock, err := repo.Resolve(thread, top, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lock.Dependencies).To(HaveLen(1))
+		Expect(lock.Dependencies[0].Version).To(Equal("1.0.0"))
+		Expect(lock.Dependencies[0].URL).To(Equal(dir.Join("catalog") + "/base-1.0.0.tgz"))
+	})
+
+	It("selects versions from the index of a http catalog", func() {
+		server := httptest.NewServer(http.FileServer(http.Dir(dir.Join("catalog"))))
+		defer server.Close()
+		repo, err := NewRepo(WithCatalog(server.URL))
+		Expect(err).NotTo(HaveOccurred())
+		versions, err := repo.Versions(thread, "catalog:base", "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(2))
+		Expect(versions[0].URL).To(Equal(server.URL + "/base-2.0.0.tgz"))
+		c, err := repo.Get(thread, "catalog:base")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.GetVersion().String()).To(Equal("2.0.0"))
+	})
+
+	It("pushes charts to WebDAV endpoints", func() {
+		files := map[string][]byte{}
+		collections := map[string]bool{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, _ := r.BasicAuth()
+			if user != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch r.Method {
+			case "MKCOL":
+				collections[r.URL.Path] = true
+				w.WriteHeader(http.StatusCreated)
+			case http.MethodPut:
+				if !collections[r.URL.Path[:strings.LastIndex(r.URL.Path, "/")+1]] {
+					w.WriteHeader(http.StatusConflict)
+					return
+				}
+				files[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+				w.WriteHeader(http.StatusCreated)
+			case http.MethodGet:
+				data, ok := files[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write(data)
+			}
+		}))
+		defer server.Close()
+		repo, err := NewRepo(WithBasicAuth(server.URL, "user", "secret"))
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("src", "1.0.0"))
+		Expect(err).NotTo(HaveOccurred())
+		url, err := repo.Push(c, server.URL+"/charts/")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal(server.URL + "/charts/base-1.0.0.tgz"))
+		pushed, err := repo.Get(thread, url)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pushed.GetVersion().String()).To(Equal("1.0.0"))
+
+		_, err = repo.Push(c, "ftp://example.com/charts/")
+		Expect(err).To(MatchError(ContainSubstring("Only oci://, https:// and http:// urls are supported")))
+	})
+})
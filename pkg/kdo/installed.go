@@ -0,0 +1,201 @@
+package kdo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const usedByPrefix = "kdo-usedby-"
+
+// dependencyAnnotation marks charts, which have been installed as dependency of another chart. It's removed, if the
+// chart is applied directly.
+const dependencyAnnotation = "kdo-dependency"
+
+// referenceSeparator separates namespace and genus in references. It can't be part of a namespace or genus.
+const referenceSeparator = "_"
+
+// unknownConstraint is the value of used-by annotations written by older versions of kdo
+const unknownConstraint = "True"
+
+// InstalledChart - a chart installed in the cluster and the charts depending on it
+type InstalledChart struct {
+	Namespace string
+	Genus     string
+	Version   string
+	// Dependency is true, if the chart has been installed as dependency of another chart
+	Dependency bool
+	// UsedBy maps the references of the charts depending on this chart to their version constraints
+	UsedBy map[string]string
+}
+
+// Reference returns the reference used in the used-by annotations of the dependencies of this chart
+func (c *InstalledChart) Reference() string {
+	return chartReference(c.Namespace, c.Genus)
+}
+
+func chartReference(namespace string, genus string) string {
+	return namespace + referenceSeparator + genus
+}
+
+// legacyReference returns the reference written by older versions of kdo, which is ambiguous for names with hyphens
+func legacyReference(reference string) string {
+	return strings.Replace(reference, referenceSeparator, "-", 1)
+}
+
+// references returns the keys of the used-by annotations, which reference the chart
+func (c *InstalledChart) references() []string {
+	reference := k8s.FixLabelValue(c.Reference())
+	return []string{reference, legacyReference(reference)}
+}
+
+// ListInstalled lists the installed charts without loading them
+func ListInstalled(k k8s.K8s, listOptions *RepoListOptions) ([]*InstalledChart, error) {
+	items, err := listItems(k, "configmaps", listOptions)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*InstalledChart, 0, len(items))
+	for _, item := range items {
+		result = append(result, installedChart(&item))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Genus < result[j].Genus
+	})
+	return result, nil
+}
+
+func installedChart(configMap *k8s.Object) *InstalledChart {
+	chart := &InstalledChart{
+		Namespace: configMap.MetaData.Namespace,
+		Genus:     configMap.MetaData.Labels["kdo.sap.github.com/genus"],
+		Version:   configMap.MetaData.Labels["kdo.sap.github.com/version"],
+		UsedBy:    map[string]string{},
+	}
+	_, chart.Dependency = configMap.MetaData.Annotations[dependencyAnnotation]
+	for key, value := range configMap.MetaData.Annotations {
+		if strings.HasPrefix(key, usedByPrefix) {
+			chart.UsedBy[strings.TrimPrefix(key, usedByPrefix)] = value
+		}
+	}
+	return chart
+}
+
+// usedBy returns true, if the chart is used by the given chart
+func (c *InstalledChart) usedBy(chart *InstalledChart) bool {
+	_, ok := c.usedByConstraint(chart)
+	return ok
+}
+
+// usedByConstraint returns the constraint of the given chart using the chart
+func (c *InstalledChart) usedByConstraint(chart *InstalledChart) (string, bool) {
+	for _, reference := range chart.references() {
+		if constraint, ok := c.UsedBy[reference]; ok {
+			return constraint, true
+		}
+	}
+	return "", false
+}
+
+// referrers returns the installed charts using the chart
+func referrers(charts []*InstalledChart, chart *InstalledChart) []*InstalledChart {
+	result := make([]*InstalledChart, 0)
+	for _, c := range charts {
+		if chart.usedBy(c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// WriteDependencyTree writes the installed charts per namespace. Dependencies are listed below the charts using them.
+func WriteDependencyTree(writer io.Writer, charts []*InstalledChart) error {
+	namespace := ""
+	printed := false
+	for _, chart := range charts {
+		if len(referrers(charts, chart)) != 0 {
+			continue
+		}
+		if !printed || chart.Namespace != namespace {
+			printed = true
+			namespace = chart.Namespace
+			if _, err := fmt.Fprintln(writer, namespace); err != nil {
+				return err
+			}
+		}
+		if err := writeDependencyTree(writer, charts, chart, "", "", map[*InstalledChart]bool{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDependencyTree(writer io.Writer, charts []*InstalledChart, chart *InstalledChart, constraint string, indent string, visited map[*InstalledChart]bool) error {
+	name := chart.Genus
+	if len(indent) != 0 {
+		name = chart.Namespace + "/" + chart.Genus
+	}
+	line := fmt.Sprintf("%s└── %s %s", indent, name, chart.Version)
+	if constraint != "" && constraint != unknownConstraint {
+		line += fmt.Sprintf(" (%s)", constraint)
+	}
+	if _, err := fmt.Fprintln(writer, line); err != nil {
+		return err
+	}
+	if visited[chart] {
+		return nil
+	}
+	visited[chart] = true
+	defer delete(visited, chart)
+	for _, dep := range charts {
+		if constraint, ok := dep.usedByConstraint(chart); ok {
+			if err := writeDependencyTree(writer, charts, dep, constraint, indent+"    ", visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addUsedBy records the reference and the constraint of a chart depending on this chart
+func (c *chartImpl) addUsedBy(reference string, constraint string, k k8s.K8s) (int, error) {
+	value, err := json.Marshal(constraint)
+	if err != nil {
+		return 0, err
+	}
+	patch := fmt.Sprintf(`[{"op": "add", "path": "/metadata/annotations/%s", "value" : %s}]`, usedByAnnotation(reference), value)
+	obj, err := k.Patch("configmap", c.objName(), types.JSONPatchType, patch, &k8s.Options{Namespace: c.namespace})
+	if err != nil {
+		return 0, fmt.Errorf("can't add reference to configmap %s in namespace %s: %v %v. error during application of patch `%s`", c.objName(), c.namespace, err, obj, patch)
+	}
+	return remainingReferences(obj), nil
+}
+
+// upgradeBlockers returns the references of all other charts using the installed chart, which don't accept the version
+func upgradeBlockers(installed *InstalledChart, reference string, version *semver.Version) []string {
+	blockers := make([]string, 0)
+	for ref, value := range installed.UsedBy {
+		if ref == k8s.FixLabelValue(reference) || ref == legacyReference(k8s.FixLabelValue(reference)) {
+			continue
+		}
+		if value == unknownConstraint {
+			blockers = append(blockers, fmt.Sprintf("%s (constraint unknown, apply it again)", ref))
+			continue
+		}
+		constraint, err := semver.NewConstraint(value)
+		if err != nil || !constraint.Check(version) {
+			blockers = append(blockers, fmt.Sprintf("%s (%s)", ref, value))
+		}
+	}
+	sort.Strings(blockers)
+	return blockers
+}
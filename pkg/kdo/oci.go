@@ -0,0 +1,486 @@
+package kdo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// ChartConfigMediaType - media type of the config of kdo charts stored in OCI registries
+	ChartConfigMediaType = "application/vnd.sap.kdo.chart.config.v1+json"
+	// ChartLayerMediaType - media type of the packaged kdo chart stored in OCI registries
+	ChartLayerMediaType = "application/vnd.sap.kdo.chart.content.v1.tar+gzip"
+)
+
+var ociURL = regexp.MustCompile(`^oci://([^/]+)/([^:@]+)(?::([^@]+))?(?:@(sha256:[0-9a-f]{64}))?$`)
+
+// ociReference - reference to an artifact in an OCI registry, e.g. oci://registry/repo:tag@sha256:...
+type ociReference struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+func parseOCIReference(uri string) (*ociReference, error) {
+	match := ociURL.FindStringSubmatch(uri)
+	if match == nil {
+		return nil, fmt.Errorf("Invalid OCI reference %s. Use oci://registry/repository:tag or oci://registry/repository@sha256:digest", uri)
+	}
+	return &ociReference{registry: match[1], repository: match[2], tag: match[3], digest: match[4]}, nil
+}
+
+// reference returns the digest, if the reference is pinned. Otherwise, it returns the tag.
+func (r *ociReference) reference() string {
+	if r.digest != "" {
+		return r.digest
+	}
+	if r.tag == "" {
+		return "latest"
+	}
+	return r.tag
+}
+
+func (r *ociReference) url(kind string, reference string) string {
+	scheme := "https"
+	host := r.registry
+	if h, _, err := net.SplitHostPort(r.registry); err == nil {
+		host = h
+	}
+	if host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, r.registry, r.repository, kind, reference)
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ociClient - minimal client for the OCI distribution API
+type ociClient struct {
+	client      *http.Client
+	credentials *credentials
+	mutex       sync.Mutex
+	tokens      map[string]string
+}
+
+func newOCIClient(client *http.Client, credentials *credentials) *ociClient {
+	return &ociClient{client: client, credentials: credentials, tokens: map[string]string{}}
+}
+
+// registryCredentials returns the credentials of the registry from the kdo config or from the docker config.json.
+// A token is used as password.
+func (c *ociClient) registryCredentials(registry string) (*Credentials, error) {
+	creds, err := c.credentials.lookup("oci://" + registry + "/")
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		result := *creds
+		if result.Token != "" {
+			result.Username, result.Password = "", result.Token
+		}
+		if result.Username != "" || result.Password != "" || result.Bearer != "" || len(result.Headers) != 0 {
+			return &result, nil
+		}
+	}
+	if username, password, ok := dockerCredentials(registry); ok {
+		return &Credentials{Username: username, Password: password}, nil
+	}
+	return &Credentials{}, nil
+}
+
+func (c *Credentials) hasBasicAuth() bool {
+	return c.Username != "" || c.Password != ""
+}
+
+// dockerCredentials reads credentials from $DOCKER_CONFIG/config.json or ~/.docker/config.json
+func dockerCredentials(registry string) (string, string, bool) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		dir = path.Join(home, ".docker")
+	}
+	data, err := ioutil.ReadFile(path.Join(dir, "config.json"))
+	if err != nil {
+		return "", "", false
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", "", false
+	}
+	for key, auth := range config.Auths {
+		host := key
+		if u, err := url.Parse(key); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if host != registry {
+			continue
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return "", "", false
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) == 2 {
+				return parts[0], parts[1], true
+			}
+		}
+		return auth.Username, auth.Password, auth.Username != ""
+	}
+	return "", "", false
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// token requests a bearer token for the challenge returned by the registry
+func (c *ociClient) token(registry string, challenge string, creds *Credentials) (string, error) {
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("Invalid authentication challenge from %s: %s", registry, challenge)
+	}
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	u.RawQuery = query.Encode()
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if creds.hasBasicAuth() {
+		request.SetBasicAuth(creds.Username, creds.Password)
+	}
+	res, err := doRequest(c.client, request)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Error fetching token from %s: status=%d", u.Host, res.StatusCode)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// authorization returns the cached authorization header of a registry
+func (c *ociClient) authorization(registry string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	authorization, ok := c.tokens[registry]
+	return authorization, ok
+}
+
+func (c *ociClient) setAuthorization(registry string, authorization string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokens[registry] = authorization
+}
+
+// do sends a request and authenticates, if the registry asks for it. Configured bearer tokens are sent without
+// requesting a token from the registry. If the authorization is rejected, cached credentials are refreshed.
+func (c *ociClient) do(registry string, method string, uri string, header http.Header, body []byte) (*http.Response, error) {
+	creds, err := c.registryCredentials(registry)
+	if err != nil {
+		return nil, err
+	}
+	send := func() (*http.Response, error) {
+		request, err := http.NewRequest(method, uri, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		for key, value := range creds.Headers {
+			request.Header.Set(key, value)
+		}
+		if authorization, ok := c.authorization(registry); ok {
+			request.Header.Set("Authorization", authorization)
+		}
+		return doRequest(c.client, request)
+	}
+	res, err := send()
+	for attempt := 0; err == nil && res.StatusCode == http.StatusUnauthorized && attempt < 2; attempt++ {
+		res.Body.Close()
+		if _, rejected := c.authorization(registry); rejected && c.credentials.invalidate("oci://"+registry+"/") {
+			if creds, err = c.registryCredentials(registry); err != nil {
+				return nil, err
+			}
+		}
+		challenge := res.Header.Get("WWW-Authenticate")
+		switch {
+		case creds.Bearer != "":
+			c.setAuthorization(registry, "Bearer "+creds.Bearer)
+		case strings.HasPrefix(challenge, "Bearer "):
+			token, err := c.token(registry, challenge, creds)
+			if err != nil {
+				return nil, err
+			}
+			c.setAuthorization(registry, "Bearer "+token)
+		case strings.HasPrefix(challenge, "Basic "):
+			if !creds.hasBasicAuth() {
+				return nil, fmt.Errorf("No credentials found for registry %s", registry)
+			}
+			c.setAuthorization(registry, "Basic "+base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)))
+		default:
+			return nil, fmt.Errorf("Unauthorized to access %s", uri)
+		}
+		res, err = send()
+	}
+	return res, err
+}
+
+func (c *ociClient) manifest(ref *ociReference) (*ociManifest, string, error) {
+	uri := ref.url("manifests", ref.reference())
+	res, err := c.do(ref.registry, http.MethodGet, uri, http.Header{"Accept": []string{ociManifestMediaType}}, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error fetching %s: %v", uri, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("Error fetching %s: status=%d", uri, res.StatusCode)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	digest := sha256Digest(data)
+	if ref.digest != "" && ref.digest != digest {
+		return nil, "", fmt.Errorf("Digest of %s doesn't match: expected %s, got %s", uri, ref.digest, digest)
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, "", err
+	}
+	return &manifest, digest, nil
+}
+
+func (c *ociClient) blob(ref *ociReference, descriptor ociDescriptor) ([]byte, error) {
+	uri := ref.url("blobs", descriptor.Digest)
+	res, err := c.do(ref.registry, http.MethodGet, uri, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching %s: %v", uri, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching %s: status=%d", uri, res.StatusCode)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if digest := sha256Digest(data); digest != descriptor.Digest {
+		return nil, fmt.Errorf("Digest of %s doesn't match: expected %s, got %s", uri, descriptor.Digest, digest)
+	}
+	return data, nil
+}
+
+// load extracts the chart layer of an OCI artifact. The digest of the manifest is used as etag.
+func (c *ociClient) load(uri string, targetDir func() (string, error), etagOld string) (string, error) {
+	ref, err := parseOCIReference(uri)
+	if err != nil {
+		return "", err
+	}
+	if ref.digest != "" && ref.digest == etagOld {
+		return etagOld, nil
+	}
+	manifest, digest, err := c.manifest(ref)
+	if err != nil {
+		return "", err
+	}
+	if digest == etagOld {
+		return etagOld, nil
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ChartLayerMediaType {
+			continue
+		}
+		data, err := c.blob(ref, layer)
+		if err != nil {
+			return "", err
+		}
+		dir, err := targetDir()
+		if err != nil {
+			return "", err
+		}
+		if err := extractArchive(bytes.NewReader(data), dir); err != nil {
+			return "", err
+		}
+		return digest, nil
+	}
+	return "", fmt.Errorf("%s doesn't contain a layer of type %s", uri, ChartLayerMediaType)
+}
+
+func (c *ociClient) uploadBlob(ref *ociReference, data []byte) (ociDescriptor, error) {
+	descriptor := ociDescriptor{Digest: sha256Digest(data), Size: int64(len(data))}
+	res, err := c.do(ref.registry, http.MethodHead, ref.url("blobs", descriptor.Digest), nil, nil)
+	if err != nil {
+		return descriptor, err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return descriptor, nil
+	}
+	res, err = c.do(ref.registry, http.MethodPost, ref.url("blobs", "uploads/"), nil, nil)
+	if err != nil {
+		return descriptor, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return descriptor, fmt.Errorf("Error starting upload to %s: status=%d", ref.registry, res.StatusCode)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return descriptor, err
+	}
+	location = res.Request.URL.ResolveReference(location)
+	query := location.Query()
+	query.Set("digest", descriptor.Digest)
+	location.RawQuery = query.Encode()
+	res, err = c.do(ref.registry, http.MethodPut, location.String(), http.Header{"Content-Type": []string{"application/octet-stream"}}, data)
+	if err != nil {
+		return descriptor, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return descriptor, fmt.Errorf("Error uploading blob to %s: status=%d", ref.registry, res.StatusCode)
+	}
+	return descriptor, nil
+}
+
+// push uploads a packaged chart and returns the digest of the manifest
+func (c *ociClient) push(ref *ociReference, config []byte, chart []byte) (string, error) {
+	configDescriptor, err := c.uploadBlob(ref, config)
+	if err != nil {
+		return "", err
+	}
+	configDescriptor.MediaType = ChartConfigMediaType
+	layerDescriptor, err := c.uploadBlob(ref, chart)
+	if err != nil {
+		return "", err
+	}
+	layerDescriptor.MediaType = ChartLayerMediaType
+	manifest, err := json.Marshal(&ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        configDescriptor,
+		Layers:        []ociDescriptor{layerDescriptor},
+	})
+	if err != nil {
+		return "", err
+	}
+	uri := ref.url("manifests", ref.reference())
+	res, err := c.do(ref.registry, http.MethodPut, uri, http.Header{"Content-Type": []string{ociManifestMediaType}}, manifest)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return "", fmt.Errorf("Error pushing %s: status=%d %s", uri, res.StatusCode, body)
+	}
+	return sha256Digest(manifest), nil
+}
+
+func openOCI(dirCache *DirCache, client *ociClient, verifier *verifier, cache OpenDirCache) OpenDirCache {
+	ociCache := dirCache.WrapRemoteDir(client.load)
+	return func(uri string) (string, error) {
+		if strings.HasPrefix(uri, "oci://") {
+			if err := verifier.checkUnsigned(uri); err != nil {
+				return "", err
+			}
+			return ociCache(uri)
+		}
+		return cache(uri)
+	}
+}
+
+// pushOCI uploads a chart to an OCI registry and returns the pinned reference
+func (r *repoImpl) pushOCI(chart Chart, uri string) (string, error) {
+	ref, err := parseOCIReference(uri)
+	if err != nil {
+		return "", err
+	}
+	if ref.digest != "" {
+		return "", fmt.Errorf("Can't push to a digest %s. Use a tag instead", uri)
+	}
+	buffer := &bytes.Buffer{}
+	if err := chart.Package(buffer, false); err != nil {
+		return "", err
+	}
+	version := ""
+	if chart.GetVersion() != nil {
+		version = chart.GetVersion().String()
+	}
+	if ref.tag == "" {
+		ref.tag = version
+	}
+	config, err := json.Marshal(map[string]string{"name": chart.GetName(), "version": version})
+	if err != nil {
+		return "", err
+	}
+	digest, err := r.oci.push(ref, config, buffer.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("oci://%s/%s:%s@%s", ref.registry, ref.repository, ref.reference(), digest), nil
+}
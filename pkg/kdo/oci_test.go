@@ -0,0 +1,216 @@
+package kdo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var registryPath = regexp.MustCompile(`^/v2/(.+)/(blobs|manifests)/(.+)$`)
+
+// newTestRegistry returns a minimal OCI registry with token authentication
+func newTestRegistry(username string, password string) *httptest.Server {
+	blobs := map[string][]byte{}
+	manifests := map[string][]byte{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != username || pass != password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token": "test-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test",scope="repository:charts:pull,push"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.HasSuffix(r.URL.Path, "/blobs/uploads/") {
+			w.Header().Set("Location", "/upload/1")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if r.URL.Path == "/upload/1" {
+			blobs[r.URL.Query().Get("digest")] = body
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		match := registryPath.FindStringSubmatch(r.URL.Path)
+		if match == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		store := blobs
+		if match[2] == "manifests" {
+			store = manifests
+		}
+		switch r.Method {
+		case http.MethodPut:
+			store[match[3]] = body
+			store[sha256Digest(body)] = body
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet, http.MethodHead:
+			data, ok := store[match[3]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	return server
+}
+
+var _ = Describe("OCI", func() {
+	var dir TestDir
+	var server *httptest.Server
+	var registry string
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("chart", 0755)
+		dir.WriteFile("chart/Chart.yaml", []byte("name: chart\nversion: 1.2.3\n"), 0644)
+		dir.WriteFile("chart/Chart.star", []byte("def init(self):\n  self.port = property(default = 8080)\n"), 0644)
+		server = newTestRegistry("user", "secret")
+		registry = strings.TrimPrefix(server.URL, "http://")
+	})
+	AfterEach(func() {
+		server.Close()
+		dir.Remove()
+	})
+
+	It("parses references", func() {
+		ref, err := parseOCIReference("oci://localhost:5000/charts/base:1.0.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref).To(Equal(&ociReference{registry: "localhost:5000", repository: "charts/base", tag: "1.0.0"}))
+		Expect(ref.url("manifests", ref.reference())).To(Equal("http://localhost:5000/v2/charts/base/manifests/1.0.0"))
+		ref, err = parseOCIReference("oci://example.com/charts/base@sha256:" + strings.Repeat("a", 64))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.reference()).To(Equal("sha256:" + strings.Repeat("a", 64)))
+		Expect(ref.url("blobs", "x")).To(HavePrefix("https://example.com/v2/charts/base/"))
+		_, err = parseOCIReference("oci://example.com")
+		Expect(err).To(HaveOccurred())
+		gv := NewGenusAndVersion("oci://example.com/charts/base:1.0.0")
+		Expect(gv.version.String()).To(Equal("1.0.0"))
+	})
+
+	It("pushes and pulls charts using credentials of the docker config", func() {
+		auth := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+		dir.MkdirAll("docker", 0755)
+		dir.WriteFile("docker/config.json", []byte(`{"auths": {"`+registry+`": {"auth": "`+auth+`"}}}`), 0644)
+		os.Setenv("DOCKER_CONFIG", dir.Join("docker"))
+		defer os.Unsetenv("DOCKER_CONFIG")
+
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		pinned, err := repo.Push(c, "oci://"+registry+"/charts/chart")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pinned).To(MatchRegexp(`^oci://` + regexp.QuoteMeta(registry) + `/charts/chart:1.2.3@sha256:[0-9a-f]{64}$`))
+
+		for _, url := range []string{"oci://" + registry + "/charts/chart:1.2.3", pinned} {
+			pulled, err := repo.Get(thread, url)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pulled.GetVersion().String()).To(Equal("1.2.3"))
+			Expect(pulled.Schema()["properties"]).To(HaveKey("port"))
+		}
+
+		_, err = repo.Get(thread, "oci://"+registry+"/charts/chart:1.2.3@sha256:"+strings.Repeat("0", 64))
+		Expect(err).To(MatchError(ContainSubstring("status=404")))
+	})
+
+	It("pulls concurrently", func() {
+		auth := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+		dir.MkdirAll("docker", 0755)
+		dir.WriteFile("docker/config.json", []byte(`{"auths": {"`+registry+`": {"auth": "`+auth+`"}}}`), 0644)
+		os.Setenv("DOCKER_CONFIG", dir.Join("docker"))
+		defer os.Unsetenv("DOCKER_CONFIG")
+
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Push(c, "oci://"+registry+"/charts/chart")
+		Expect(err).NotTo(HaveOccurred())
+
+		client := newOCIClient(&http.Client{}, repo.(*repoImpl).credentials)
+		ref, err := parseOCIReference("oci://" + registry + "/charts/chart:1.2.3")
+		Expect(err).NotTo(HaveOccurred())
+		var wg sync.WaitGroup
+		errs := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := client.manifest(ref)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	It("fails without credentials", func() {
+		os.Setenv("DOCKER_CONFIG", dir.Join("docker"))
+		defer os.Unsetenv("DOCKER_CONFIG")
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, "oci://"+registry+"/charts/chart:1.2.3")
+		Expect(err).To(MatchError(ContainSubstring("Error fetching token")))
+	})
+
+	It("sends bearer tokens of the kdo config", func() {
+		repo, err := NewRepo(WithBearerAuth("oci://"+registry, "test-token"))
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Push(c, "oci://"+registry+"/charts/chart:latest")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, "oci://"+registry+"/charts/chart")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports errors of credential providers", func() {
+		repo, err := NewRepo(WithCredentialProvider("oci://"+registry, &failingProvider{}))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, "oci://"+registry+"/charts/chart:1.2.3")
+		Expect(err).To(MatchError(ContainSubstring("Can't get credentials for oci://" + registry + "/: vault sealed")))
+	})
+
+	It("uses credentials of the kdo config", func() {
+		repo, err := NewRepo(WithBasicAuth("oci://"+registry, "user", "secret"))
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Push(c, "oci://"+registry+"/charts/chart:latest")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, "oci://"+registry+"/charts/chart")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
+
+type failingProvider struct{}
+
+func (p *failingProvider) Credentials(url string) (*Credentials, error) {
+	return nil, fmt.Errorf("vault sealed")
+}
@@ -0,0 +1,376 @@
+package kdo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/k14s/starlark-go/starlarkstruct"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
+)
+
+// policyConfig - the policies checked before the objects of a chart are applied
+type policyConfig struct {
+	Rules []policyRuleConfig `yaml:"rules,omitempty"`
+	Files []string           `yaml:"files,omitempty"`
+}
+
+// policyRuleConfig - a built-in rule and its action (deny or warn)
+type policyRuleConfig struct {
+	Name   string `yaml:"name"`
+	Action string `yaml:"action,omitempty"`
+}
+
+const (
+	policyDeny = "deny"
+	policyWarn = "warn"
+)
+
+// policyRule returns the violations of an object given as map
+type policyRule func(obj map[string]interface{}) []string
+
+var policyRules = map[string]policyRule{
+	"no-latest-tag":     noLatestTag,
+	"resource-requests": resourceRequests,
+	"no-privileged":     noPrivileged,
+	"no-host-path":      noHostPath,
+}
+
+var containerKeys = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// policyResult - a violation found by a policy
+type policyResult struct {
+	action  string
+	message string
+}
+
+var policyResultConstructor = starlark.String("policy_result")
+
+func (p policyConfig) empty() bool {
+	return len(p.Rules) == 0 && len(p.Files) == 0
+}
+
+// checkPolicies checks the objects of the chart against the configured policies. Warnings are written to warnings,
+// denials are returned as error.
+func (c *chartImpl) checkPolicies(thread *starlark.Thread, objects []*k8s.Object, warnings io.Writer) error {
+	if c.policies.empty() {
+		return nil
+	}
+	values := make([]map[string]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		value, err := objectValue(obj)
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+	messages := map[string][]string{}
+	for _, rule := range c.policies.Rules {
+		check, ok := policyRules[rule.Name]
+		if !ok {
+			return fmt.Errorf("Unknown policy rule %s", rule.Name)
+		}
+		action := rule.Action
+		if action == "" {
+			action = policyDeny
+		}
+		if action != policyDeny && action != policyWarn {
+			return fmt.Errorf("Invalid action %s of policy rule %s. Use deny or warn", action, rule.Name)
+		}
+		for i, value := range values {
+			for _, msg := range check(value) {
+				messages[action] = append(messages[action], fmt.Sprintf("%s: %s (%s)", objects[i].Describe(), msg, rule.Name))
+			}
+		}
+	}
+	for _, file := range c.policies.Files {
+		results, err := c.checkPolicyFile(thread, file, objects, values)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			messages[result.action] = append(messages[result.action], fmt.Sprintf("%s (%s)", result.message, path.Base(file)))
+		}
+	}
+	for _, msg := range messages[policyWarn] {
+		fmt.Fprintf(warnings, "Warning: %s\n", msg)
+	}
+	if denials := messages[policyDeny]; len(denials) != 0 {
+		return fmt.Errorf("Policy check of chart %s failed:\n  %s", c.GetName(), strings.Join(denials, "\n  "))
+	}
+	return nil
+}
+
+// checkPolicyFile executes a starlark policy and calls its functions check_object for each object and check_chart
+// for the chart
+func (c *chartImpl) checkPolicyFile(thread *starlark.Thread, file string, objects []*k8s.Object, values []map[string]interface{}) ([]policyResult, error) {
+	policyThread := &starlark.Thread{Name: "policy", Load: policyLoad(thread.Load)}
+	globals, err := starlark.ExecFile(policyThread, file, nil, starlark.StringDict{
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := []policyResult{}
+	if checkObject, ok := globals["check_object"].(starlark.Callable); ok {
+		for i, value := range values {
+			result, err := starlark.Call(policyThread, checkObject, starlark.Tuple{starutils.WrapDict(starutils.ToStarlark(value))}, nil)
+			if err != nil {
+				return nil, err
+			}
+			objectResults, err := toPolicyResults(file, result)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range objectResults {
+				results = append(results, policyResult{action: r.action, message: objects[i].Describe() + ": " + r.message})
+			}
+		}
+	}
+	if checkChart, ok := globals["check_chart"].(starlark.Callable); ok {
+		list := make([]starlark.Value, 0, len(values))
+		for _, value := range values {
+			list = append(list, starutils.WrapDict(starutils.ToStarlark(value)))
+		}
+		result, err := starlark.Call(policyThread, checkChart, starlark.Tuple{c, starlark.NewList(list)}, nil)
+		if err != nil {
+			return nil, err
+		}
+		chartResults, err := toPolicyResults(file, result)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range chartResults {
+			results = append(results, policyResult{action: r.action, message: "chart " + c.GetName() + ": " + r.message})
+		}
+	}
+	return results, nil
+}
+
+// policyLoad returns a loader, which provides the module @kdo:policy and loads all other modules with load
+func policyLoad(load func(thread *starlark.Thread, module string) (starlark.StringDict, error)) func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+	return func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+		if module == "@kdo:policy" {
+			return policyModule(), nil
+		}
+		if load == nil {
+			return nil, fmt.Errorf("Unknown module '%s'", module)
+		}
+		return load(thread, module)
+	}
+}
+
+func policyModule() starlark.StringDict {
+	result := starlark.StringDict{
+		"deny":       starlark.NewBuiltin("deny", makePolicyResult(policyDeny)),
+		"warn":       starlark.NewBuiltin("warn", makePolicyResult(policyWarn)),
+		"pod_spec":   starlark.NewBuiltin("pod_spec", podSpecFunction),
+		"containers": starlark.NewBuiltin("containers", containersFunction),
+	}
+	for name, rule := range policyRules {
+		name = strings.ReplaceAll(name, "-", "_")
+		result[name] = starlark.NewBuiltin(name, ruleFunction(rule))
+	}
+	return result
+}
+
+func makePolicyResult(action string) func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var message string
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "message", &message); err != nil {
+			return nil, err
+		}
+		return newPolicyResult(action, message), nil
+	}
+}
+
+func newPolicyResult(action string, message string) starlark.Value {
+	return starlarkstruct.FromStringDict(policyResultConstructor, starlark.StringDict{
+		"action":  starlark.String(action),
+		"message": starlark.String(message),
+	})
+}
+
+func podSpecFunction(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var obj starlark.Value
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "obj", &obj); err != nil {
+		return nil, err
+	}
+	spec := podSpec(toObjectValue(obj))
+	if spec == nil {
+		return starlark.None, nil
+	}
+	return starutils.WrapDict(starutils.ToStarlark(spec)), nil
+}
+
+func containersFunction(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var obj starlark.Value
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "obj", &obj); err != nil {
+		return nil, err
+	}
+	result := []starlark.Value{}
+	for _, container := range containers(podSpec(toObjectValue(obj)), containerKeys) {
+		result = append(result, starutils.WrapDict(starutils.ToStarlark(container)))
+	}
+	return starlark.NewList(result), nil
+}
+
+// ruleFunction makes a built-in rule callable from starlark policies. It returns a list of denials.
+func ruleFunction(rule policyRule) func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var obj starlark.Value
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "obj", &obj); err != nil {
+			return nil, err
+		}
+		result := []starlark.Value{}
+		for _, msg := range rule(toObjectValue(obj)) {
+			result = append(result, newPolicyResult(policyDeny, msg))
+		}
+		return starlark.NewList(result), nil
+	}
+}
+
+// toPolicyResults converts the result of a policy function. It's None, a result of deny or warn or a list of them.
+func toPolicyResults(file string, value starlark.Value) ([]policyResult, error) {
+	switch value := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case *starlarkstruct.Struct:
+		if value.Constructor() != policyResultConstructor {
+			break
+		}
+		action, _ := value.Attr("action")
+		message, _ := value.Attr("message")
+		return []policyResult{{action: string(action.(starlark.String)), message: string(message.(starlark.String))}}, nil
+	case *starlark.List, starlark.Tuple:
+		list := value.(starlark.Indexable)
+		results := []policyResult{}
+		for i := 0; i < list.Len(); i++ {
+			r, err := toPolicyResults(file, list.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, r...)
+		}
+		return results, nil
+	}
+	return nil, fmt.Errorf("Policy %s returned %s. Expected None, deny(...), warn(...) or a list of them", file, value.String())
+}
+
+func objectValue(obj *k8s.Object) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj.WithoutSource())
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	return result, json.Unmarshal(data, &result)
+}
+
+func toObjectValue(value starlark.Value) map[string]interface{} {
+	result, _ := starutils.ToGo(starutils.UnwrapDict(value)).(map[string]interface{})
+	return result
+}
+
+// podSpec returns the pod spec of a workload resource or nil
+func podSpec(obj map[string]interface{}) map[string]interface{} {
+	kind, _ := obj["kind"].(string)
+	specPath, ok := podSpecPaths[kind]
+	if !ok {
+		return nil
+	}
+	var current interface{} = obj
+	for _, p := range specPath {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[p]
+	}
+	result, _ := current.(map[string]interface{})
+	return result
+}
+
+// containers returns the containers of a pod spec stored under the given keys
+func containers(spec map[string]interface{}, keys []string) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for _, key := range keys {
+		list, _ := spec[key].([]interface{})
+		for _, container := range list {
+			if c, ok := container.(map[string]interface{}); ok {
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
+func noLatestTag(obj map[string]interface{}) []string {
+	result := []string{}
+	for _, c := range containers(podSpec(obj), containerKeys) {
+		image, _ := c["image"].(string)
+		if image == "" || strings.Contains(image, "@") {
+			continue
+		}
+		tag := ""
+		name := image[strings.LastIndex(image, "/")+1:]
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			tag = name[i+1:]
+		}
+		if tag == "" || tag == "latest" {
+			result = append(result, fmt.Sprintf("container %v uses image %s without a fixed tag", c["name"], image))
+		}
+	}
+	return result
+}
+
+func resourceRequests(obj map[string]interface{}) []string {
+	result := []string{}
+	for _, c := range containers(podSpec(obj), []string{"initContainers", "containers"}) {
+		resources, _ := c["resources"].(map[string]interface{})
+		requests, _ := resources["requests"].(map[string]interface{})
+		missing := []string{}
+		for _, resource := range []string{"cpu", "memory"} {
+			if _, ok := requests[resource]; !ok {
+				missing = append(missing, resource)
+			}
+		}
+		if len(missing) != 0 {
+			result = append(result, fmt.Sprintf("container %v has no %s request", c["name"], strings.Join(missing, " and ")))
+		}
+	}
+	return result
+}
+
+func noPrivileged(obj map[string]interface{}) []string {
+	result := []string{}
+	for _, c := range containers(podSpec(obj), containerKeys) {
+		securityContext, _ := c["securityContext"].(map[string]interface{})
+		if privileged, _ := securityContext["privileged"].(bool); privileged {
+			result = append(result, fmt.Sprintf("container %v is privileged", c["name"]))
+		}
+	}
+	return result
+}
+
+func noHostPath(obj map[string]interface{}) []string {
+	result := []string{}
+	if obj["kind"] == "PersistentVolume" {
+		spec, _ := obj["spec"].(map[string]interface{})
+		if hostPath, ok := spec["hostPath"].(map[string]interface{}); ok {
+			result = append(result, fmt.Sprintf("persistent volume uses host path %v", hostPath["path"]))
+		}
+		return result
+	}
+	volumes, _ := podSpec(obj)["volumes"].([]interface{})
+	for _, volume := range volumes {
+		v, _ := volume.(map[string]interface{})
+		if hostPath, ok := v["hostPath"].(map[string]interface{}); ok {
+			result = append(result, fmt.Sprintf("volume %v uses host path %v", v["name"], hostPath["path"]))
+		}
+	}
+	return result
+}
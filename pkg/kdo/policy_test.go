@@ -0,0 +1,138 @@
+package kdo
+
+import (
+	"bytes"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Policies", func() {
+	var dir TestDir
+	var kim *k8s.K8sInMemory
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		dir.MkdirAll("chart/templates", 0755)
+		dir.WriteFile("chart/Chart.yaml", []byte("name: chart\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("chart/templates/workloads.yaml", []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox
+        resources:
+          requests:
+            cpu: 10m
+            memory: 16Mi
+      containers:
+      - name: web
+        image: nginx:1.19
+        securityContext:
+          privileged: true
+        resources:
+          requests:
+            cpu: 100m
+      volumes:
+      - name: logs
+        hostPath:
+          path: /var/log
+`), 0644)
+		kim = k8s.NewK8sInMemory("default")
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	apply := func(configs ...RepoConfig) error {
+		repo, err := NewRepo(configs...)
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		return c.Apply(thread, kim)
+	}
+
+	applied := func() bool {
+		_, err := kim.Get("deployment", "web", &k8s.Options{})
+		return err == nil
+	}
+
+	It("denies objects violating built-in rules", func() {
+		err := apply(
+			WithPolicyRule("no-latest-tag", ""),
+			WithPolicyRule("resource-requests", "deny"),
+			WithPolicyRule("no-privileged", ""),
+			WithPolicyRule("no-host-path", ""))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Policy check of chart chart failed"))
+		Expect(err.Error()).To(ContainSubstring("templates/workloads.yaml:2 Deployment web: container init uses image busybox without a fixed tag (no-latest-tag)"))
+		Expect(err.Error()).To(ContainSubstring("Deployment web: container web has no memory request (resource-requests)"))
+		Expect(err.Error()).To(ContainSubstring("Deployment web: container web is privileged (no-privileged)"))
+		Expect(err.Error()).To(ContainSubstring("Deployment web: volume logs uses host path /var/log (no-host-path)"))
+		Expect(applied()).To(BeFalse())
+	})
+
+	It("only warns about violations of rules with action warn", func() {
+		warnings := &bytes.Buffer{}
+		Expect(apply(WithPolicyRule("no-privileged", "warn"), WithWarnings(warnings))).To(Succeed())
+		Expect(applied()).To(BeTrue())
+		Expect(warnings.String()).To(Equal("Warning: templates/workloads.yaml:2 Deployment web: container web is privileged (no-privileged)\n"))
+	})
+
+	It("applies charts without policies", func() {
+		Expect(apply()).To(Succeed())
+		Expect(applied()).To(BeTrue())
+	})
+
+	It("rejects unknown rules", func() {
+		err := apply(WithPolicyRule("no-root", ""))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unknown policy rule no-root"))
+	})
+
+	It("reads policies from the config file", func() {
+		dir.WriteFile("config", []byte("policies:\n  rules:\n  - name: no-host-path\n"), 0644)
+		err := apply(WithConfigFile(dir.Join("config")))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("volume logs uses host path /var/log"))
+	})
+
+	It("evaluates starlark policies per object and per chart", func() {
+		dir.WriteFile("policy.star", []byte(`
+load("@kdo:policy", "deny", "warn", "containers", "no_privileged")
+
+def check_object(obj):
+  results = no_privileged(obj)
+  for c in containers(obj):
+    if c.image.startswith("nginx"):
+      results.append(deny("image %s isn't approved" % c.image))
+  return results
+
+def check_chart(chart, objects):
+  if len(objects) < 2:
+    return warn("chart %s has only %d object" % (chart.name, len(objects)))
+`), 0644)
+		err := apply(WithPolicyFile(dir.Join("policy.star")))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Deployment web: container web is privileged (policy.star)"))
+		Expect(err.Error()).To(ContainSubstring("Deployment web: image nginx:1.19 isn't approved (policy.star)"))
+		Expect(err.Error()).NotTo(ContainSubstring("only"))
+		Expect(applied()).To(BeFalse())
+	})
+
+	It("reports invalid results of starlark policies", func() {
+		dir.WriteFile("policy.star", []byte("def check_object(obj):\n  return 'denied'\n"), 0644)
+		err := apply(WithPolicyFile(dir.Join("policy.star")))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`returned "denied". Expected None, deny(...), warn(...) or a list of them`))
+	})
+})
@@ -3,8 +3,12 @@ package kdo
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
 
 	"github.com/k14s/starlark-go/starlark"
+	"github.com/k14s/starlark-go/syntax"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
 )
 
@@ -27,28 +31,71 @@ type PropertyValue interface {
 }
 
 type property struct {
-	typ   string
-	value starlark.Value
-	dflt  starlark.Value
+	typ         string
+	typed       bool
+	value       starlark.Value
+	dflt        starlark.Value
+	required    bool
+	enum        *starlark.List
+	pattern     *regexp.Regexp
+	min         starlark.Value
+	max         starlark.Value
+	description string
+	secret      bool
+	deprecated  bool
+}
+
+// propertyError - validation error of a property value. path locates the property (e.g. db.port)
+type propertyError struct {
+	path string
+	msg  string
 }
 
 var _ PropertyValue = (*property)(nil)
 var _ starutils.GoConvertible = (*property)(nil)
 
+var propertyTypes = map[string]bool{"string": true, "int": true, "float": true, "bool": true, "list": true, "dict": true, "any": true}
+
 func newProperty(dflt starlark.Value) *property {
-	return &property{value: starlark.None, typ: "string", dflt: dflt}
+	return &property{value: starlark.None, typ: "string", dflt: dflt, min: starlark.None, max: starlark.None}
 }
 
 func makeProperty(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
 	s := newProperty(starlark.None)
-	var err error
-	if err = starlark.UnpackArgs("property", args, kwargs, "type?", &s.typ, "default?", &s.dflt); err != nil {
+	s.typ = ""
+	var pattern string
+	if err := starlark.UnpackArgs("property", args, kwargs, "type?", &s.typ, "default?", &s.dflt, "required?", &s.required,
+		"enum?", &s.enum, "pattern?", &pattern, "min?", &s.min, "max?", &s.max, "description?", &s.description, "secret?", &s.secret, "deprecated?", &s.deprecated); err != nil {
 		return nil, err
 	}
+	if s.typ == "" {
+		s.typ = "string"
+	} else {
+		if !propertyTypes[s.typ] {
+			return nil, fmt.Errorf("property: unknown type %s", s.typ)
+		}
+		s.typed = true
+	}
+	if pattern != "" {
+		var err error
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("property: invalid pattern %s: %s", pattern, err.Error())
+		}
+	}
+	if s.dflt != starlark.None {
+		dflt, err := s.validate(s.dflt)
+		if err != nil {
+			return nil, fmt.Errorf("property: invalid default: %s", err.Error())
+		}
+		s.dflt = dflt
+	}
 	return s, nil
 }
 
 func (s *property) String() string {
+	if s.secret {
+		return fmt.Sprintf("property(type = %s, value = %s , default = %s)", s.typ, redactedValue, redactedValue)
+	}
 	return fmt.Sprintf("property(type = %s, value = %v , default = %v)", s.typ, s.value, s.dflt)
 }
 
@@ -76,10 +123,138 @@ func (s *property) SetValue(value starlark.Value) error {
 	if ok {
 		value = o.GetValueOrDefault()
 	}
+	value, err := s.validate(value)
+	if err != nil {
+		return err
+	}
 	s.value = value
 	return nil
 }
 
+// validate checks value against the constraints of the property. Strings are converted to the type of the property.
+func (s *property) validate(value starlark.Value) (starlark.Value, error) {
+	if value == starlark.None {
+		return value, nil
+	}
+	if _, ok := value.(*secretRef); ok {
+		return value, nil
+	}
+	value, err := s.convert(value)
+	if err != nil {
+		return nil, err
+	}
+	if s.enum != nil {
+		found := false
+		for i := 0; i < s.enum.Len(); i++ {
+			if eq, err := starlark.Equal(s.enum.Index(i), value); err == nil && eq {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &propertyError{msg: fmt.Sprintf("%s is not one of %s", value.String(), s.enum.String())}
+		}
+	}
+	if s.pattern != nil {
+		str, ok := value.(starlark.String)
+		if !ok || !s.pattern.MatchString(str.GoString()) {
+			return nil, &propertyError{msg: fmt.Sprintf("%s doesn't match pattern %s", value.String(), s.pattern.String())}
+		}
+	}
+	if err := s.checkBound(value, s.min, syntax.LT, "less than minimum"); err != nil {
+		return nil, err
+	}
+	if err := s.checkBound(value, s.max, syntax.GT, "greater than maximum"); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// checkBound compares numbers by value and strings and lists by length
+func (s *property) checkBound(value starlark.Value, bound starlark.Value, op syntax.Token, msg string) error {
+	if bound == starlark.None {
+		return nil
+	}
+	compared := value
+	switch v := value.(type) {
+	case starlark.String, *starlark.List, starlark.Tuple:
+		compared = starlark.MakeInt(starlark.Len(v))
+	}
+	result, err := starlark.Compare(op, compared, bound)
+	if err != nil {
+		return &propertyError{msg: err.Error()}
+	}
+	if result {
+		return &propertyError{msg: fmt.Sprintf("%s is %s %s", value.String(), msg, bound.String())}
+	}
+	return nil
+}
+
+func (s *property) convert(value starlark.Value) (starlark.Value, error) {
+	if !s.typed {
+		return value, nil
+	}
+	str, isString := value.(starlark.String)
+	switch s.typ {
+	case "string":
+		if isString {
+			return value, nil
+		}
+	case "int":
+		if isString {
+			if i, err := strconv.ParseInt(str.GoString(), 10, 64); err == nil {
+				return starlark.MakeInt64(i), nil
+			}
+		}
+		if _, ok := value.(starlark.Int); ok {
+			return value, nil
+		}
+		// persisted properties are read back as json numbers
+		if f, ok := value.(starlark.Float); ok && float64(f) == math.Trunc(float64(f)) {
+			return starlark.MakeInt64(int64(f)), nil
+		}
+	case "float":
+		if isString {
+			if f, err := strconv.ParseFloat(str.GoString(), 64); err == nil {
+				return starlark.Float(f), nil
+			}
+		}
+		switch value.(type) {
+		case starlark.Int, starlark.Float:
+			return value, nil
+		}
+	case "bool":
+		if isString {
+			if b, err := strconv.ParseBool(str.GoString()); err == nil {
+				return starlark.Bool(b), nil
+			}
+		}
+		if _, ok := value.(starlark.Bool); ok {
+			return value, nil
+		}
+	case "list":
+		switch value.(type) {
+		case *starlark.List, starlark.Tuple:
+			return value, nil
+		}
+	case "dict":
+		if _, ok := value.(starlark.IterableMapping); ok {
+			return value, nil
+		}
+	case "any":
+		return value, nil
+	}
+	return nil, &propertyError{msg: fmt.Sprintf("%s is not of type %s", value.String(), s.typ)}
+}
+
+// checkRequired returns an error, if a required property has neither a value nor a default
+func (s *property) checkRequired() error {
+	if s.required && s.GetValueOrDefault() == starlark.None {
+		return &propertyError{msg: "value is required"}
+	}
+	return nil
+}
+
 func (s *property) GetValue() starlark.Value {
 	return s.value
 }
@@ -99,3 +274,23 @@ func (s *property) AttrNames() []string {
 func (s *property) ToGo() interface{} {
 	return starutils.ToGo(s.GetValueOrDefault())
 }
+
+func (e *propertyError) Error() string {
+	if e.path == "" {
+		return e.msg
+	}
+	return fmt.Sprintf("invalid value for %s: %s", e.path, e.msg)
+}
+
+// withPath prefixes the path of a property error with name
+func withPath(name string, err error) error {
+	pe, ok := err.(*propertyError)
+	if !ok {
+		return err
+	}
+	path := name
+	if pe.path != "" {
+		path = name + "." + pe.path
+	}
+	return &propertyError{path: path, msg: pe.msg}
+}
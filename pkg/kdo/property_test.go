@@ -35,4 +35,83 @@ var _ = Describe("Property", func() {
 
 	})
 
+	Context("validation", func() {
+		thread := &starlark.Thread{Name: "main"}
+		newTypedProperty := func(kwargs ...starlark.Tuple) *property {
+			p, err := makeProperty(thread, nil, nil, kwargs)
+			Expect(err).NotTo(HaveOccurred())
+			return p.(*property)
+		}
+		kwarg := func(name string, value starlark.Value) starlark.Tuple {
+			return starlark.Tuple{starlark.String(name), value}
+		}
+
+		It("converts and checks types", func() {
+			p := newTypedProperty(kwarg("type", starlark.String("int")))
+			Expect(p.SetValue(starlark.String("8080"))).To(Succeed())
+			Expect(p.GetValue()).To(Equal(starlark.MakeInt(8080)))
+			Expect(p.SetValue(starlark.String("abc"))).To(MatchError("\"abc\" is not of type int"))
+			Expect(p.SetValue(starlark.Float(8080))).To(Succeed())
+			Expect(p.GetValue()).To(Equal(starlark.MakeInt(8080)))
+			Expect(p.SetValue(starlark.Float(80.5))).To(MatchError("80.5 is not of type int"))
+			p = newTypedProperty(kwarg("type", starlark.String("bool")))
+			Expect(p.SetValue(starlark.String("true"))).To(Succeed())
+			Expect(p.GetValue()).To(Equal(starlark.True))
+		})
+		It("rejects unknown types", func() {
+			_, err := makeProperty(thread, nil, nil, []starlark.Tuple{kwarg("type", starlark.String("unknown"))})
+			Expect(err).To(HaveOccurred())
+		})
+		It("checks enum, pattern and bounds", func() {
+			p := newTypedProperty(kwarg("enum", starlark.NewList([]starlark.Value{starlark.String("a"), starlark.String("b")})))
+			Expect(p.SetValue(starlark.String("a"))).To(Succeed())
+			Expect(p.SetValue(starlark.String("c"))).To(HaveOccurred())
+			p = newTypedProperty(kwarg("pattern", starlark.String("^[a-z]+$")))
+			Expect(p.SetValue(starlark.String("abc"))).To(Succeed())
+			Expect(p.SetValue(starlark.String("ABC"))).To(HaveOccurred())
+			p = newTypedProperty(kwarg("type", starlark.String("int")), kwarg("min", starlark.MakeInt(1)), kwarg("max", starlark.MakeInt(65535)))
+			Expect(p.SetValue(starlark.MakeInt(0))).To(MatchError("0 is less than minimum 1"))
+			Expect(p.SetValue(starlark.MakeInt(65536))).To(HaveOccurred())
+			Expect(p.SetValue(starlark.MakeInt(80))).To(Succeed())
+			p = newTypedProperty(kwarg("type", starlark.String("string")), kwarg("max", starlark.MakeInt(3)))
+			Expect(p.SetValue(starlark.String("abcd"))).To(HaveOccurred())
+		})
+		It("validates the default", func() {
+			_, err := makeProperty(thread, nil, nil, []starlark.Tuple{kwarg("type", starlark.String("int")), kwarg("default", starlark.String("x"))})
+			Expect(err).To(HaveOccurred())
+		})
+		It("reports the path of invalid values", func() {
+			db := newStructProperty(false)
+			db.add("port", newTypedProperty(kwarg("type", starlark.String("int")), kwarg("required", starlark.True)))
+			root := newStructProperty(false)
+			root.add("db", db)
+			value := starlark.NewDict(1)
+			value.SetKey(starlark.String("port"), starlark.String("x"))
+			Expect(root.SetField("db", value)).To(MatchError("invalid value for db.port: \"x\" is not of type int"))
+			Expect(root.checkRequired()).To(MatchError("invalid value for db.port: value is required"))
+		})
+		It("hides secrets", func() {
+			p := newTypedProperty(kwarg("secret", starlark.True), kwarg("default", starlark.String("xxx")))
+			Expect(p.String()).NotTo(ContainSubstring("xxx"))
+			Expect(p.schema()).NotTo(HaveKey("default"))
+			Expect(p.schema()).To(HaveKeyWithValue("writeOnly", true))
+		})
+		It("describes itself as JSON schema", func() {
+			p := newTypedProperty(kwarg("type", starlark.String("int")), kwarg("min", starlark.MakeInt(1)), kwarg("description", starlark.String("port")), kwarg("required", starlark.True))
+			s := newStructProperty(false)
+			s.add("port", p)
+			Expect(s.schema()).To(Equal(map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"required":             []string{"port"},
+				"properties": map[string]interface{}{
+					"port": map[string]interface{}{"type": "integer", "minimum": int64(1), "description": "port"},
+				},
+			}))
+		})
+		It("describes bounds of untyped properties as length", func() {
+			p := newTypedProperty(kwarg("min", starlark.MakeInt(1)), kwarg("max", starlark.MakeInt(8)))
+			Expect(p.schema()).To(Equal(map[string]interface{}{"minLength": int64(1), "maxLength": int64(8)}))
+		})
+	})
 })
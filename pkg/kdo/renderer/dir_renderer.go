@@ -11,6 +11,8 @@ import (
 type DirSpec struct {
 	Dir          string
 	FileRenderer func(filename string) func(writer io.Writer) error
+	// Source is the path of Dir used in source comments, e.g. templates. No source comments are written if it's empty.
+	Source string
 }
 
 // DirRender -
@@ -47,8 +49,15 @@ func DirRender(glob string, specs ...DirSpec) func(io.Writer) error {
 			for _, filename := range filenames {
 				processors = append(processors, r.FileRenderer(filename))
 			}
-			for _, processor := range processors {
+			for i, processor := range processors {
 				writer := &YamlWriter{Writer: in}
+				if r.Source != "" {
+					rel, err := filepath.Rel(r.Dir, filenames[i])
+					if err != nil {
+						return err
+					}
+					writer.Source = path.Join(r.Source, filepath.ToSlash(rel))
+				}
 				err := processor(writer)
 				if err != nil {
 					return err
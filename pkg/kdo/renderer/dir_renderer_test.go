@@ -34,10 +34,20 @@ var _ = Describe("DirRender", func() {
 			dir.WriteFile("test2.yml", []byte("test: test2"), 0644)
 
 			writer := &bytes.Buffer{}
-			err = DirRender("", DirSpec{dir.Root(), fileRenderer})(writer)
+			err = DirRender("", DirSpec{Dir: dir.Root(), FileRenderer: fileRenderer})(writer)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(writer.String()).To(Equal("---\ntest: test1\n---\ntest: test2\n"))
 		})
+		It("writes sources relative to the dir", func() {
+			dir := NewTestDir()
+			defer dir.Remove()
+			dir.MkdirAll("templates/sub", 0755)
+			dir.WriteFile("templates/sub/test.yaml", []byte("test: test1"), 0644)
+			writer := &bytes.Buffer{}
+			err := DirRender("", DirSpec{Dir: dir.Join("templates"), FileRenderer: fileRenderer, Source: "templates"})(writer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.String()).To(Equal("---\n# Source: templates/sub/test.yaml\ntest: test1\n"))
+		})
 		It("repects glob patterns", func() {
 			var err error
 			dir := NewTestDir()
@@ -45,7 +55,7 @@ var _ = Describe("DirRender", func() {
 			dir.WriteFile("test1.yaml", []byte("test: test1"), 0644)
 			dir.WriteFile("test3.yaml", []byte("test: test2"), 0644)
 			writer := &bytes.Buffer{}
-			err = DirRender("*[1-2].yaml", DirSpec{dir.Root(), fileRenderer})(writer)
+			err = DirRender("*[1-2].yaml", DirSpec{Dir: dir.Root(), FileRenderer: fileRenderer})(writer)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(writer.String()).To(Equal("---\ntest: test1\n"))
 		})
@@ -1,9 +1,12 @@
 package renderer
 
 import (
+	"encoding/base64"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
+	"strings"
 )
 
 // Files -
@@ -11,24 +14,26 @@ type Files struct {
 	Dir string
 }
 
-// Glob -
-func (f Files) Glob(pattern string) map[string][]byte {
-	result := make(map[string][]byte)
-	matches, err := filepath.Glob(path.Join(f.Dir, pattern))
-	if err != nil {
-		return result
-	}
-	for _, match := range matches {
-		data, err := ioutil.ReadFile(match)
+// FileMap - files selected by Glob keyed by their path relative to the chart
+type FileMap map[string][]byte
+
+// Glob - returns the files matching a pattern. ** matches any number of directories.
+func (f Files) Glob(pattern string) FileMap {
+	result := make(FileMap)
+	_ = filepath.Walk(f.Dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(f.Dir, file)
+		if err != nil || !matchGlob(pattern, filepath.ToSlash(rel)) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(file)
 		if err == nil {
-			p, err := filepath.Rel(f.Dir, match)
-			if err == nil {
-				result[p] = data
-			} else {
-			}
-		} else {
+			result[filepath.ToSlash(rel)] = data
 		}
-	}
+		return nil
+	})
 	return result
 }
 
@@ -40,3 +45,103 @@ func (f Files) Get(name string) string {
 	}
 	return string(data)
 }
+
+// GetBytes -
+func (f Files) GetBytes(name string) []byte {
+	data, err := ioutil.ReadFile(path.Join(f.Dir, name))
+	if err != nil {
+		return []byte{}
+	}
+	return data
+}
+
+// Lines - the lines of a file
+func (f Files) Lines(name string) []string {
+	return lines(f.GetBytes(name))
+}
+
+// Get -
+func (m FileMap) Get(name string) string {
+	return string(m[name])
+}
+
+// GetBytes -
+func (m FileMap) GetBytes(name string) []byte {
+	return m[name]
+}
+
+// Lines - the lines of a file
+func (m FileMap) Lines(name string) []string {
+	return lines(m[name])
+}
+
+// Glob - returns the files of the map matching a pattern
+func (m FileMap) Glob(pattern string) FileMap {
+	result := make(FileMap)
+	for name, data := range m {
+		if matchGlob(pattern, name) {
+			result[name] = data
+		}
+	}
+	return result
+}
+
+// AsConfig - returns the files as data of a config map keyed by their base names
+func (m FileMap) AsConfig() string {
+	data := map[string]string{}
+	for name, content := range m {
+		data[path.Base(name)] = string(content)
+	}
+	if len(data) == 0 {
+		return ""
+	}
+	s, _ := toYAML(data)
+	return s
+}
+
+// AsSecrets - returns the base64 encoded files as data of a secret keyed by their base names
+func (m FileMap) AsSecrets() string {
+	data := map[string]string{}
+	for name, content := range m {
+		data[path.Base(name)] = base64.StdEncoding.EncodeToString(content)
+	}
+	if len(data) == 0 {
+		return ""
+	}
+	s, _ := toYAML(data)
+	return s
+}
+
+func lines(data []byte) []string {
+	if len(data) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// matchGlob matches a slash separated path. ** matches any number of path segments, the other segments are
+// matched with path.Match.
+func matchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
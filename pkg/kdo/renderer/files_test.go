@@ -21,4 +21,25 @@ var _ = Describe("files", func() {
 		Expect(f.Get("file2.yaml")).To(Equal("aaaa"))
 		Expect(f.Get("file3.yaml")).To(ContainSubstring("no such file"))
 	})
+
+	It("globs files like helm", func() {
+		dir := NewTestDir()
+		defer dir.Remove()
+		dir.MkdirAll("config/nested", 0755)
+		dir.WriteFile("config/a.conf", []byte("a=1\nb=2\n"), 0644)
+		dir.WriteFile("config/nested/b.conf", []byte("c"), 0644)
+		dir.WriteFile("config/nested/c.txt", []byte("d"), 0644)
+		f := Files{Dir: dir.Root()}
+		Expect(f.Glob("config/*.conf")).To(Equal(FileMap{"config/a.conf": []byte("a=1\nb=2\n")}))
+		Expect(f.Glob("config/**.conf")).To(HaveLen(1))
+		Expect(f.Glob("config/**/*.conf")).To(HaveLen(2))
+		Expect(f.Glob("**/*.txt")).To(HaveKey("config/nested/c.txt"))
+		Expect(f.Glob("config/**").Glob("**/*.txt")).To(HaveLen(1))
+		Expect(f.Glob("config/**/*.conf").AsConfig()).To(Equal("a.conf: |\n  a=1\n  b=2\nb.conf: c"))
+		Expect(f.Glob("config/nested/*.conf").AsSecrets()).To(Equal("b.conf: Yw=="))
+		Expect(f.Glob("missing/*").AsConfig()).To(BeEmpty())
+		Expect(f.Lines("config/a.conf")).To(Equal([]string{"a=1", "b=2"}))
+		Expect(f.Glob("config/*").Lines("config/a.conf")).To(Equal([]string{"a=1", "b=2"}))
+		Expect(f.Glob("config/*").Get("config/a.conf")).To(Equal("a=1\nb=2\n"))
+	})
 })
@@ -12,52 +12,72 @@ import (
 	"reflect"
 	"strings"
 	"text/template"
+	"text/template/parse"
 
 	yaml "gopkg.in/yaml.v2"
+	k8syaml "sigs.k8s.io/yaml"
 
+	"github.com/BurntSushi/toml"
 	"github.com/Masterminds/sprig/v3"
 )
 
 type helmRenderer struct {
-	helpers string
+	helpers []string
 	root    *template.Template
 }
 
-// HelmFileRenderer -
-func HelmFileRenderer(dir string, value interface{}) func(filename string) func(writer io.Writer) error {
-	h, err := newHelmRenderer(dir)
-	if err != nil {
-		return errorFileRenderer(err)
+// HelmFileRenderer - renders helm templates. funcs add or replace template functions, e.g. lookup.
+func HelmFileRenderer(dir string, value interface{}, funcs ...template.FuncMap) func(filename string) func(writer io.Writer) error {
+	return HelmTreeRenderer([]string{dir}, funcs...)(value)
+}
+
+// HelmTreeRenderer - renders the helm templates of a chart and its sub charts. The helpers of all charts in dirs are
+// parsed into one template root, so the charts can include helpers of each other like in helm. Helpers of later dirs
+// replace helpers with the same name, i.e. the root chart should be the last one.
+func HelmTreeRenderer(dirs []string, funcs ...template.FuncMap) func(value interface{}) func(filename string) func(writer io.Writer) error {
+	h, err := newHelmRenderer(dirs)
+	if err == nil {
+		for _, f := range funcs {
+			h.root.Funcs(f)
+		}
+		for _, helpers := range h.helpers {
+			if _, err = h.root.Parse(helpers); err != nil {
+				break
+			}
+		}
 	}
-	if h.helpers != "" {
-		_, err = h.root.Parse(h.helpers)
+	return func(value interface{}) func(filename string) func(writer io.Writer) error {
 		if err != nil {
 			return errorFileRenderer(err)
 		}
+		return h.fileTemplater(value)
 	}
-	return h.fileTemplater(value)
 }
 
-func newHelmRenderer(dir string) (*helmRenderer, error) {
+func newHelmRenderer(dirs []string) (*helmRenderer, error) {
 	h := &helmRenderer{root: template.New("root")}
-	content, err := ioutil.ReadFile(path.Join(dir, "templates", "_helpers.tpl"))
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, err
+	for _, dir := range dirs {
+		content, err := ioutil.ReadFile(path.Join(dir, "templates", "_helpers.tpl"))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
 		}
-		h.helpers = ""
-	} else {
-		h.helpers = string(content)
+		h.helpers = append(h.helpers, string(content))
 	}
 	h.root.Funcs(sprig.TxtFuncMap())
 	h.root.Funcs(map[string]interface{}{
-		"toToml":   notImplemented("toToml"),
-		"toYaml":   toYAML,
-		"fromYaml": notImplemented("fromYaml"),
-		"toJson":   toJSON,
-		"fromJson": notImplemented("fromJson"),
-		"tpl":      h.tpl(),
-		"required": required,
+		"toToml":        toTOML,
+		"toYaml":        toYAML,
+		"fromYaml":      fromYAML,
+		"fromYamlArray": fromYAMLArray,
+		"toJson":        toJSON,
+		"fromJson":      fromJSON,
+		"fromJsonArray": fromJSONArray,
+		"tpl":           h.tpl(),
+		"required":      required,
+		"lookup":        lookup,
 		"include": func(name string, data interface{}) (string, error) {
 			var buf strings.Builder
 			err := h.root.ExecuteTemplate(&buf, name, data)
@@ -93,15 +113,46 @@ func (h *helmRenderer) fileTemplater(value interface{}) func(filename string) fu
 			return errorWriter(err)
 		}
 		return func(writer io.Writer) error {
+			if w, ok := writer.(*YamlWriter); ok && w.Source != "" && tpl.Tree != nil {
+				markLines(tpl.Tree.Root, string(content))
+			}
 			return tpl.Execute(writer, value)
 		}
 	}
 
 }
 
-func notImplemented(name string) func(_ interface{}) (string, error) {
-	return func(_ interface{}) (string, error) {
-		return "", fmt.Errorf("Function %s is not implemented in kdo", name)
+// markLines prefixes the text nodes of a template and each of their lines with a marker containing the line in the
+// template text. YamlWriter removes the markers and uses them for the source comments.
+func markLines(node parse.Node, text string) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			markLines(n, text)
+		}
+	case *parse.TextNode:
+		line := 1 + strings.Count(text[:node.Pos], "\n")
+		marked := []byte(fmt.Sprintf("%c%d%c", lineMarker, line, lineMarker))
+		for _, b := range node.Text {
+			marked = append(marked, b)
+			if b == '\n' {
+				line++
+				marked = append(marked, fmt.Sprintf("%c%d%c", lineMarker, line, lineMarker)...)
+			}
+		}
+		node.Text = marked
+	case *parse.IfNode:
+		markLines(node.List, text)
+		markLines(node.ElseList, text)
+	case *parse.RangeNode:
+		markLines(node.List, text)
+		markLines(node.ElseList, text)
+	case *parse.WithNode:
+		markLines(node.List, text)
+		markLines(node.ElseList, text)
 	}
 }
 
@@ -136,6 +187,54 @@ func toYAML(v interface{}) (string, error) {
 	return strings.TrimSuffix(string(data), "\n"), nil
 }
 
+// toTOML returns the toml of a value or the error message like helm does
+func toTOML(v interface{}) string {
+	b := &bytes.Buffer{}
+	if err := toml.NewEncoder(b).Encode(v); err != nil {
+		return err.Error()
+	}
+	return b.String()
+}
+
+// fromYAML converts yaml into a map. Errors are returned in the key Error like helm does.
+func fromYAML(str string) map[string]interface{} {
+	m := map[string]interface{}{}
+	if err := k8syaml.Unmarshal([]byte(str), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+	return m
+}
+
+// fromYAMLArray converts a yaml array into a slice. Errors are returned as only element.
+func fromYAMLArray(str string) []interface{} {
+	a := []interface{}{}
+	if err := k8syaml.Unmarshal([]byte(str), &a); err != nil {
+		a = []interface{}{err.Error()}
+	}
+	return a
+}
+
+func fromJSON(str string) map[string]interface{} {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(str), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+	return m
+}
+
+func fromJSONArray(str string) []interface{} {
+	a := []interface{}{}
+	if err := json.Unmarshal([]byte(str), &a); err != nil {
+		a = []interface{}{err.Error()}
+	}
+	return a
+}
+
+// lookup returns an empty map, if no cluster is given like helm template does
+func lookup(apiVersion string, kind string, namespace string, name string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
 func toJSON(v interface{}) (string, error) {
 	data, err := json.Marshal(v)
 	if err != nil {
@@ -151,5 +151,43 @@ var _ = Describe("helmRenderer", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(writer.String()).To(Equal("test: chart-version"))
 		})
+
+		It("parses yaml, json and toml", func() {
+			dir := NewTestDir()
+			defer dir.Remove()
+			dir.WriteFile("test.yaml", []byte(`a: {{ (fromYaml "x: 1\nlist: [2]").list | first }}
+b: {{ (fromJson "{\"x\": \"json\"}").x }}
+c: {{ fromYamlArray "- 1\n- 2" | len }}
+d: {{ fromJsonArray "[1, 2, 3]" | len }}
+e: {{ (fromYaml "[").Error | empty | not }}
+f: {{ fromJsonArray "{" | len }}
+{{ .Value | toToml }}`), 0644)
+			helmFileRenderer := HelmFileRenderer(dir.Root(), struct {
+				Value map[string]interface{}
+			}{
+				Value: map[string]interface{}{"key": "value", "table": map[string]interface{}{"n": 1}},
+			})
+			writer := &bytes.Buffer{}
+			Expect(helmFileRenderer(dir.Join("test.yaml"))(writer)).To(Succeed())
+			Expect(writer.String()).To(Equal("a: 2\nb: json\nc: 2\nd: 3\ne: true\nf: 1\nkey = \"value\"\n\n[table]\n  n = 1\n"))
+		})
+
+		It("looks up objects", func() {
+			dir := NewTestDir()
+			defer dir.Remove()
+			dir.WriteFile("test.yaml", []byte(`default: {{ lookup "v1" "Secret" "ns" "name" | len }}`), 0644)
+			writer := &bytes.Buffer{}
+			Expect(HelmFileRenderer(dir.Root(), nil)(dir.Join("test.yaml"))(writer)).To(Succeed())
+			Expect(writer.String()).To(Equal("default: 0"))
+
+			writer.Reset()
+			dir.WriteFile("test.yaml", []byte(`found: {{ (lookup "v1" "Secret" "ns" "name").kind }}`), 0644)
+			Expect(HelmFileRenderer(dir.Root(), nil, map[string]interface{}{
+				"lookup": func(apiVersion, kind, namespace, name string) (map[string]interface{}, error) {
+					return map[string]interface{}{"kind": kind + "/" + namespace + "/" + name}, nil
+				},
+			})(dir.Join("test.yaml"))(writer)).To(Succeed())
+			Expect(writer.String()).To(Equal("found: Secret/ns/name"))
+		})
 	})
 })
@@ -1,10 +1,16 @@
 package renderer
 
 type Entry struct {
-	Deprecated bool     `yaml:"deprecated,omitempty"`
-	URLs       []string `yaml:"urls,omitempty"`
+	Name        string   `yaml:"name,omitempty"`
+	Genus       string   `yaml:"genus,omitempty"`
+	Version     string   `yaml:"version,omitempty"`
+	Description string   `yaml:"description,omitempty"`
+	Digest      string   `yaml:"digest,omitempty"`
+	Deprecated  bool     `yaml:"deprecated,omitempty"`
+	URLs        []string `yaml:"urls,omitempty"`
 }
 
 type Index struct {
-	Entries map[string][]Entry `yaml:"entries,omitempty"`
+	APIVersion string             `yaml:"apiVersion,omitempty"`
+	Entries    map[string][]Entry `yaml:"entries,omitempty"`
 }
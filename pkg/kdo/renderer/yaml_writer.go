@@ -1,11 +1,29 @@
 package renderer
 
-import "io"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SourceComment - prefix of the comment written in front of each document with the file and line it was rendered from.
+const SourceComment = "# Source: "
+
+// lineMarker - encloses the template line in the markers written by templates with source lines, see markLines
+const lineMarker = '\x00'
 
 // YamlWriter -
 type YamlWriter struct {
 	state  int
 	Writer io.Writer
+	// Source is the file written. If it's set, each document starts with a source comment. The comment contains the
+	// template line the document starts at, if the template marks its lines.
+	Source       string
+	templateLine int
+	buffer       []byte
+	inDocument   bool
 }
 
 const skipWhitespace = 0
@@ -14,6 +32,9 @@ const endSeperator = 2
 const normal = 3
 
 func (w *YamlWriter) Write(data []byte) (int, error) {
+	if w.Source != "" {
+		return w.writeWithSource(data)
+	}
 	if w.state != normal {
 		for i, b := range data {
 			switch w.state {
@@ -46,3 +67,70 @@ func (w *YamlWriter) Write(data []byte) (int, error) {
 	}
 	return w.Writer.Write(data)
 }
+
+// writeWithSource writes complete lines and starts each document with a separator and a source comment
+func (w *YamlWriter) writeWithSource(data []byte) (int, error) {
+	for _, b := range data {
+		w.buffer = append(w.buffer, b)
+		if b == '\n' {
+			if err := w.writeLine(w.buffer); err != nil {
+				return 0, err
+			}
+			w.buffer = w.buffer[:0]
+		}
+	}
+	return len(data), nil
+}
+
+func (w *YamlWriter) writeLine(line []byte) error {
+	line, start := w.stripLineMarkers(line)
+	content := strings.TrimRight(string(line), " \t\r\n")
+	if content == "---" || strings.HasPrefix(content, "--- ") {
+		w.inDocument = false
+		return nil
+	}
+	if !w.inDocument {
+		if strings.TrimSpace(content) == "" {
+			return nil
+		}
+		source := w.Source
+		if start > 0 {
+			source += ":" + strconv.Itoa(start)
+		}
+		if _, err := fmt.Fprintf(w.Writer, "---\n%s%s\n", SourceComment, source); err != nil {
+			return err
+		}
+		w.inDocument = true
+	}
+	_, err := w.Writer.Write(line)
+	return err
+}
+
+// stripLineMarkers removes the line markers from line. It returns the template line, the line starts at, i.e. the
+// marker at the beginning of the line or the last marker before the line, or 0, if there is none.
+func (w *YamlWriter) stripLineMarkers(line []byte) ([]byte, int) {
+	start := w.templateLine
+	if bytes.IndexByte(line, lineMarker) < 0 {
+		return line, start
+	}
+	result := make([]byte, 0, len(line))
+	for len(line) > 0 {
+		i := bytes.IndexByte(line, lineMarker)
+		if i < 0 {
+			break
+		}
+		end := bytes.IndexByte(line[i+1:], lineMarker)
+		if end < 0 {
+			break
+		}
+		result = append(result, line[:i]...)
+		if n, err := strconv.Atoi(string(line[i+1 : i+1+end])); err == nil {
+			w.templateLine = n
+			if len(result) == 0 {
+				start = n
+			}
+		}
+		line = line[i+end+2:]
+	}
+	return append(result, line...), start
+}
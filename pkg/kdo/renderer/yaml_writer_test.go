@@ -30,4 +30,20 @@ var _ = Describe("YamlWriter", func() {
 		Expect(buf.String()).To(Equal(""))
 	})
 
+	It("writes the source of each document", func() {
+		buf := &bytes.Buffer{}
+		writer := &YamlWriter{Writer: buf, Source: "templates/test.yaml"}
+		writer.Write([]byte("\n---\na: 1\n  ---\n---\n\nb: 2"))
+		writer.Write([]byte("\n"))
+		Expect(buf.String()).To(Equal("---\n# Source: templates/test.yaml\na: 1\n  ---\n---\n# Source: templates/test.yaml\nb: 2\n"))
+	})
+
+	It("writes the template line of each document", func() {
+		buf := &bytes.Buffer{}
+		writer := &YamlWriter{Writer: buf, Source: "templates/test.yaml"}
+		writer.Write([]byte("\x002\x00a: 1\n\x003\x00---\n"))
+		writer.Write([]byte("b: 2\n\x006\x00c: 3\x006\x00\n"))
+		Expect(buf.String()).To(Equal("---\n# Source: templates/test.yaml:2\na: 1\n---\n# Source: templates/test.yaml:3\nb: 2\nc: 3\n"))
+	})
+
 })
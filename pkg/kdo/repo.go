@@ -6,20 +6,24 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v2"
 
@@ -48,11 +52,29 @@ type Repo interface {
 	GetFromSpec(thread *starlark.Thread, spec *kdov1a2.ChartSpec, options ...ChartOption) (ChartValue, error)
 	// List -
 	List(thread *starlark.Thread, k8s k8s.K8s, listOptions *RepoListOptions) ([]ChartValue, error)
+	// Rekey -
+	Rekey(k8s k8s.K8s, listOptions *RepoListOptions, rotate bool) error
+	// Resolve -
+	Resolve(thread *starlark.Thread, chart Chart, installed k8s.K8s) (*Lock, error)
+	// Versions -
+	Versions(thread *starlark.Thread, url string, namespace string) ([]*ChartVersion, error)
+	// Push -
+	Push(chart Chart, url string) (string, error)
 }
 
 type repoImpl struct {
-	cacheDir string
-	cache    OpenDirCache
+	cacheDir    string
+	cache       OpenDirCache
+	keyProvider KeyProvider
+	helmIndex   helmIndex
+	catalogs    []string
+	oci         *ociClient
+	verifier    *verifier
+	client      *http.Client
+	credentials *credentials
+	images      imageMappings
+	policies    policyConfig
+	warnings    io.Writer
 }
 
 var _ Repo = &repoImpl{}
@@ -77,22 +99,59 @@ func NewRepo(config ...RepoConfig) (Repo, error) {
 	httpClient := &http.Client{
 		Timeout: time.Second * 60,
 	}
-	dirCache := NewDirCache(path.Join(homedir, ".kdo", "cache-etag"))
-	cache := dirCache.WrapDir(loadArchive)
-	cache = openLocal(cache)
-	cache = openURL(dirCache, httpClient, configs.Credentials, cache)
-	cache = openHelm(dirCache, httpClient, configs.Credentials, cache)
+	verifier, err := configs.Verification.newVerifier()
+	if err != nil {
+		return nil, err
+	}
+	dirCache, err := configs.newDirCache()
+	if err != nil {
+		return nil, err
+	}
+	credentials := newCredentials(configs.Credentials, configs.providers, configs.secrets)
+	indexTTL, err := configs.Cache.indexTTL(dirCache)
+	if err != nil {
+		return nil, err
+	}
+	cache := dirCache.WrapDir(loadArchive(verifier))
+	cacheDir := path.Join(homedir, ".kdo", "cache")
+	cache = openLocal(verifier, []string{dirCache.baseDir, cacheDir}, cache)
+	cache = openURL(dirCache, httpClient, credentials, verifier, cache)
+	index := loadHelmIndex(dirCache.withTTL(indexTTL), httpClient, credentials)
+	cache = openHelm(index, loadHelmChart(dirCache, httpClient, credentials, verifier), cache)
+	oci := newOCIClient(httpClient, credentials)
+	cache = openOCI(dirCache, oci, verifier, cache)
+	cache = openGit(dirCache, &gitClient{credentials: credentials}, verifier, cache)
 	cache = openWithFragment(cache)
-	cache = openWithCatalogs(configs.Catalogs, cache)
+	cache = openWithCatalogs(configs.Catalogs, index, cache)
+	keyProvider := configs.keyProvider
+	if keyProvider == nil {
+		keyProvider, err = configs.Encryption.newKeyProvider()
+		if err != nil {
+			return nil, err
+		}
+	}
 	r := &repoImpl{
-		cacheDir: path.Join(homedir, ".kdo", "cache"),
-		cache:    cache,
+		cacheDir:    cacheDir,
+		cache:       cache,
+		keyProvider: keyProvider,
+		helmIndex:   index,
+		catalogs:    configs.Catalogs,
+		oci:         oci,
+		verifier:    verifier,
+		client:      httpClient,
+		credentials: credentials,
+		images:      configs.Images,
+		policies:    configs.Policies,
+		warnings:    configs.warnings,
 	}
 	return r, nil
 }
 
-func openURL(dirCache *DirCache, client *http.Client, credentials []credential, dfltCache OpenDirCache) OpenDirCache {
-	urlCache := dirCache.WrapDir(loadURL(client, credentials, extractArchive))
+func openURL(dirCache *DirCache, client *http.Client, credentials *credentials, verifier *verifier, dfltCache OpenDirCache) OpenDirCache {
+	urlCache := dirCache.WrapRemoteDir(func(url string, targetDir func() (string, error), etagOld string) (string, error) {
+		extract := verifier.verifying(url, loadURLSuffix(client, credentials, url), extractArchive)
+		return loadURL(client, credentials, extract)(url, targetDir, etagOld)
+	})
 	return func(url string) (string, error) {
 		if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
 			return urlCache(url)
@@ -101,8 +160,11 @@ func openURL(dirCache *DirCache, client *http.Client, credentials []credential,
 	}
 }
 
-func openHelm(dirCache *DirCache, client *http.Client, credentials []credential, cache OpenDirCache) OpenDirCache {
-	helmCache := dirCache.WrapDir(loadURL(client, credentials, func(body io.Reader, dir string) error {
+// helmIndex loads the index of a helm repository
+type helmIndex func(indexURL string) (*renderer.Index, error)
+
+func loadHelmIndex(dirCache *DirCache, client *http.Client, credentials *credentials) helmIndex {
+	helmCache := dirCache.WrapRemoteDir(loadURL(client, credentials, func(body io.Reader, dir string) error {
 		out, err := os.Create(path.Join(dir, "index.yaml"))
 		if err != nil {
 			return err
@@ -111,41 +173,146 @@ func openHelm(dirCache *DirCache, client *http.Client, credentials []credential,
 		_, err = io.Copy(out, body)
 		return err
 	}))
-	return func(uri string) (string, error) {
-		u, err := url.Parse(uri)
+	return func(indexURL string) (*renderer.Index, error) {
+		dir, err := helmCache(indexURL)
 		if err != nil {
-			return cache(uri)
+			return nil, err
 		}
-		if u.Scheme == "helm" {
-			chart := path.Base(u.Path)
-			u.Path = path.Join(path.Dir(u.Path), "index.yaml")
-			u.Scheme = "https"
-			dir, err := helmCache(u.String())
-			if err != nil {
-				return "", err
-			}
-			indexIn, err := os.Open(path.Join(dir, "index.yaml"))
-			if err != nil {
-				return "", err
-			}
-			defer indexIn.Close()
-			var index renderer.Index
-			decoder := yaml.NewDecoder(indexIn)
-			err = decoder.Decode(&index)
-			if err != nil {
-				return "", err
-			}
-			entries, ok := index.Entries[chart]
-			if !ok {
-				return "", fmt.Errorf("chart %s not found in index", chart)
+		indexIn, err := os.Open(path.Join(dir, "index.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		defer indexIn.Close()
+		var index renderer.Index
+		decoder := yaml.NewDecoder(indexIn)
+		err = decoder.Decode(&index)
+		if err != nil {
+			return nil, err
+		}
+		return &index, nil
+	}
+}
+
+// helmReference - a chart in a helm repository: helm://host/path/chart[@version][?version=constraint]
+type helmReference struct {
+	indexURL   url.URL
+	chart      string
+	version    string
+	constraint *semver.Constraints
+}
+
+func parseHelmReference(uri string) (*helmReference, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "helm" {
+		return nil, fmt.Errorf("Invalid helm url %s", uri)
+	}
+	ref := &helmReference{chart: path.Base(u.Path)}
+	if i := strings.LastIndex(ref.chart, "@"); i >= 0 {
+		ref.chart, ref.version = ref.chart[:i], ref.chart[i+1:]
+	}
+	if constraint := u.Query().Get("version"); constraint != "" {
+		if ref.constraint, err = semver.NewConstraint(constraint); err != nil {
+			return nil, fmt.Errorf("Invalid version constraint %s in %s: %s", constraint, uri, err.Error())
+		}
+	}
+	ref.indexURL = url.URL{Scheme: "https", User: u.User, Host: u.Host, Path: path.Join(path.Dir(u.Path), "index.yaml")}
+	return ref, nil
+}
+
+// matches checks, if an entry has the pinned version or matches the version constraint
+func (r *helmReference) matches(entry renderer.Entry) bool {
+	if r.version != "" {
+		return strings.TrimPrefix(entry.Version, "v") == strings.TrimPrefix(r.version, "v")
+	}
+	if r.constraint != nil {
+		version, err := semver.NewVersion(entry.Version)
+		return err == nil && r.constraint.Check(version)
+	}
+	return true
+}
+
+// helmEntries returns the index entries of the chart referenced by a helm:// url, which match the version of
+// the url, starting with the highest version
+func helmEntries(index helmIndex, ref *helmReference) ([]renderer.Entry, error) {
+	idx, err := index(ref.indexURL.String())
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := idx.Entries[ref.chart]
+	if !ok {
+		return nil, fmt.Errorf("chart %s not found in index", ref.chart)
+	}
+	result := make([]renderer.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if !ref.matches(entry) {
+			continue
+		}
+		urls := make([]string, 0, len(entry.URLs))
+		for _, entryURL := range entry.URLs {
+			if u, err := url.Parse(entryURL); err == nil {
+				entryURL = ref.indexURL.ResolveReference(u).String()
 			}
-			for _, entry := range entries {
-				if !entry.Deprecated && len(entry.URLs) > 0 {
-					return cache(entry.URLs[0])
-				}
+			urls = append(urls, entryURL)
+		}
+		entry.URLs = urls
+		result = append(result, entry)
+	}
+	sortEntries(result)
+	return result, nil
+}
+
+// openHelm loads the highest version of a chart in a helm index, which isn't deprecated and matches the version of
+// the url. Pinned versions are loaded, even if they are deprecated. The archive is verified against the digest of the index.
+func openHelm(index helmIndex, load func(url string, digest string) (string, error), cache OpenDirCache) OpenDirCache {
+	return func(uri string) (string, error) {
+		if !strings.HasPrefix(uri, "helm://") {
+			return cache(uri)
+		}
+		ref, err := parseHelmReference(uri)
+		if err != nil {
+			return "", err
+		}
+		entries, err := helmEntries(index, ref)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			if (!entry.Deprecated || ref.version != "") && len(entry.URLs) > 0 {
+				return load(entry.URLs[0], entry.Digest)
 			}
 		}
-		return cache(uri)
+		return "", fmt.Errorf("No version of chart %s found in %s matching %s", ref.chart, ref.indexURL.String(), uri)
+	}
+}
+
+// loadHelmChart downloads charts referenced by helm indexes and verifies their digests
+func loadHelmChart(dirCache *DirCache, client *http.Client, credentials *credentials, verifier *verifier) func(url string, digest string) (string, error) {
+	return func(url string, digest string) (string, error) {
+		return dirCache.WrapRemoteDir(func(url string, targetDir func() (string, error), etagOld string) (string, error) {
+			extract := verifier.verifying(url, loadURLSuffix(client, credentials, url), verifyDigest(url, digest, extractArchive))
+			return loadURL(client, credentials, extract)(url, targetDir, etagOld)
+		})(url)
+	}
+}
+
+// verifyDigest checks the sha256 digest of an archive while extracting it
+func verifyDigest(url string, digest string, extract func(body io.Reader, dir string) error) func(body io.Reader, dir string) error {
+	if digest == "" {
+		return extract
+	}
+	return func(body io.Reader, dir string) error {
+		hash := sha256.New()
+		tee := io.TeeReader(body, hash)
+		if err := extract(tee, dir); err != nil {
+			return err
+		}
+		if _, err := io.Copy(ioutil.Discard, tee); err != nil {
+			return err
+		}
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != strings.TrimPrefix(digest, "sha256:") {
+			return fmt.Errorf("Digest of %s doesn't match the index: expected %s, got %s", url, digest, actual)
+		}
+		return nil
 	}
 }
 
@@ -165,13 +332,22 @@ func openWithFragment(cache OpenDirCache) OpenDirCache {
 	}
 }
 
-func openWithCatalogs(catalogs []string, cache OpenDirCache) OpenDirCache {
+func openWithCatalogs(catalogs []string, index helmIndex, cache OpenDirCache) OpenDirCache {
 	return func(uri string) (string, error) {
 		if match := catalogURL.FindStringSubmatch(uri); match != nil {
 			err := errors.New("No catalogs found")
 			dir := ""
 			for _, catalog := range catalogs {
-				dir, err = cache(catalog + "/" + match[1])
+				if entries := catalogEntries(index, cache, catalog, match[1]); len(entries) > 0 {
+					entry := latestEntry(entries)
+					if entry == nil {
+						err = fmt.Errorf("All versions of chart %s in catalog %s are deprecated", match[1], catalog)
+						continue
+					}
+					dir, err = cache(entry.URLs[0])
+				} else {
+					dir, err = cache(catalog + "/" + match[1])
+				}
 				if err == nil {
 					return dir, nil
 				}
@@ -182,14 +358,30 @@ func openWithCatalogs(catalogs []string, cache OpenDirCache) OpenDirCache {
 	}
 }
 
+// Push uploads the packaged chart to an OCI registry or a HTTP (WebDAV) endpoint and returns the url of the uploaded chart
+func (r *repoImpl) Push(chart Chart, uri string) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, "oci://"):
+		return r.pushOCI(chart, uri)
+	case strings.HasPrefix(uri, "https://") || strings.HasPrefix(uri, "http://"):
+		return r.pushHTTP(chart, uri)
+	}
+	return "", fmt.Errorf("Can't push to %s. Only oci://, https:// and http:// urls are supported", uri)
+}
+
 // Get -
 func (r *repoImpl) Get(thread *starlark.Thread, url string, opts ...ChartOption) (ChartValue, error) {
+	return r.get(thread, url, append(append([]ChartOption{}, opts...), NewGenusAndVersion(url).AsOptions()...)...)
+}
+
+func (r *repoImpl) get(thread *starlark.Thread, url string, opts ...ChartOption) (ChartValue, error) {
 
 	dir, err := r.cache(url)
 	if err != nil {
 		return nil, fmt.Errorf("Chart not found for url %s: %s", url, err.Error())
 	}
-	return newChart(thread, r, dir, append(opts, NewGenusAndVersion(url).AsOptions()...)...)
+	opts = append(append([]ChartOption{}, opts...), withKeyProvider(r.keyProvider), withImageMappings(r.images), withPolicies(r.policies), withWarnings(r.warnings))
+	return newChart(thread, r, dir, opts...)
 }
 
 func (r *repoImpl) cacheDirForChart(data []byte) string {
@@ -200,8 +392,6 @@ func (r *repoImpl) cacheDirForChart(data []byte) string {
 }
 
 func (r *repoImpl) GetFromSpec(thread *starlark.Thread, spec *kdov1a2.ChartSpec, options ...ChartOption) (ChartValue, error) {
-	var c *chartImpl
-	var err error
 	kwargs, err := spec.GetKwArgs()
 	if err != nil {
 		return nil, err
@@ -210,23 +400,23 @@ func (r *repoImpl) GetFromSpec(thread *starlark.Thread, spec *kdov1a2.ChartSpec,
 	if err != nil {
 		return nil, err
 	}
-	options = append(options, WithNamespace(spec.Namespace), WithSuffix(spec.Suffix), WithArgs(starutils.ToStarlark(spec.Args).(starlark.Tuple)), WithValues(values), WithValues(kwargs))
+	options = append(options, withKeyProvider(r.keyProvider), withImageMappings(r.images), withPolicies(r.policies), withWarnings(r.warnings), WithNamespace(spec.Namespace), WithSuffix(spec.Suffix), WithArgs(starutils.ToStarlark(spec.Args).(starlark.Tuple)), WithValues(values), WithValues(kwargs))
 	if spec.ChartURL != "" {
-		chart, err := r.Get(thread, spec.ChartURL, options...)
-		if err != nil {
-			c = chart.(*chartImpl)
-		}
-
-	} else {
-		c, err = newChartFromReader(thread, r, r.cacheDirForChart(spec.ChartTgz), bytes.NewReader(spec.ChartTgz), options...)
+		return r.Get(thread, spec.ChartURL, options...)
 	}
+	dir := r.cacheDirForChart(spec.ChartTgz)
+	extract := r.verifier.verifying("chart_tgz", loadSpecSuffix(spec.ChartProvenance, spec.ChartSignature), extractArchive)
+	if err := extract(bytes.NewReader(spec.ChartTgz), dir); err != nil {
+		return nil, err
+	}
+	c, err := newChart(thread, r, dir, options...)
 	if err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
-func newChartFromConfigMap(thread *starlark.Thread, r *repoImpl, configMap k8s.Object) (ChartValue, error) {
+func (r *repoImpl) newChartFromConfigMap(thread *starlark.Thread, k k8s.K8s, configMap k8s.Object) (ChartValue, error) {
 	dataJSON, ok := configMap.Additional["data"]
 	if !ok {
 		return nil, fmt.Errorf("Invalid config map")
@@ -246,40 +436,90 @@ func newChartFromConfigMap(thread *starlark.Thread, r *repoImpl, configMap k8s.O
 	if err != nil {
 		return nil, err
 	}
+	values, err := r.readProperties(k, configMap.MetaData.Namespace, configMap.MetaData.Name)
+	if err != nil {
+		return nil, err
+	}
 	gv := &GenusAndVersion{version: version, genus: configMap.MetaData.Labels["kdo.sap.github.com/genus"]}
-	return newChartFromReader(thread, r, r.cacheDirForChart(tgz), bytes.NewReader(tgz), gv.AsOptions()...)
+	options := append(gv.AsOptions(), withKeyProvider(r.keyProvider), withImageMappings(r.images), withPolicies(r.policies), withWarnings(r.warnings), WithValues(values))
+	if configMap.MetaData.Namespace != "" {
+		options = append(options, WithNamespace(configMap.MetaData.Namespace))
+	}
+	return newChartFromReader(thread, r, r.cacheDirForChart(tgz), bytes.NewReader(tgz), options...)
 }
 
-func (r *repoImpl) List(thread *starlark.Thread, k k8s.K8s, repoListOptions *RepoListOptions) ([]ChartValue, error) {
-	requirement, err := labels.NewRequirement("kdo.sap.github.com/chart", selection.Equals, []string{"true"})
+// readProperties reads the persisted properties of a chart from its secret
+func (r *repoImpl) readProperties(k k8s.K8s, namespace string, name string) (map[string]interface{}, error) {
+	obj, err := k.Get("secret", name, &k8s.Options{Namespace: namespace, IgnoreNotFound: true, Quiet: true})
+	if err != nil {
+		return nil, err
+	}
+	data, err := secretData(obj)
 	if err != nil {
 		return nil, err
 	}
+	data, err = decryptProperties(k, r.keyProvider, data)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read properties of %s in namespace %s: %s", name, namespace, err.Error())
+	}
+	values := make(map[string]interface{})
+	for key, j := range data {
+		var value interface{}
+		if err := json.Unmarshal(j, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (o *RepoListOptions) k8sOptions() (*k8s.Options, *k8s.ListOptions, error) {
+	requirement, err := labels.NewRequirement("kdo.sap.github.com/chart", selection.Equals, []string{"true"})
+	if err != nil {
+		return nil, nil, err
+	}
 	listOptions := &k8s.ListOptions{
 		LabelSelector: labels.NewSelector().Add(*requirement),
-		AllNamespaces: repoListOptions.allNamespaces,
+		AllNamespaces: o.allNamespaces,
 	}
-	if len(repoListOptions.genus) != 0 {
-		requirement, err := labels.NewRequirement("kdo.sap.github.com/genus", selection.Equals, []string{repoListOptions.genus})
+	if len(o.genus) != 0 {
+		requirement, err := labels.NewRequirement("kdo.sap.github.com/genus", selection.Equals, []string{o.genus})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		listOptions.LabelSelector = listOptions.LabelSelector.Add(*requirement)
 	}
-	k8sOptions := &k8s.Options{Quiet: true, Namespace: repoListOptions.namespace, ClusterScoped: repoListOptions.allNamespaces}
-	obj, err := k.List("configmaps", k8sOptions, listOptions)
+	return &k8s.Options{Quiet: true, Namespace: o.namespace, ClusterScoped: o.allNamespaces}, listOptions, nil
+}
+
+func listItems(k k8s.K8s, kind string, repoListOptions *RepoListOptions) ([]k8s.Object, error) {
+	k8sOptions, listOptions, err := repoListOptions.k8sOptions()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := k.List(kind, k8sOptions, listOptions)
 	if err != nil {
 		return nil, err
 	}
-	itemsJSON := obj.Additional["items"]
+	if obj == nil || len(obj.Additional["items"]) == 0 {
+		return nil, nil
+	}
 	var items []k8s.Object
-	err = json.Unmarshal(itemsJSON, &items)
+	err = json.Unmarshal(obj.Additional["items"], &items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *repoImpl) List(thread *starlark.Thread, k k8s.K8s, repoListOptions *RepoListOptions) ([]ChartValue, error) {
+	items, err := listItems(k, "configmaps", repoListOptions)
 	if err != nil {
 		return nil, err
 	}
 	charts := make([]ChartValue, 0)
 	for _, o := range items {
-		chart, err := newChartFromConfigMap(thread, r, o)
+		chart, err := r.newChartFromConfigMap(thread, k, o)
 		if err != nil {
 			return nil, err
 		}
@@ -288,6 +528,45 @@ func (r *repoImpl) List(thread *starlark.Thread, k k8s.K8s, repoListOptions *Rep
 	return charts, nil
 }
 
+// Rekey encrypts the persisted properties of all charts with the current key of the key provider
+func (r *repoImpl) Rekey(k k8s.K8s, repoListOptions *RepoListOptions, rotate bool) error {
+	if r.keyProvider == nil {
+		return errors.New("No encryption provider configured")
+	}
+	if rotate {
+		if err := r.keyProvider.Rotate(k); err != nil {
+			return err
+		}
+	}
+	items, err := listItems(k, "secrets", repoListOptions)
+	if err != nil {
+		return err
+	}
+	for _, o := range items {
+		secret := o
+		options := &k8s.Options{Namespace: secret.MetaData.Namespace, Quiet: true}
+		_, err := k.CreateOrUpdate(&secret, func(obj *k8s.Object) error {
+			data, err := secretData(obj)
+			if err != nil {
+				return err
+			}
+			properties, err := decryptProperties(k, r.keyProvider, data)
+			if err != nil {
+				return err
+			}
+			data, err = encryptProperties(k, r.keyProvider, properties)
+			if err != nil {
+				return err
+			}
+			return setSecretData(obj, data)
+		}, options)
+		if err != nil {
+			return fmt.Errorf("Can't rekey secret %s in namespace %s: %s", secret.MetaData.Name, secret.MetaData.Namespace, err.Error())
+		}
+	}
+	return nil
+}
+
 func newChartFromReader(thread *starlark.Thread, repo Repo, dir string, reader io.Reader, opts ...ChartOption) (*chartImpl, error) {
 	if err := extractArchive(reader, dir); err != nil {
 		return nil, err
@@ -313,51 +592,40 @@ var githubEnterpriseArchive = regexp.MustCompile("https://(github[^/]*)/api/v3/r
 var otherURL = regexp.MustCompile("(https|http)://(.*)/(v{0,1}\\d+\\.\\d+\\.\\d+)")
 var catalogURL = regexp.MustCompile("catalog:(.*)")
 
-func loadArchive(name string, targetDir func() (string, error), etagOld string) (string, error) {
-	stat, err := os.Stat(name)
-	if err != nil {
-		return "", err
-	}
-	tag := stat.ModTime().String()
-	if etagOld == tag {
+func loadArchive(verifier *verifier) LoadDir {
+	return func(name string, targetDir func() (string, error), etagOld string) (string, error) {
+		stat, err := os.Stat(name)
+		if err != nil {
+			return "", err
+		}
+		tag := stat.ModTime().String()
+		if etagOld == tag {
+			return tag, nil
+		}
+		dir, err := targetDir()
+		if err != nil {
+			return "", err
+		}
+		in, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer in.Close()
+		if err := verifier.verifying(name, loadFileSuffix(name), extractArchive)(in, dir); err != nil {
+			return "", err
+		}
 		return tag, nil
 	}
-	dir, err := targetDir()
-	if err != nil {
-		return "", err
-	}
-	in, err := os.Open(name)
-	if err != nil {
-		return "", err
-	}
-	defer in.Close()
-	if err := extractArchive(in, dir); err != nil {
-		return "", err
-	}
-	return tag, nil
 }
 
-func loadURL(client *http.Client, credentials []credential, extract func(body io.Reader, dir string) error) func(name string, targetDir func() (string, error), etagOld string) (string, error) {
+func loadURL(client *http.Client, credentials *credentials, extract func(body io.Reader, dir string) error) func(name string, targetDir func() (string, error), etagOld string) (string, error) {
 	return func(url string, targetDir func() (string, error), etagOld string) (string, error) {
 		request, err := http.NewRequest(http.MethodGet, url, nil)
-		maxMatch := 0
-		var bestMatch *credential
-		for _, cred := range credentials {
-			if strings.HasPrefix(url, cred.URL) && len(cred.URL) > maxMatch {
-				maxMatch = len(cred.URL)
-				bestMatch = &cred
-			}
-		}
-		if bestMatch != nil {
-			if len(bestMatch.Token) != 0 {
-				request.Header.Add("Authorization", "token "+bestMatch.Token)
-			} else if len(bestMatch.Username) != 0 {
-				request.Header.Add("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(bestMatch.Username+":"+bestMatch.Password)))
-			}
+		if err != nil {
+			return "", err
 		}
-
 		request.Header.Add("If-None-Match", etagOld)
-		res, err := client.Do(request)
+		res, err := doAuthorizedRequest(client, request, credentials)
 		if err != nil {
 			return "", fmt.Errorf("Error fetching %s: %v", url, err)
 		}
@@ -384,10 +652,17 @@ func loadURL(client *http.Client, credentials []credential, extract func(body io
 	}
 }
 
-func openLocal(openArchive OpenDirCache) OpenDirCache {
+// openLocal opens chart directories. They can't be signed, i.e. they are rejected, if verification is enforced. Sub
+// charts of charts in the cache directories have been verified together with their parent.
+func openLocal(verifier *verifier, cacheDirs []string, openArchive OpenDirCache) OpenDirCache {
 	return func(url string) (cachedDir string, err error) {
 		if stat, err := os.Stat(url); err == nil {
 			if stat.IsDir() {
+				if !inDirs(url, cacheDirs) {
+					if err := verifier.checkUnsigned(url); err != nil {
+						return "", err
+					}
+				}
 				return url, nil
 			}
 		}
@@ -395,6 +670,20 @@ func openLocal(openArchive OpenDirCache) OpenDirCache {
 	}
 }
 
+// inDirs returns true, if the file is located within one of the directories
+func inDirs(file string, dirs []string) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	for _, dir := range dirs {
+		if dir, err := filepath.Abs(dir); err == nil && strings.HasPrefix(abs, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 func extractArchive(in io.Reader, dir string) error {
 	prefix := chartDirExpr
 	reader := bufio.NewReader(in)
@@ -1,15 +1,77 @@
 package kdo
 
-type credential struct {
-	URL      string `yaml:"url,omitempty"`
-	Token    string `yaml:"token,omitempty"`
-	Username string `yaml:"username,omitempty"`
-	Password string `yaml:"password,omitempty"`
-}
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+)
 
 type repoConfigs struct {
-	Credentials []credential `yaml:"credentials,omitempty"`
-	Catalogs    []string     `yaml:"catalogs,omitempty"`
+	Credentials  []credential       `yaml:"credentials,omitempty"`
+	Catalogs     []string           `yaml:"catalogs,omitempty"`
+	Encryption   encryptionConfig   `yaml:"encryption,omitempty"`
+	Verification verificationConfig `yaml:"verification,omitempty"`
+	Cache        cacheConfig        `yaml:"cache,omitempty"`
+	Images       imageMappings      `yaml:"images,omitempty"`
+	Policies     policyConfig       `yaml:"policies,omitempty"`
+	keyProvider  KeyProvider
+	warnings     io.Writer
+	offline      bool
+	providers    []credentialSource
+	secrets      k8s.K8sReader
+}
+
+type cacheConfig struct {
+	Dir      string `yaml:"dir,omitempty"`
+	TTL      string `yaml:"ttl,omitempty"`
+	IndexTTL string `yaml:"indexTTL,omitempty"`
+}
+
+// indexTTL returns the time helm indexes are used without validation. It defaults to the ttl of the cache.
+func (c cacheConfig) indexTTL(dirCache *DirCache) (time.Duration, error) {
+	if c.IndexTTL == "" {
+		return dirCache.ttl, nil
+	}
+	ttl, err := time.ParseDuration(c.IndexTTL)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid index ttl %s: %s", c.IndexTTL, err.Error())
+	}
+	return ttl, nil
+}
+
+func (r *repoConfigs) newDirCache() (*DirCache, error) {
+	dir := r.Cache.Dir
+	if dir == "" {
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = path.Join(homedir, ".kdo", "cache-etag")
+	}
+	cache := &DirCache{baseDir: dir, offline: r.offline}
+	if r.Cache.TTL != "" {
+		ttl, err := time.ParseDuration(r.Cache.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cache ttl %s: %s", r.Cache.TTL, err.Error())
+		}
+		cache.ttl = ttl
+	}
+	return cache, nil
+}
+
+// NewCache returns the cache of charts, indexes and repositories used by a repo with the given configuration
+func NewCache(config ...RepoConfig) (*DirCache, error) {
+	configs := &repoConfigs{}
+	for _, cfg := range config {
+		if err := cfg(configs); err != nil {
+			return nil, err
+		}
+	}
+	return configs.newDirCache()
 }
 
 // RepoConfig -
@@ -31,6 +93,38 @@ func WithBasicAuth(url string, username string, password string) RepoConfig {
 	}
 }
 
+// WithBearerAuth -
+func WithBearerAuth(url string, token string) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Credentials = append(r.Credentials, credential{URL: url, Bearer: token})
+		return nil
+	}
+}
+
+// WithHeaders adds headers to all requests to urls with the given prefix
+func WithHeaders(url string, headers map[string]string) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Credentials = append(r.Credentials, credential{URL: url, Headers: headers})
+		return nil
+	}
+}
+
+// WithCredentialProvider gets the credentials of urls with the given prefix from a provider
+func WithCredentialProvider(url string, provider CredentialProvider) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.providers = append(r.providers, credentialSource{url: url, provider: provider})
+		return nil
+	}
+}
+
+// WithCredentialSecrets reads the kubernetes secrets referenced by credentials using k
+func WithCredentialSecrets(k k8s.K8sReader) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.secrets = k
+		return nil
+	}
+}
+
 // WithCatalog -
 func WithCatalog(url string) RepoConfig {
 	return func(r *repoConfigs) error {
@@ -39,6 +133,99 @@ func WithCatalog(url string) RepoConfig {
 	}
 }
 
+// WithVerification sets the verification mode (off, warn or enforce) for charts loaded from urls with the given prefix.
+// The empty prefix sets the default mode, which is also used for charts given as chart_tgz.
+func WithVerification(url string, mode string) RepoConfig {
+	return func(r *repoConfigs) error {
+		if url == "" {
+			r.Verification.Mode = mode
+			return nil
+		}
+		r.Verification.Repos = append(r.Verification.Repos, verificationRule{URL: url, Mode: mode})
+		return nil
+	}
+}
+
+// WithTrustedKey adds a public key in PEM format or the name of a file containing it
+func WithTrustedKey(key string) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Verification.TrustedKeys = append(r.Verification.TrustedKeys, key)
+		return nil
+	}
+}
+
+// WithCacheDir sets the directory of the cache (default ~/.kdo/cache-etag)
+func WithCacheDir(dir string) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Cache.Dir = dir
+		return nil
+	}
+}
+
+// WithCacheTTL sets the time cached remote content is used without asking the server for changes
+func WithCacheTTL(ttl time.Duration) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Cache.TTL = ttl.String()
+		return nil
+	}
+}
+
+// WithIndexTTL sets the time cached helm indexes are used without asking the server for changes
+func WithIndexTTL(ttl time.Duration) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Cache.IndexTTL = ttl.String()
+		return nil
+	}
+}
+
+// WithOffline serves remote charts only from the cache
+func WithOffline() RepoConfig {
+	return func(r *repoConfigs) error {
+		r.offline = true
+		return nil
+	}
+}
+
+// WithImageMapping relocates images starting with from, e.g. docker.io/* -> registry.internal/mirror/*
+func WithImageMapping(from string, to string) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Images = append(r.Images, imageMapping{From: from, To: to})
+		return nil
+	}
+}
+
+// WithPolicyRule enables a built-in policy rule. The action is deny or warn.
+func WithPolicyRule(name string, action string) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Policies.Rules = append(r.Policies.Rules, policyRuleConfig{Name: name, Action: action})
+		return nil
+	}
+}
+
+// WithPolicyFile adds a policy written in starlark
+func WithPolicyFile(filename string) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.Policies.Files = append(r.Policies.Files, filename)
+		return nil
+	}
+}
+
+// WithKeyProvider -
+func WithKeyProvider(provider KeyProvider) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.keyProvider = provider
+		return nil
+	}
+}
+
+// WithWarnings sets the writer for warnings of charts, e.g. deprecated properties. It defaults to stderr.
+func WithWarnings(writer io.Writer) RepoConfig {
+	return func(r *repoConfigs) error {
+		r.warnings = writer
+		return nil
+	}
+}
+
 // WithConfigFile -
 func WithConfigFile(filename string) RepoConfig {
 	return func(r *repoConfigs) error {
@@ -1,12 +1,14 @@
 package kdo
 
 import (
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	runtime2 "k8s.io/apimachinery/pkg/runtime"
 
@@ -15,6 +17,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	kdov1a2 "github.com/sap/kubernetes-deployment-orchestrator/api/v1alpha2"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/renderer"
 )
 
 var (
@@ -109,6 +112,73 @@ var _ = Describe("Repo", func() {
 			options := NewGenusAndVersion("catalog:istio")
 			Expect(options.genus).To(Equal("istio"))
 		})
+		It("helm urls match", func() {
+			options := NewGenusAndVersion("helm://charts.example.com/stable/redis@0.6.1?version=%3E0.1")
+			Expect(options.genus).To(Equal("redis"))
+			Expect(options.version).To(Equal(semver.MustParse("0.6.1")))
+			options = NewGenusAndVersion("helm://charts.example.com/stable/redis?version=^1.2")
+			Expect(options.genus).To(Equal("redis"))
+			Expect(options.version).To(BeNil())
+		})
+
+	})
+
+	Context("helm indexes", func() {
+		index := func(indexURL string) (*renderer.Index, error) {
+			Expect(indexURL).To(Equal("https://charts.example.com/stable/index.yaml"))
+			return &renderer.Index{Entries: map[string][]renderer.Entry{
+				"redis": {
+					{Version: "1.2.0", URLs: []string{"redis-1.2.0.tgz"}, Digest: "d120"},
+					{Version: "1.10.0", URLs: []string{"https://mirror.example.com/redis-1.10.0.tgz"}, Digest: "d1100"},
+					{Version: "2.0.0", URLs: []string{"redis-2.0.0.tgz"}, Deprecated: true},
+					{Version: "1.9.0", URLs: []string{"redis-1.9.0.tgz"}},
+				},
+			}}, nil
+		}
+		var loaded []string
+		open := openHelm(index, func(url string, digest string) (string, error) {
+			loaded = []string{url, digest}
+			return "dir", nil
+		}, func(url string) (string, error) {
+			return url, nil
+		})
+
+		It("selects the highest version, which isn't deprecated", func() {
+			_, err := open("helm://charts.example.com/stable/redis")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal([]string{"https://mirror.example.com/redis-1.10.0.tgz", "d1100"}))
+		})
 
+		It("selects versions matching a constraint", func() {
+			_, err := open("helm://charts.example.com/stable/redis?version=%3C1.10")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal([]string{"https://charts.example.com/stable/redis-1.9.0.tgz", ""}))
+			_, err = open("helm://charts.example.com/stable/redis?version=~1.2.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal([]string{"https://charts.example.com/stable/redis-1.2.0.tgz", "d120"}))
+			_, err = open("helm://charts.example.com/stable/redis?version=^3")
+			Expect(err).To(MatchError(ContainSubstring("No version of chart redis found")))
+		})
+
+		It("selects pinned versions", func() {
+			_, err := open("helm://charts.example.com/stable/redis@2.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal([]string{"https://charts.example.com/stable/redis-2.0.0.tgz", ""}))
+		})
+
+		It("passes other urls", func() {
+			dir, err := open("https://charts.example.com/stable/redis-1.2.0.tgz")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dir).To(Equal("https://charts.example.com/stable/redis-1.2.0.tgz"))
+		})
+
+		It("verifies digests", func() {
+			extract := func(body io.Reader, dir string) error {
+				_, err := io.CopyN(ioutil.Discard, body, 2)
+				return err
+			}
+			Expect(verifyDigest("redis.tgz", "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", extract)(strings.NewReader("hello"), "")).To(Succeed())
+			Expect(verifyDigest("redis.tgz", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", extract)(strings.NewReader("hello!"), "")).To(MatchError(ContainSubstring("Digest of redis.tgz doesn't match the index")))
+		})
 	})
 })
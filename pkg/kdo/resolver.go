@@ -0,0 +1,477 @@
+package kdo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"gopkg.in/yaml.v2"
+)
+
+// LockFile - name of the file pinning the resolved dependencies of a chart
+const LockFile = "Chart.lock"
+
+const maxResolveRounds = 10
+
+const resolvedDependenciesKey = "resolved-dependencies"
+
+// resolvedDependencies returns the dependencies resolved by the outermost apply
+func resolvedDependencies(thread *starlark.Thread) *Lock {
+	lock, _ := thread.Local(resolvedDependenciesKey).(*Lock)
+	return lock
+}
+
+// Lock - resolved dependencies of a chart tree
+type Lock struct {
+	Dependencies []LockedDependency `yaml:"dependencies"`
+	charts       map[string]ChartValue
+}
+
+// LockedDependency -
+type LockedDependency struct {
+	Genus      string   `yaml:"genus"`
+	Namespace  string   `yaml:"namespace"`
+	Version    string   `yaml:"version"`
+	URL        string   `yaml:"url"`
+	Digest     string   `yaml:"digest"`
+	RequiredBy []string `yaml:"requiredBy,omitempty"`
+}
+
+// ReadLock reads Chart.lock from a chart directory. Returns nil, if the chart has no lock file.
+func ReadLock(dir string) (*Lock, error) {
+	in, err := os.Open(path.Join(dir, LockFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer in.Close()
+	var lock Lock
+	if err := yaml.NewDecoder(in).Decode(&lock); err != nil {
+		return nil, fmt.Errorf("Invalid %s in %s: %v", LockFile, dir, err)
+	}
+	return &lock, nil
+}
+
+// Write -
+func (l *Lock) Write(writer io.Writer) error {
+	encoder := yaml.NewEncoder(writer)
+	defer encoder.Close()
+	return encoder.Encode(l)
+}
+
+func (l *Lock) find(namespace string, genus string) *LockedDependency {
+	if l == nil {
+		return nil
+	}
+	for i := range l.Dependencies {
+		if l.Dependencies[i].Namespace == namespace && l.Dependencies[i].Genus == genus {
+			return &l.Dependencies[i]
+		}
+	}
+	return nil
+}
+
+// chart returns the chart loaded during the resolution
+func (l *Lock) chart(namespace string, genus string) ChartValue {
+	if l == nil {
+		return nil
+	}
+	return l.charts[dependencyKey(namespace, genus)]
+}
+
+func dependencyKey(namespace string, genus string) string {
+	return namespace + "/" + genus
+}
+
+// chartDigest returns the sha256 digest of the packaged chart
+func chartDigest(chart ChartValue) (string, error) {
+	c, ok := chart.(*chartImpl)
+	if !ok {
+		return "", nil
+	}
+	hash := sha256.New()
+	if err := c.packageTgz(hash); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type candidate struct {
+	version *semver.Version
+	url     string
+	digest  string
+	chart   ChartValue
+}
+
+// ChartVersion - an available version of a chart
+type ChartVersion struct {
+	Version *semver.Version
+	URL     string
+	get     func() (ChartValue, error)
+}
+
+// Get loads the chart
+func (v *ChartVersion) Get() (ChartValue, error) {
+	return v.get()
+}
+
+// Versions returns the available versions of a chart starting with the highest version
+func (r *repoImpl) Versions(thread *starlark.Thread, url string, namespace string) ([]*ChartVersion, error) {
+	genus := NewGenusAndVersion(url).genus
+	key := dependencyKey(namespace, genus)
+	res := &resolver{
+		repo:       r,
+		thread:     thread,
+		candidates: map[string][]*candidate{},
+		genus:      map[string]string{key: genus},
+		namespace:  map[string]string{key: namespace},
+	}
+	candidates, err := res.candidatesFor(key, url)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*ChartVersion, 0, len(candidates))
+	for _, c := range candidates {
+		c := c
+		versions = append(versions, &ChartVersion{Version: c.version, URL: c.url, get: func() (ChartValue, error) {
+			if err := res.load(key, c); err != nil {
+				return nil, err
+			}
+			return c.chart, nil
+		}})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version.GreaterThan(versions[j].Version)
+	})
+	return versions, nil
+}
+
+type requirement struct {
+	by         string
+	url        string
+	constraint *semver.Constraints
+}
+
+func (r requirement) String() string {
+	return fmt.Sprintf("%s (required by %s)", r.constraint, r.by)
+}
+
+type resolver struct {
+	repo         *repoImpl
+	thread       *starlark.Thread
+	lock         *Lock
+	candidates   map[string][]*candidate
+	requirements map[string][]requirement
+	previous     map[string][]requirement
+	urls         map[string][]string
+	selected     map[string]*candidate
+	k8s          k8s.K8s
+	installed    map[string]*candidate
+	genus        map[string]string
+	namespace    map[string]string
+}
+
+// Resolve selects the highest version of all dependencies of the chart tree matching all constraints.
+// Versions installed in k, which match all constraints, are kept without looking up other versions. Otherwise versions
+// pinned in the Chart.lock of the chart are used, if the chart has a lock file. k may be nil.
+func (r *repoImpl) Resolve(thread *starlark.Thread, chart Chart, k k8s.K8s) (*Lock, error) {
+	c, ok := chart.(*chartImpl)
+	if !ok {
+		return &Lock{charts: map[string]ChartValue{}}, nil
+	}
+	lock, err := ReadLock(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	res := &resolver{
+		repo:       r,
+		thread:     thread,
+		lock:       lock,
+		candidates: map[string][]*candidate{},
+		selected:   map[string]*candidate{},
+		k8s:        k,
+		installed:  map[string]*candidate{},
+		urls:       map[string][]string{},
+		genus:      map[string]string{},
+		namespace:  map[string]string{},
+	}
+	for i := 0; i < maxResolveRounds; i++ {
+		res.previous = res.requirements
+		res.requirements = map[string][]requirement{}
+		changed, err := res.walk(c, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			return res.result()
+		}
+	}
+	return nil, fmt.Errorf("Unable to resolve dependencies of %s within %d rounds", c.GetName(), maxResolveRounds)
+}
+
+func (res *resolver) walk(c *chartImpl, visited map[string]bool) (bool, error) {
+	names := make([]string, 0, len(c.values))
+	for name := range c.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	changed := false
+	for _, name := range names {
+		switch v := c.values[name].(type) {
+		case *chartImpl:
+			subChanged, err := res.walk(v, visited)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || subChanged
+		case *dependency:
+			if _, ok := v.properties.(ChartValue); ok {
+				continue
+			}
+			genus := NewGenusAndVersion(v.url).genus
+			key := dependencyKey(v.namespace, genus)
+			res.genus[key] = genus
+			res.namespace[key] = v.namespace
+			res.requirements[key] = append(res.requirements[key], requirement{by: c.GetName(), url: v.url, constraint: v.constraint})
+			if !containsString(res.urls[key], v.url) {
+				res.urls[key] = append(res.urls[key], v.url)
+			}
+			selected, err := res.selectCandidate(key)
+			if err != nil {
+				return false, err
+			}
+			if current := res.selected[key]; current != nil && current.url == selected.url && current.version.Equal(selected.version) {
+				selected = current
+			} else {
+				res.selected[key] = selected
+				changed = true
+			}
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			if err := res.load(key, selected); err != nil {
+				return false, err
+			}
+			if dep, ok := selected.chart.(*chartImpl); ok {
+				subChanged, err := res.walk(dep, visited)
+				if err != nil {
+					return false, err
+				}
+				changed = changed || subChanged
+			}
+		}
+	}
+	return changed, nil
+}
+
+// satisfies checks the requirements found so far and the requirements of the previous round
+func (res *resolver) satisfies(key string, version *semver.Version) bool {
+	for _, req := range append(res.previous[key], res.requirements[key]...) {
+		if !req.constraint.Check(version) {
+			return false
+		}
+	}
+	return true
+}
+
+func (res *resolver) conflict(key string) error {
+	requirements := make([]string, 0, len(res.requirements[key]))
+	for _, req := range append(res.previous[key], res.requirements[key]...) {
+		if !containsString(requirements, req.String()) {
+			requirements = append(requirements, req.String())
+		}
+	}
+	if len(requirements) > 1 {
+		return fmt.Errorf("Conflicting constraints for genus %s in namespace %s: %s", res.genus[key], res.namespace[key], strings.Join(requirements, ", "))
+	}
+	return fmt.Errorf("No version of genus %s in namespace %s matches %s", res.genus[key], res.namespace[key], strings.Join(requirements, ", "))
+}
+
+func (res *resolver) selectCandidate(key string) (*candidate, error) {
+	installed, err := res.installedCandidate(key)
+	if err != nil {
+		return nil, err
+	}
+	if installed != nil && res.satisfies(key, installed.version) {
+		return installed, nil
+	}
+	if locked := res.lock.find(res.namespace[key], res.genus[key]); locked != nil {
+		version, err := semver.NewVersion(locked.Version)
+		if err != nil {
+			return nil, err
+		}
+		if !res.satisfies(key, version) {
+			return nil, fmt.Errorf("%s is out of date: version %s of genus %s doesn't match %v", LockFile, locked.Version, locked.Genus, res.requirements[key])
+		}
+		return &candidate{version: version, url: locked.URL, digest: locked.Digest}, nil
+	}
+	var best *candidate
+	for _, url := range res.urls[key] {
+		candidates, err := res.candidatesFor(key, url)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range candidates {
+			if res.satisfies(key, c.version) && (best == nil || c.version.GreaterThan(best.version)) {
+				best = c
+			}
+		}
+	}
+	if best == nil {
+		return nil, res.conflict(key)
+	}
+	return best, nil
+}
+
+// installedCandidate returns the installed version of a dependency or nil, if it isn't installed
+func (res *resolver) installedCandidate(key string) (*candidate, error) {
+	if res.k8s == nil {
+		return nil, nil
+	}
+	if installed, ok := res.installed[key]; ok {
+		return installed, nil
+	}
+	charts, err := res.repo.List(res.thread, res.k8s, &RepoListOptions{namespace: res.namespace[key], genus: res.genus[key]})
+	if err != nil {
+		return nil, err
+	}
+	var installed *candidate
+	if len(charts) == 1 && charts[0].GetVersion() != nil {
+		digest, err := chartDigest(charts[0])
+		if err != nil {
+			return nil, err
+		}
+		installed = &candidate{version: charts[0].GetVersion(), url: res.urls[key][0], digest: digest, chart: charts[0]}
+	}
+	res.installed[key] = installed
+	return installed, nil
+}
+
+// candidatesFor returns all available versions of a dependency
+func (res *resolver) candidatesFor(key string, uri string) ([]*candidate, error) {
+	cacheKey := res.namespace[key] + "/" + uri
+	if candidates, ok := res.candidates[cacheKey]; ok {
+		return candidates, nil
+	}
+	candidates := make([]*candidate, 0)
+	switch {
+	case strings.HasPrefix(uri, "helm://"):
+		ref, err := parseHelmReference(uri)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := helmEntries(res.repo.helmIndex, ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			version, err := semver.NewVersion(entry.Version)
+			if entry.Deprecated || len(entry.URLs) == 0 || err != nil {
+				continue
+			}
+			candidates = append(candidates, &candidate{version: version, url: entry.URLs[0]})
+		}
+	case catalogURL.MatchString(uri):
+		name := catalogURL.FindStringSubmatch(uri)[1]
+		for _, catalog := range res.repo.catalogs {
+			if entries := catalogEntries(res.repo.helmIndex, res.repo.cache, catalog, name); len(entries) > 0 {
+				for _, entry := range entries {
+					version, err := semver.NewVersion(entry.Version)
+					if entry.Deprecated || len(entry.URLs) == 0 || err != nil {
+						continue
+					}
+					candidates = append(candidates, &candidate{version: version, url: entry.URLs[0]})
+				}
+				continue
+			}
+			c := &candidate{url: catalog + "/" + name}
+			if err := res.load(key, c); err != nil {
+				continue
+			}
+			candidates = append(candidates, c)
+		}
+	default:
+		c := &candidate{url: uri}
+		if err := res.load(key, c); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	res.candidates[cacheKey] = candidates
+	return candidates, nil
+}
+
+// load loads the chart of a candidate and verifies its digest
+func (res *resolver) load(key string, c *candidate) error {
+	if c.chart != nil {
+		return nil
+	}
+	opts := append(NewGenusAndVersion(c.url).AsOptions(), WithGenus(res.genus[key]), WithNamespace(res.namespace[key]))
+	chart, err := res.repo.get(res.thread, c.url, opts...)
+	if err != nil {
+		return err
+	}
+	if c.version != nil && chart.GetVersion() != nil && !c.version.Equal(chart.GetVersion()) {
+		return fmt.Errorf("Chart %s has version %v instead of %v", c.url, chart.GetVersion(), c.version)
+	}
+	digest, err := chartDigest(chart)
+	if err != nil {
+		return err
+	}
+	if c.digest != "" && c.digest != digest {
+		return fmt.Errorf("Digest of chart %s doesn't match %s: expected %s, got %s", c.url, LockFile, c.digest, digest)
+	}
+	c.chart = chart
+	c.version = chart.GetVersion()
+	c.digest = digest
+	return nil
+}
+
+func (res *resolver) result() (*Lock, error) {
+	keys := make([]string, 0, len(res.requirements))
+	for key := range res.requirements {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lock := &Lock{Dependencies: make([]LockedDependency, 0, len(keys)), charts: map[string]ChartValue{}}
+	for _, key := range keys {
+		selected := res.selected[key]
+		if err := res.load(key, selected); err != nil {
+			return nil, err
+		}
+		requiredBy := make([]string, 0, len(res.requirements[key]))
+		for _, req := range res.requirements[key] {
+			requiredBy = append(requiredBy, fmt.Sprintf("%s %s", req.by, req.constraint))
+		}
+		lock.Dependencies = append(lock.Dependencies, LockedDependency{
+			Genus:      res.genus[key],
+			Namespace:  res.namespace[key],
+			Version:    selected.version.String(),
+			URL:        selected.url,
+			Digest:     selected.digest,
+			RequiredBy: requiredBy,
+		})
+		lock.charts[key] = selected.chart
+	}
+	return lock, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package kdo
+
+import (
+	"bytes"
+	"os"
+	"path"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("Resolver", func() {
+	var dir TestDir
+	var repo Repo
+	thread := &starlark.Thread{Name: "main"}
+
+	writeChart := func(name string, version string, star string) {
+		dir.MkdirAll(name, 0755)
+		dir.WriteFile(name+"/Chart.yaml", []byte("name: "+path.Base(name)+"\nversion: "+version+"\n"), 0644)
+		dir.WriteFile(name+"/Chart.star", []byte(star), 0644)
+	}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		repo, _ = NewRepo()
+		writeChart("v1/base", "1.0.0", "")
+		writeChart("v2/base", "2.0.0", "")
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	It("selects the highest version matching all constraints", func() {
+		writeChart("a", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v1/base")+`", ">= 1.0")
+`)
+		writeChart("b", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v2/base")+`", ">= 1.0")
+`)
+		writeChart("top", "1.0.0", `
+def init(self):
+  self.a = depends_on("`+dir.Join("a")+`", "1.x")
+  self.b = depends_on("`+dir.Join("b")+`", "1.x")
+`)
+		c, err := repo.Get(thread, dir.Join("top"))
+		Expect(err).NotTo(HaveOccurred())
+		lock, err := repo.Resolve(thread, c, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lock.Dependencies).To(HaveLen(3))
+		base := lock.find("default", "base")
+		Expect(base).NotTo(BeNil())
+		Expect(base.Version).To(Equal("2.0.0"))
+		Expect(base.URL).To(Equal(dir.Join("v2/base")))
+		Expect(base.Digest).To(HavePrefix("sha256:"))
+		Expect(base.RequiredBy).To(ConsistOf("a >=1.0", "b >=1.0"))
+	})
+
+	It("detects diamond conflicts", func() {
+		writeChart("a", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v1/base")+`", "< 2.0")
+`)
+		writeChart("b", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v2/base")+`", ">= 2.0")
+`)
+		writeChart("top", "1.0.0", `
+def init(self):
+  self.a = depends_on("`+dir.Join("a")+`", "1.x")
+  self.b = depends_on("`+dir.Join("b")+`", "1.x")
+`)
+		c, err := repo.Get(thread, dir.Join("top"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Resolve(thread, c, nil)
+		Expect(err).To(MatchError(ContainSubstring("Conflicting constraints for genus base in namespace default")))
+		Expect(err).To(MatchError(ContainSubstring("(required by a)")))
+		Expect(err).To(MatchError(ContainSubstring("(required by b)")))
+
+		k := k8s.NewK8sInMemory("default")
+		Expect(c.Apply(thread, k)).NotTo(Succeed())
+		_, err = k.Get("configmap", "kdo.a", &k8s.Options{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("uses and verifies Chart.lock", func() {
+		writeChart("top", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v2/base")+`", ">= 1.0")
+`)
+		c, err := repo.Get(thread, dir.Join("top"))
+		Expect(err).NotTo(HaveOccurred())
+		lock, err := repo.Resolve(thread, c, nil)
+		Expect(err).NotTo(HaveOccurred())
+		lock.Dependencies[0].URL = dir.Join("v1/base")
+		lock.Dependencies[0].Version = "1.0.0"
+		buf := &bytes.Buffer{}
+		Expect(lock.Write(buf)).To(Succeed())
+		Expect(dir.WriteFile("top/"+LockFile, buf.Bytes(), 0644)).To(Succeed())
+
+		_, err = repo.Resolve(thread, c, nil)
+		Expect(err).To(MatchError(ContainSubstring("Digest of chart " + dir.Join("v1/base") + " doesn't match Chart.lock")))
+
+		lock.Dependencies[0].Digest = ""
+		buf.Reset()
+		Expect(lock.Write(buf)).To(Succeed())
+		Expect(dir.WriteFile("top/"+LockFile, buf.Bytes(), 0644)).To(Succeed())
+		locked, err := repo.Resolve(thread, c, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locked.Dependencies[0].Version).To(Equal("1.0.0"))
+
+		Expect(os.Remove(dir.Join("top", LockFile))).To(Succeed())
+		Expect(dir.WriteFile("top/"+LockFile, []byte("dependencies:\n- genus: base\n  namespace: default\n  version: 0.5.0\n  url: x\n"), 0644)).To(Succeed())
+		_, err = repo.Resolve(thread, c, nil)
+		Expect(err).To(MatchError(ContainSubstring("Chart.lock is out of date")))
+	})
+
+	It("applies the resolved versions", func() {
+		writeChart("top", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v2/base")+`", ">= 2.0")
+`)
+		c, err := repo.Get(thread, dir.Join("top"))
+		Expect(err).NotTo(HaveOccurred())
+		k := k8s.NewK8sInMemory("default")
+		Expect(c.Apply(thread, k)).To(Succeed())
+		configMap, err := k.Get("configmap", "kdo.base", &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMap.MetaData.Labels).To(HaveKeyWithValue("kdo.sap.github.com/version", "2.0.0"))
+	})
+
+	Context("shared dependencies", func() {
+		var k *k8s.K8sInMemory
+		BeforeEach(func() {
+			k = k8s.NewK8sInMemory("default")
+			writeChart("b", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v2/base")+`", ">= 2.0")
+`)
+		})
+		apply := func(name string) error {
+			c, err := repo.Get(thread, dir.Join(name))
+			Expect(err).NotTo(HaveOccurred())
+			return c.Apply(thread, k)
+		}
+
+		It("upgrades a shared chart, if all charts using it accept the new version", func() {
+			writeChart("a", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v1/base")+`", ">= 1.0")
+`)
+			Expect(apply("a")).To(Succeed())
+			Expect(apply("b")).To(Succeed())
+			configMap, err := k.Get("configmap", "kdo.base", &k8s.Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configMap.MetaData.Labels).To(HaveKeyWithValue("kdo.sap.github.com/version", "2.0.0"))
+			Expect(configMap.MetaData.Annotations).To(HaveKeyWithValue("kdo-usedby-default_a", ">=1.0"))
+			Expect(configMap.MetaData.Annotations).To(HaveKeyWithValue("kdo-usedby-default_b", ">=2.0"))
+
+			charts, err := ListInstalled(k, &RepoListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			buf := &bytes.Buffer{}
+			Expect(WriteDependencyTree(buf, charts)).To(Succeed())
+			Expect(buf.String()).To(Equal("default\n└── a 1.0.0\n    └── default/base 2.0.0 (>=1.0)\n└── b 1.0.0\n    └── default/base 2.0.0 (>=2.0)\n"))
+		})
+
+		It("keeps the values of a shared chart during an upgrade", func() {
+			writeChart("v1/base", "1.0.0", "def init(self):\n  self.size = property()\n")
+			writeChart("v2/base", "2.0.0", "def init(self):\n  self.size = property()\n  self.tier = property()\n")
+			writeChart("a", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v1/base")+`", ">= 1.0")
+  self.base.size = "large"
+`)
+			writeChart("b", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v2/base")+`", ">= 2.0")
+  self.base.tier = "gold"
+`)
+			Expect(apply("a")).To(Succeed())
+			Expect(apply("b")).To(Succeed())
+			charts, err := repo.List(thread, k, &RepoListOptions{genus: "base"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(charts).To(HaveLen(1))
+			Expect(charts[0].GetVersion().String()).To(Equal("2.0.0"))
+			Expect(charts[0].Attr("size")).To(Equal(starlark.String("large")))
+			Expect(charts[0].Attr("tier")).To(Equal(starlark.String("gold")))
+		})
+
+		It("keeps installed versions matching the constraint", func() {
+			writeChart("a", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v1/base")+`", ">= 1.0")
+`)
+			Expect(apply("a")).To(Succeed())
+			Expect(os.RemoveAll(dir.Join("v1"))).To(Succeed())
+			c, err := repo.Get(thread, dir.Join("a"))
+			Expect(err).NotTo(HaveOccurred())
+			lock, err := repo.Resolve(thread, c, k)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lock.find("default", "base").Version).To(Equal("1.0.0"))
+			Expect(c.Apply(thread, k)).To(Succeed())
+		})
+
+		It("reports the charts blocking an upgrade", func() {
+			writeChart("a", "1.0.0", `
+def init(self):
+  self.base = depends_on("`+dir.Join("v1/base")+`", "< 2.0")
+`)
+			Expect(apply("a")).To(Succeed())
+			Expect(apply("b")).To(MatchError(ContainSubstring("can't upgrade genus base in namespace default from version 1.0.0 to 2.0.0: blocked by default_a (<2.0)")))
+			configMap, err := k.Get("configmap", "kdo.base", &k8s.Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configMap.MetaData.Labels).To(HaveKeyWithValue("kdo.sap.github.com/version", "1.0.0"))
+		})
+	})
+})
@@ -0,0 +1,116 @@
+package kdo
+
+import (
+	"sort"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var jsonSchemaTypes = map[string]string{
+	"string": "string",
+	"int":    "integer",
+	"float":  "number",
+	"bool":   "boolean",
+	"list":   "array",
+	"dict":   "object",
+}
+
+// schemaProvider - values, which are able to describe themselves as JSON schema
+type schemaProvider interface {
+	schema() map[string]interface{}
+}
+
+func (s *property) schema() map[string]interface{} {
+	result := map[string]interface{}{}
+	if typ, ok := jsonSchemaTypes[s.typ]; ok && s.typed {
+		result["type"] = typ
+	}
+	if s.description != "" {
+		result["description"] = s.description
+	}
+	if s.dflt != starlark.None && !s.secret {
+		result["default"] = starutils.ToGo(s.dflt)
+	}
+	if s.enum != nil {
+		result["enum"] = starutils.ToGo(s.enum)
+	}
+	if s.pattern != nil {
+		result["pattern"] = s.pattern.String()
+	}
+	minKey, maxKey := "minimum", "maximum"
+	switch s.typ {
+	case "string":
+		// untyped properties are bound by the length of their value
+		minKey, maxKey = "minLength", "maxLength"
+	case "list":
+		minKey, maxKey = "minItems", "maxItems"
+	}
+	if s.min != starlark.None {
+		result[minKey] = starutils.ToGo(s.min)
+	}
+	if s.max != starlark.None {
+		result[maxKey] = starutils.ToGo(s.max)
+	}
+	if s.secret {
+		result["writeOnly"] = true
+	}
+	if s.deprecated {
+		result["deprecated"] = true
+	}
+	return result
+}
+
+func (s *structProperty) schema() map[string]interface{} {
+	result := objectSchema(s.properties)
+	result["additionalProperties"] = s.additionalProperties
+	return result
+}
+
+func (c *chartImpl) schema() map[string]interface{} {
+	properties := make(map[string]PropertyValue)
+	for name, v := range c.values {
+		if property, ok := v.(PropertyValue); ok {
+			properties[name] = property
+		}
+	}
+	result := objectSchema(properties)
+	result["title"] = c.GetName()
+	if c.clazz.Description != "" {
+		result["description"] = c.clazz.Description
+	}
+	return result
+}
+
+// Schema returns the properties of the chart as JSON schema
+func (c *chartImpl) Schema() map[string]interface{} {
+	result := c.schema()
+	result["$schema"] = jsonSchemaDraft
+	return result
+}
+
+func objectSchema(values map[string]PropertyValue) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for name, v := range values {
+		provider, ok := v.(schemaProvider)
+		if !ok {
+			continue
+		}
+		properties[name] = provider.schema()
+		if p, ok := v.(*property); ok && p.required {
+			required = append(required, name)
+		}
+	}
+	result := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) != 0 {
+		sort.Strings(required)
+		result["required"] = required
+	}
+	return result
+}
@@ -0,0 +1,238 @@
+package kdo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/k14s/starlark-go/starlark"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
+)
+
+const secretRefKey = "secretRef"
+
+// redactedValue is rendered instead of the content of a secret reference, if secrets must not be revealed
+const redactedValue = "<redacted>"
+
+type secretRef struct {
+	namespace string
+	name      string
+	key       string
+	value     starlark.Value
+}
+
+var (
+	_ starlark.HasAttrs       = (*secretRef)(nil)
+	_ starutils.GoConvertible = (*secretRef)(nil)
+)
+
+var secretRefRegexp = regexp.MustCompile("^(([a-z0-9][-a-z0-9]*)/)?([a-z0-9][-a-z0-9.]*)#(.+)$")
+
+func newSecretRef(namespace, name, key string) *secretRef {
+	return &secretRef{namespace: namespace, name: name, key: key, value: starlark.None}
+}
+
+// parseSecretRef parses references in the format [namespace/]name#key
+func parseSecretRef(ref string) (*secretRef, error) {
+	match := secretRefRegexp.FindStringSubmatch(ref)
+	if match == nil {
+		return nil, fmt.Errorf("Invalid secret reference %s. Expected format is namespace/name#key", ref)
+	}
+	return newSecretRef(match[2], match[3], match[4]), nil
+}
+
+// secretRefFromGo converts the persisted representation of a secret reference back into a secretRef
+func secretRefFromGo(vi interface{}) (*secretRef, bool) {
+	m, ok := vi.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, false
+	}
+	ref, ok := m[secretRefKey].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	name, _ := ref["name"].(string)
+	key, _ := ref["key"].(string)
+	namespace, _ := ref["namespace"].(string)
+	if name == "" || key == "" {
+		return nil, false
+	}
+	return newSecretRef(namespace, name, key), true
+}
+
+func makeSecretRef(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (value starlark.Value, e error) {
+	s := newSecretRef("", "", "")
+	if err := starlark.UnpackArgs("secret_ref", args, kwargs, "name", &s.name, "key", &s.key, "namespace?", &s.namespace); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *secretRef) String() string {
+	return fmt.Sprintf("secret_ref(%s)", s.reference())
+}
+
+func (s *secretRef) reference() string {
+	if s.namespace == "" {
+		return s.name + "#" + s.key
+	}
+	return s.namespace + "/" + s.name + "#" + s.key
+}
+
+func (s *secretRef) Type() string {
+	return "secret_ref"
+}
+
+func (s *secretRef) Freeze() {
+}
+
+func (s *secretRef) Truth() starlark.Bool {
+	return true
+}
+
+func (s *secretRef) Hash() (uint32, error) {
+	return 0, errors.New("Hash() not implemented")
+}
+
+func (s *secretRef) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "namespace":
+		return starlark.String(s.namespace), nil
+	case "name":
+		return starlark.String(s.name), nil
+	case "key":
+		return starlark.String(s.key), nil
+	}
+	return starlark.None, starlark.NoSuchAttrError(fmt.Sprintf("secret_ref has no .%s attribute", name))
+}
+
+func (s *secretRef) AttrNames() []string {
+	return []string{"namespace", "name", "key"}
+}
+
+func (s *secretRef) resolve(k k8s.K8sReader, namespace string) error {
+	if s.namespace != "" {
+		namespace = s.namespace
+	}
+	obj, err := k.Get("secret", s.name, &k8s.Options{Namespace: namespace, Quiet: true})
+	if err != nil {
+		return fmt.Errorf("can't resolve secret reference %s: %v", s.reference(), err)
+	}
+	var data map[string][]byte
+	if loadedData, ok := obj.Additional["data"]; ok {
+		if err := json.Unmarshal(loadedData, &data); err != nil {
+			return err
+		}
+	}
+	value, ok := data[s.key]
+	if !ok {
+		return fmt.Errorf("can't resolve secret reference %s: key %s not found", s.reference(), s.key)
+	}
+	s.value = starlark.String(string(value))
+	return nil
+}
+
+func (s *secretRef) redact() {
+	s.value = starlark.String(redactedValue)
+}
+
+// persisted returns the representation of the reference which is stored instead of the secret value
+func (s *secretRef) persisted() map[string]interface{} {
+	ref := map[string]interface{}{"name": s.name, "key": s.key}
+	if s.namespace != "" {
+		ref["namespace"] = s.namespace
+	}
+	return map[string]interface{}{secretRefKey: ref}
+}
+
+// starutils.ToGo -
+func (s *secretRef) ToGo() interface{} {
+	return starutils.ToGo(s.value)
+}
+
+// eachSecretRef calls block for every secret reference found in value
+func eachSecretRef(value starlark.Value, block func(ref *secretRef) error) error {
+	switch v := value.(type) {
+	case starlark.String:
+		return nil
+	case *secretRef:
+		return block(v)
+	case *property:
+		if err := eachSecretRef(v.value, block); err != nil {
+			return err
+		}
+		return eachSecretRef(v.dflt, block)
+	case *structProperty:
+		for _, p := range v.properties {
+			if err := eachSecretRef(p, block); err != nil {
+				return err
+			}
+		}
+	case starlark.IterableMapping:
+		for _, t := range v.Items() {
+			if err := eachSecretRef(t.Index(1), block); err != nil {
+				return err
+			}
+		}
+	case starlark.Indexable:
+		for i := 0; i < v.Len(); i++ {
+			if err := eachSecretRef(v.Index(i), block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolvedValue returns the content of a resolved secret reference or the value itself
+func resolvedValue(value starlark.Value) starlark.Value {
+	ref, ok := value.(*secretRef)
+	if ok && ref.value != starlark.None {
+		return ref.value
+	}
+	return value
+}
+
+// toPersistedGo converts value like starutils.ToGo, but keeps secret references instead of their content
+func toPersistedGo(value starlark.Value) interface{} {
+	switch v := value.(type) {
+	case *secretRef:
+		return v.persisted()
+	case starlark.IterableMapping:
+		d := make(map[string]interface{})
+		for _, t := range v.Items() {
+			key, ok := t.Index(0).(starlark.String)
+			if ok {
+				value := toPersistedGo(t.Index(1))
+				if value != nil {
+					d[key.GoString()] = value
+				}
+			}
+		}
+		return d
+	case starlark.Tuple, *starlark.List:
+		a := make([]interface{}, 0)
+		for i := 0; i < starlark.Len(v); i++ {
+			a = append(a, toPersistedGo(v.(starlark.Indexable).Index(i)))
+		}
+		return a
+	}
+	return starutils.ToGo(value)
+}
+
+// toStarlarkWithSecretRefs converts vi like starutils.ToStarlark, but restores persisted secret references
+func toStarlarkWithSecretRefs(vi interface{}) starlark.Value {
+	if ref, ok := secretRefFromGo(vi); ok {
+		return ref
+	}
+	if m, ok := vi.(map[string]interface{}); ok {
+		d := starlark.NewDict(len(m))
+		for k, v := range m {
+			d.SetKey(starlark.String(k), toStarlarkWithSecretRefs(v))
+		}
+		return d
+	}
+	return starutils.ToStarlark(vi)
+}
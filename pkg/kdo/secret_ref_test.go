@@ -0,0 +1,113 @@
+package kdo
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sap/kubernetes-deployment-orchestrator/pkg/k8s"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+)
+
+var _ = Describe("SecretRef", func() {
+	It("parses references", func() {
+		ref, err := parseSecretRef("namespace/name#password")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.namespace).To(Equal("namespace"))
+		Expect(ref.name).To(Equal("name"))
+		Expect(ref.key).To(Equal("password"))
+		ref, err = parseSecretRef("name#password")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.namespace).To(Equal(""))
+		_, err = parseSecretRef("name")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("chart", func() {
+		var dir TestDir
+		var c *chartImpl
+		var kim *k8s.K8sInMemory
+		thread := &starlark.Thread{Name: "main"}
+
+		BeforeEach(func() {
+			dir = NewTestDir()
+			dir.MkdirAll("templates", 0755)
+			dir.WriteFile("templates/config.yaml", []byte("password: {{ .Values.password }}"), 0644)
+			dir.WriteFile("Chart.star", []byte(`
+def init(self):
+	self.password = property()
+`), 0644)
+			kim = k8s.NewK8sInMemory("default", k8s.Object{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				MetaData:   k8s.MetaData{Name: "db", Namespace: "secrets"},
+				Additional: map[string]json.RawMessage{"data": json.RawMessage(`{"password":"c2VjcmV0"}`)},
+			})
+			properties := starlark.NewDict(1)
+			properties.SetKey(starlark.String("password"), newSecretRef("secrets", "db", "password"))
+			repo, _ := NewRepo()
+			var err error
+			c, err = newChart(thread, repo, dir.Root(), WithProperties(properties))
+			Expect(err).NotTo(HaveOccurred())
+		})
+		AfterEach(func() {
+			dir.Remove()
+		})
+		It("resolves the secret during apply", func() {
+			err := c.Apply(thread, kim)
+			Expect(err).NotTo(HaveOccurred())
+			obj, err := kim.GetObject("secret", "kdo."+c.GetGenus(), &k8s.Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(obj.Additional["data"])).NotTo(ContainSubstring("c2VjcmV0"))
+			var data map[string][]byte
+			Expect(json.Unmarshal(obj.Additional["data"], &data)).To(Succeed())
+			Expect(string(data["password"])).To(MatchJSON(`{"secretRef":{"namespace":"secrets","name":"db","key":"password"}}`))
+			buf := &bytes.Buffer{}
+			Expect(c.helmTemplate(thread, "templates", "", kim, false)(buf)).To(Succeed())
+			Expect(buf.String()).To(ContainSubstring("password: secret"))
+		})
+		It("redacts the secret in template output", func() {
+			buf := &bytes.Buffer{}
+			err := c.Template(thread, kim)(buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf.String()).To(ContainSubstring("password: " + redactedValue))
+			Expect(c.String()).NotTo(ContainSubstring("secret\""))
+		})
+	})
+	It("resolves the secret for templates written in starlark", func() {
+		dir := NewTestDir()
+		defer dir.Remove()
+		dir.WriteFile("Chart.star", []byte(`
+def init(self):
+	self.password = property()
+def template(self, glob = "", k8s = None):
+	return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  password: " + self.password + "\n"
+`), 0644)
+		kim := k8s.NewK8sInMemory("default", k8s.Object{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			MetaData:   k8s.MetaData{Name: "db", Namespace: "secrets"},
+			Additional: map[string]json.RawMessage{"data": json.RawMessage(`{"password":"c2VjcmV0"}`)},
+		})
+		properties := starlark.NewDict(1)
+		properties.SetKey(starlark.String("password"), newSecretRef("secrets", "db", "password"))
+		repo, _ := NewRepo()
+		thread := &starlark.Thread{Name: "main"}
+		c, err := newChart(thread, repo, dir.Root(), WithProperties(properties))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Apply(thread, kim)).To(Succeed())
+		obj, err := kim.GetObject("configmap", "config", &k8s.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(obj.Additional["data"])).To(ContainSubstring(`"password":"secret"`))
+	})
+
+	It("restores persisted references", func() {
+		value := toStarlarkWithSecretRefs(map[string]interface{}{"secretRef": map[string]interface{}{"name": "db", "key": "password"}})
+		ref, ok := value.(*secretRef)
+		Expect(ok).To(BeTrue())
+		Expect(ref.reference()).To(Equal("db#password"))
+		Expect(toPersistedGo(ref)).To(Equal(ref.persisted()))
+	})
+})
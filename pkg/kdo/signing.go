@@ -0,0 +1,297 @@
+package kdo
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// Verification modes
+const (
+	VerificationOff     = "off"
+	VerificationWarn    = "warn"
+	VerificationEnforce = "enforce"
+)
+
+// ProvenanceSuffix and SignatureSuffix are appended to the name of a packaged chart
+const (
+	ProvenanceSuffix = ".prov"
+	SignatureSuffix  = ".sig"
+)
+
+// Provenance - the digest of a packaged chart
+type Provenance struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Digest  string `yaml:"digest"`
+}
+
+type verificationRule struct {
+	URL  string `yaml:"url"`
+	Mode string `yaml:"mode"`
+}
+
+type verificationConfig struct {
+	Mode        string             `yaml:"mode,omitempty"`
+	TrustedKeys []string           `yaml:"trustedKeys,omitempty"`
+	Repos       []verificationRule `yaml:"repos,omitempty"`
+}
+
+// verifier verifies the signatures of packaged charts
+type verifier struct {
+	config verificationConfig
+	keys   []ed25519.PublicKey
+}
+
+func checkVerificationMode(mode string) error {
+	switch mode {
+	case "", VerificationOff, VerificationWarn, VerificationEnforce:
+		return nil
+	}
+	return fmt.Errorf("Unknown verification mode %s. Supported modes are off, warn and enforce", mode)
+}
+
+func (v *verificationConfig) newVerifier() (*verifier, error) {
+	if err := checkVerificationMode(v.Mode); err != nil {
+		return nil, err
+	}
+	for _, rule := range v.Repos {
+		if err := checkVerificationMode(rule.Mode); err != nil {
+			return nil, err
+		}
+	}
+	result := &verifier{config: *v}
+	for _, key := range v.TrustedKeys {
+		data := []byte(key)
+		if !strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN") {
+			var err error
+			data, err = ioutil.ReadFile(key)
+			if err != nil {
+				return nil, err
+			}
+		}
+		publicKey, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted key %s: %s", key, err.Error())
+		}
+		result.keys = append(result.keys, publicKey)
+	}
+	return result, nil
+}
+
+func parsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("only ed25519 keys are supported")
+	}
+	return publicKey, nil
+}
+
+// ReadSigningKey reads an ed25519 private key in PKCS8 PEM format
+func ReadSigningKey(filename string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM data found in %s", filename)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Key in %s isn't an ed25519 key", filename)
+	}
+	return privateKey, nil
+}
+
+// SignPackage returns the provenance file of a packaged chart and its detached signature
+func SignPackage(chart Chart, tgz []byte, key ed25519.PrivateKey) ([]byte, []byte, error) {
+	provenance, err := yaml.Marshal(&Provenance{Name: chart.GetName(), Version: chart.GetVersion().String(), Digest: sha256Digest(tgz)})
+	if err != nil {
+		return nil, nil, err
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, provenance))
+	return provenance, []byte(signature + "\n"), nil
+}
+
+// mode returns the verification mode of the rule with the longest matching url prefix
+func (v *verifier) mode(url string) string {
+	mode := v.config.Mode
+	maxMatch := -1
+	for _, rule := range v.config.Repos {
+		if strings.HasPrefix(url, rule.URL) && len(rule.URL) > maxMatch {
+			maxMatch = len(rule.URL)
+			mode = rule.Mode
+		}
+	}
+	if mode == "" {
+		return VerificationOff
+	}
+	return mode
+}
+
+// matches checks, that the provenance has been created for a chart with the given name and version
+func (p *Provenance) matches(name string, version string) error {
+	if v, err := semver.NewVersion(version); err == nil {
+		version = v.String()
+	}
+	if p.Name != name || p.Version != version {
+		return fmt.Errorf("provenance of %s %s doesn't match chart %s %s", p.Name, p.Version, name, version)
+	}
+	return nil
+}
+
+// verify checks, that the signature of the provenance is valid for a trusted key and that the provenance matches the
+// data. It returns the verified provenance.
+func (v *verifier) verify(data []byte, provenance []byte, signature []byte) (*Provenance, error) {
+	if len(provenance) == 0 || len(signature) == 0 {
+		return nil, fmt.Errorf("chart isn't signed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %s", err.Error())
+	}
+	trusted := false
+	for _, key := range v.keys {
+		if ed25519.Verify(key, provenance, sig) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return nil, fmt.Errorf("signature isn't valid for any trusted key")
+	}
+	var prov Provenance
+	if err := yaml.Unmarshal(provenance, &prov); err != nil {
+		return nil, fmt.Errorf("invalid provenance: %s", err.Error())
+	}
+	if digest := sha256Digest(data); prov.Digest != digest {
+		return nil, fmt.Errorf("digest %s doesn't match provenance digest %s", digest, prov.Digest)
+	}
+	return &prov, nil
+}
+
+// report returns a verification failure in mode enforce. In mode warn, it's only logged.
+func (v *verifier) report(mode string, name string, err error) error {
+	if err == nil || mode == VerificationOff {
+		return nil
+	}
+	if mode == VerificationEnforce {
+		return fmt.Errorf("Verification of chart %s failed: %s", name, err.Error())
+	}
+	log.Printf("Warning: verification of chart %s failed: %s", name, err.Error())
+	return nil
+}
+
+// check verifies a chart according to the mode. It returns the provenance, if the verification succeeded.
+func (v *verifier) check(mode string, name string, data []byte, provenance []byte, signature []byte) (*Provenance, error) {
+	if mode == VerificationOff {
+		return nil, nil
+	}
+	prov, err := v.verify(data, provenance, signature)
+	return prov, v.report(mode, name, err)
+}
+
+// checkExtracted checks, that the provenance has been created for the chart extracted into dir
+func (v *verifier) checkExtracted(mode string, name string, prov *Provenance, dir string) error {
+	if prov == nil {
+		return nil
+	}
+	var class chartClass
+	if err := readYamlFile(path.Join(dir, "Chart.yaml"), &class); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return v.report(mode, name, prov.matches(class.Name, class.Version))
+}
+
+// checkUnsigned checks a chart loaded from a source without provenance and signature, e.g. oci, git or a local
+// directory. It's rejected, if verification is enforced for the url.
+func (v *verifier) checkUnsigned(name string) error {
+	_, err := v.check(v.mode(name), name, nil, nil, nil)
+	return err
+}
+
+// verifying wraps an extract function and verifies the data using the provenance and signature loaded by the given function
+func (v *verifier) verifying(name string, load func(suffix string) ([]byte, error), extract func(body io.Reader, dir string) error) func(body io.Reader, dir string) error {
+	mode := v.mode(name)
+	if mode == VerificationOff {
+		return extract
+	}
+	return func(body io.Reader, dir string) error {
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		provenance, _ := load(ProvenanceSuffix)
+		signature, _ := load(SignatureSuffix)
+		prov, err := v.check(mode, name, data, provenance, signature)
+		if err != nil {
+			return err
+		}
+		if err := extract(bytes.NewReader(data), dir); err != nil {
+			return err
+		}
+		return v.checkExtracted(mode, name, prov, dir)
+	}
+}
+
+// loadFileSuffix loads files next to a local archive
+func loadFileSuffix(name string) func(suffix string) ([]byte, error) {
+	return func(suffix string) ([]byte, error) {
+		return ioutil.ReadFile(name + suffix)
+	}
+}
+
+// loadSpecSuffix returns the provenance and signature given together with a chart_tgz
+func loadSpecSuffix(provenance []byte, signature []byte) func(suffix string) ([]byte, error) {
+	return func(suffix string) ([]byte, error) {
+		if suffix == ProvenanceSuffix {
+			return provenance, nil
+		}
+		return signature, nil
+	}
+}
+
+// loadURLSuffix loads files next to a downloaded archive
+func loadURLSuffix(client *http.Client, credentials *credentials, url string) func(suffix string) ([]byte, error) {
+	return func(suffix string) ([]byte, error) {
+		request, err := http.NewRequest(http.MethodGet, url+suffix, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := doAuthorizedRequest(client, request, credentials)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Error fetching %s: status=%d", url+suffix, res.StatusCode)
+		}
+		return ioutil.ReadAll(res.Body)
+	}
+}
@@ -0,0 +1,187 @@
+package kdo
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/k14s/starlark-go/starlark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	kdov1a2 "github.com/sap/kubernetes-deployment-orchestrator/api/v1alpha2"
+	. "github.com/sap/kubernetes-deployment-orchestrator/pkg/kdo/test"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Signing", func() {
+	var dir TestDir
+	var publicKey string
+	var tgz, provenance, signature []byte
+	thread := &starlark.Thread{Name: "main"}
+
+	BeforeEach(func() {
+		dir = NewTestDir()
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		Expect(err).NotTo(HaveOccurred())
+		dir.WriteFile("key.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+		der, err = x509.MarshalPKIXPublicKey(public)
+		Expect(err).NotTo(HaveOccurred())
+		publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+		dir.MkdirAll("chart", 0755)
+		dir.WriteFile("chart/Chart.yaml", []byte("name: chart\nversion: 1.0.0\n"), 0644)
+		repo, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		c, err := repo.Get(thread, dir.Join("chart"))
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(c.Package(buf, false)).To(Succeed())
+		tgz = buf.Bytes()
+		key, err := ReadSigningKey(dir.Join("key.pem"))
+		Expect(err).NotTo(HaveOccurred())
+		provenance, signature, err = SignPackage(c, tgz, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(provenance)).To(ContainSubstring("digest: " + sha256Digest(tgz)))
+	})
+	AfterEach(func() {
+		dir.Remove()
+	})
+
+	writePackage := func(name string, provenance []byte, signature []byte) string {
+		dir.WriteFile(name, tgz, 0644)
+		if provenance != nil {
+			dir.WriteFile(name+ProvenanceSuffix, provenance, 0644)
+		}
+		if signature != nil {
+			dir.WriteFile(name+SignatureSuffix, signature, 0644)
+		}
+		return dir.Join(name)
+	}
+
+	It("verifies signed packages", func() {
+		repo, err := NewRepo(WithVerification("", VerificationEnforce), WithTrustedKey(publicKey))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, writePackage("signed.tgz", provenance, signature))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = repo.Get(thread, writePackage("unsigned.tgz", nil, nil))
+		Expect(err).To(MatchError(ContainSubstring("chart isn't signed")))
+
+		tampered := bytes.Replace(provenance, []byte("1.0.0"), []byte("1.0.1"), 1)
+		_, err = repo.Get(thread, writePackage("tampered.tgz", tampered, signature))
+		Expect(err).To(MatchError(ContainSubstring("signature isn't valid for any trusted key")))
+
+		_, err = repo.Get(thread, dir.Join("chart"))
+		Expect(err).To(MatchError(ContainSubstring("Verification of chart " + dir.Join("chart") + " failed: chart isn't signed")))
+	})
+
+	It("checks name and version of the provenance", func() {
+		repo, err := NewRepo(WithVerification("", VerificationEnforce), WithTrustedKey(publicKey))
+		Expect(err).NotTo(HaveOccurred())
+		key, err := ReadSigningKey(dir.Join("key.pem"))
+		Expect(err).NotTo(HaveOccurred())
+		other, err := yaml.Marshal(&Provenance{Name: "other", Version: "1.0.0", Digest: sha256Digest(tgz)})
+		Expect(err).NotTo(HaveOccurred())
+		otherSignature := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, other)))
+		_, err = repo.Get(thread, writePackage("other.tgz", other, otherSignature))
+		Expect(err).To(MatchError(ContainSubstring("provenance of other 1.0.0 doesn't match chart chart 1.0.0")))
+		_, err = repo.GetFromSpec(thread, &kdov1a2.ChartSpec{Namespace: "default", ChartTgz: tgz, ChartProvenance: other, ChartSignature: otherSignature})
+		Expect(err).To(MatchError(ContainSubstring("provenance of other 1.0.0 doesn't match chart chart 1.0.0")))
+	})
+
+	It("verifies sub charts together with their parent", func() {
+		dir.MkdirAll("parent/sub", 0755)
+		dir.WriteFile("parent/Chart.yaml", []byte("name: parent\nversion: 1.0.0\n"), 0644)
+		dir.WriteFile("parent/Chart.star", []byte("def init(self):\n  self.sub = chart(\"sub\")\n"), 0644)
+		dir.WriteFile("parent/sub/Chart.yaml", []byte("name: sub\nversion: 1.0.0\n"), 0644)
+		unverified, err := NewRepo()
+		Expect(err).NotTo(HaveOccurred())
+		c, err := unverified.Get(thread, dir.Join("parent"))
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(c.Package(buf, false)).To(Succeed())
+		key, err := ReadSigningKey(dir.Join("key.pem"))
+		Expect(err).NotTo(HaveOccurred())
+		prov, sig, err := SignPackage(c, buf.Bytes(), key)
+		Expect(err).NotTo(HaveOccurred())
+		dir.WriteFile("parent.tgz", buf.Bytes(), 0644)
+		dir.WriteFile("parent.tgz"+ProvenanceSuffix, prov, 0644)
+		dir.WriteFile("parent.tgz"+SignatureSuffix, sig, 0644)
+
+		repo, err := NewRepo(WithVerification("", VerificationEnforce), WithTrustedKey(publicKey), WithCacheDir(dir.Join("cache")))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, dir.Join("parent.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("detects modified packages", func() {
+		repo, err := NewRepo(WithVerification("", VerificationEnforce), WithTrustedKey(publicKey))
+		Expect(err).NotTo(HaveOccurred())
+		writePackage("modified.tgz", provenance, signature)
+		dir.WriteFile("modified.tgz", append(append([]byte{}, tgz...), 0), 0644)
+		_, err = repo.Get(thread, dir.Join("modified.tgz"))
+		Expect(err).To(MatchError(ContainSubstring("doesn't match provenance digest")))
+	})
+
+	It("uses the mode of the longest matching url prefix", func() {
+		repo, err := NewRepo(WithVerification("", VerificationEnforce), WithVerification(dir.Join("trusted"), VerificationOff), WithVerification(dir.Join("trusted/warn"), VerificationWarn))
+		Expect(err).NotTo(HaveOccurred())
+		dir.MkdirAll("trusted", 0755)
+		_, err = repo.Get(thread, writePackage("trusted/chart.tgz", nil, nil))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, writePackage("trusted/warn-chart.tgz", nil, nil))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, writePackage("chart.tgz", nil, nil))
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewRepo(WithVerification("", "strict"))
+		Expect(err).To(MatchError(ContainSubstring("Unknown verification mode strict")))
+	})
+
+	It("verifies downloaded packages", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/chart.tgz", "/unsigned.tgz":
+				w.Write(tgz)
+			case "/chart.tgz.prov":
+				w.Write(provenance)
+			case "/chart.tgz.sig":
+				w.Write(signature)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		repo, err := NewRepo(WithVerification(server.URL, VerificationEnforce), WithTrustedKey(publicKey))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, server.URL+"/chart.tgz")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, server.URL+"/unsigned.tgz")
+		Expect(err).To(MatchError(ContainSubstring("chart isn't signed")))
+	})
+
+	It("refuses charts from sources without signatures", func() {
+		repo, err := NewRepo(WithVerification("", VerificationEnforce), WithTrustedKey(publicKey))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.Get(thread, "oci://registry.example.com/charts/chart:1.0.0")
+		Expect(err).To(MatchError(ContainSubstring("Verification of chart oci://registry.example.com/charts/chart:1.0.0 failed: chart isn't signed")))
+		_, err = repo.Get(thread, "git+https://example.com/charts.git//chart")
+		Expect(err).To(MatchError(ContainSubstring("Verification of chart git+https://example.com/charts.git//chart failed: chart isn't signed")))
+	})
+
+	It("refuses unsigned chart_tgz payloads", func() {
+		repo, err := NewRepo(WithVerification("", VerificationEnforce), WithTrustedKey(publicKey))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = repo.GetFromSpec(thread, &kdov1a2.ChartSpec{Namespace: "default", ChartTgz: tgz})
+		Expect(err).To(MatchError(ContainSubstring("Verification of chart chart_tgz failed: chart isn't signed")))
+		_, err = repo.GetFromSpec(thread, &kdov1a2.ChartSpec{Namespace: "default", ChartTgz: tgz, ChartProvenance: provenance, ChartSignature: signature})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -3,6 +3,7 @@ package kdo
 import (
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/k14s/starlark-go/starlark"
 	"github.com/sap/kubernetes-deployment-orchestrator/pkg/starutils"
@@ -81,7 +82,7 @@ func (s *structProperty) Attr(name string) (starlark.Value, error) {
 	}
 	simpleProperty, ok := p.(*property)
 	if ok {
-		return simpleProperty.GetValueOrDefault(), nil
+		return resolvedValue(simpleProperty.GetValueOrDefault()), nil
 	}
 	return p, nil
 }
@@ -143,11 +144,16 @@ func (s *structProperty) SetField(name string, val starlark.Value) error {
 	}
 	err := property.SetValue(val)
 	if err != nil {
-		return err
+		return withPath(name, err)
 	}
 	return nil
 }
 
+// checkRequired -
+func (s *structProperty) checkRequired() error {
+	return checkRequired(s.properties)
+}
+
 func (s *structProperty) SetKey(k, v starlark.Value) error {
 	return s.SetField(k.(starlark.String).GoString(), v)
 }
@@ -176,3 +182,24 @@ func (s *structProperty) AttrNames() []string {
 func (s *structProperty) ToGo() interface{} {
 	return starutils.ToGo(s.GetValueOrDefault())
 }
+
+type requiredChecker interface {
+	checkRequired() error
+}
+
+// checkRequired checks all required properties inside values
+func checkRequired(values map[string]PropertyValue) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if checker, ok := values[name].(requiredChecker); ok {
+			if err := checker.checkRequired(); err != nil {
+				return withPath(name, err)
+			}
+		}
+	}
+	return nil
+}
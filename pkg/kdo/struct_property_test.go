@@ -40,6 +40,12 @@ var _ = Describe("StructProperty", func() {
 		Expect(value).To(Equal(starlark.String("value")))
 
 	})
+	It("drops false values", func() {
+		s := newStructProperty(true)
+		Expect(s.SetField("enabled", starlark.False)).To(Succeed())
+		Expect(s.GetValue().(*starlark.Dict).Len()).To(Equal(0))
+		Expect(helmValue(s)).To(Equal(map[string]interface{}{"enabled": false}))
+	})
 	It("allows additional properties", func() {
 		s := newStructProperty(true)
 		err := s.SetField("test", starlark.String("test"))